@@ -0,0 +1,84 @@
+/*
+ * tokenize the card of the payment form in the browser, so only the id
+ * of its payment method is sent to the server. Stripe.js creates it for
+ * the stripe provider, the fake provider answers at /payment/cards.
+ */
+
+(function () {
+    var fields = document.querySelector('.card-fields');
+    if (!fields) {
+        return;
+    }
+    var form = fields.closest('form');
+    var method = document.getElementById('paymentMethod');
+    var provider = fields.getAttribute('data-provider');
+
+    function showErrors(errors) {
+        var spans = fields.querySelectorAll('[data-card-error]');
+        for (var i = 0; i < spans.length; i++) {
+            var message = errors[spans[i].getAttribute('data-card-error')];
+            spans[i].textContent = message || '';
+            spans[i].hidden = !message;
+        }
+    }
+
+    function value(id) {
+        return document.getElementById(id).value;
+    }
+
+    var stripe, card;
+    if (provider === 'stripe') {
+        stripe = Stripe(fields.getAttribute('data-key'));
+        card = stripe.elements().create('card', {hidePostalCode: true});
+        card.mount('#card-element');
+    }
+
+    //createMethod calls done with the id of the payment method, or with the problems of the card by field
+    function createMethod(done) {
+        if (stripe) {
+            stripe.createPaymentMethod({
+                type: 'card',
+                card: card,
+                billing_details: {name: value('cname')}
+            }).then(function (result) {
+                if (result.error) {
+                    done('', {number: result.error.message});
+                } else {
+                    done(result.paymentMethod.id, {});
+                }
+            });
+            return;
+        }
+        fetch('/payment/cards', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({
+                name: value('cname'),
+                number: value('ccnum'),
+                expMonth: parseInt(value('expmonth'), 10) || 0,
+                expYear: parseInt(value('expyear'), 10) || 0,
+                cvc: value('cvc')
+            })
+        }).then(function (response) {
+            return response.json().then(function (body) {
+                done(body.id || '', body.errors || {});
+            });
+        }).catch(function () {
+            done('', {number: 'Your card could not be checked, please try again.'});
+        });
+    }
+
+    form.addEventListener('submit', function (event) {
+        if (method.value) {
+            return;
+        }
+        event.preventDefault();
+        createMethod(function (id, errors) {
+            showErrors(errors);
+            if (id) {
+                method.value = id;
+                form.submit();
+            }
+        });
+    });
+})();
@@ -17,17 +17,3 @@ function validate() {
       
     return false;
  }
-
-function validateCard(){
-   var visaReg=/^(?:4[0-9]{12}(?:[0-9]{3})?)$/;
-   console.log($("#ccnum").val())
-   console.log($("#cardnum").val().match(visaReg))
-   event.preventDefault();
-   if($("#cardnum").val().match(visaReg)){
-     alert("valid visa")
-      console.log("valid")
-     return true;
-   }else{
-     return false;
-   }
-}
\ No newline at end of file
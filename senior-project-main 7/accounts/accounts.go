@@ -0,0 +1,265 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"test/mail"
+)
+
+var (
+	// ErrNotFound is returned when no user or reset token matches
+	ErrNotFound = errors.New("accounts: not found")
+	// ErrEmailTaken is returned when registering an email that has an account
+	ErrEmailTaken = errors.New("accounts: an account already exists for this email")
+	// ErrInvalidCredentials is returned for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("accounts: invalid email or password")
+	// ErrInvalidToken is returned for a reset token that is unknown, used or expired
+	ErrInvalidToken = errors.New("accounts: invalid or expired reset token")
+	// ErrInvalidEmail is returned for anything but a single bare address
+	ErrInvalidEmail = errors.New("accounts: invalid email address")
+	// ErrWeakPassword is returned for passwords shorter than MinPasswordLength
+	ErrWeakPassword = fmt.Errorf("accounts: passwords need at least %d characters", MinPasswordLength)
+	// ErrLongPassword is returned for passwords longer than bcrypt reads
+	ErrLongPassword = errors.New("accounts: passwords can be at most 72 bytes")
+)
+
+const (
+	MinPasswordLength = 8
+	// DefaultResetTTL is how long a password reset link can be used
+	DefaultResetTTL = time.Hour
+)
+
+// User is a registered customer. Email is stored lower cased.
+type User struct {
+	ID           int64
+	Email        string
+	PasswordHash string
+	// OrgID is the organization the user orders for, 0 for the default one
+	OrgID int64
+	// Admin users may use the admin pages. It is only set with SetAdmin,
+	// CreateUser ignores it.
+	Admin     bool
+	CreatedAt time.Time
+}
+
+// Reset is a pending password reset. Only the SHA-256 of the token is
+// stored, the token itself is only in the email.
+type Reset struct {
+	TokenHash string
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+// Store persists users and their password resets
+type Store interface {
+	// CreateUser returns ErrEmailTaken when the email is already registered
+	CreateUser(ctx context.Context, user User) (User, error)
+	User(ctx context.Context, id int64) (User, error)
+	UserByEmail(ctx context.Context, email string) (User, error)
+	// SetPasswordHash replaces the password of a user and drops their
+	// pending resets
+	SetPasswordHash(ctx context.Context, id int64, passwordHash string) error
+	SaveReset(ctx context.Context, reset Reset) error
+	// TakeReset deletes and returns the reset of tokenHash
+	TakeReset(ctx context.Context, tokenHash string) (Reset, error)
+	// SetAdmin grants or revokes the admin pages to the user of id
+	SetAdmin(ctx context.Context, id int64, admin bool) error
+}
+
+// Service registers and authenticates users and runs the password reset flow
+type Service struct {
+	// Brand names the organization a user's emails are sent for, "Renulogix"
+	// when nil
+	Brand func(ctx context.Context, user User) string
+
+	store    Store
+	mailer   mail.Sender
+	resetTTL time.Duration
+	now      func() time.Time
+}
+
+func New(store Store, mailer mail.Sender, resetTTL time.Duration) *Service {
+	if resetTTL <= 0 {
+		resetTTL = DefaultResetTTL
+	}
+	return &Service{store: store, mailer: mailer, resetTTL: resetTTL, now: time.Now}
+}
+
+// Register creates an account of organization orgId with a bcrypt hash of password
+func (s *Service) Register(ctx context.Context, orgId int64, email string, password string) (User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	return s.store.CreateUser(ctx, User{Email: email, PasswordHash: hash, OrgID: orgId})
+}
+
+// Authenticate returns the user of email when password matches
+func (s *Service) Authenticate(ctx context.Context, email string, password string) (User, error) {
+	user, err := s.store.UserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrNotFound) {
+		//Comparing anyway keeps unknown emails as slow as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// User returns the user with id
+func (s *Service) User(ctx context.Context, id int64) (User, error) {
+	return s.store.User(ctx, id)
+}
+
+// SetAdmin grants or revokes the admin pages to the account of email. It is
+// run from the command line by an operator, never from a page, so an account
+// registered with the email of an admin gets no rights.
+func (s *Service) SetAdmin(ctx context.Context, email string, admin bool) (User, error) {
+	user, err := s.store.UserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return User{}, err
+	}
+	if err := s.store.SetAdmin(ctx, user.ID, admin); err != nil {
+		return User{}, err
+	}
+	user.Admin = admin
+	return user, nil
+}
+
+// RequestReset emails a password reset link to the user of email. link turns
+// the token into the URL of the reset page. Unknown emails are ignored so the
+// caller cannot tell which emails have an account.
+func (s *Service) RequestReset(ctx context.Context, email string, link func(token string) string) error {
+	user, err := s.store.UserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("accounts: generating reset token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+	reset := Reset{TokenHash: hashToken(token), UserID: user.ID, ExpiresAt: s.now().Add(s.resetTTL).UTC()}
+	if err := s.store.SaveReset(ctx, reset); err != nil {
+		return err
+	}
+	brand := "Renulogix"
+	if s.Brand != nil {
+		brand = s.Brand(ctx, user)
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your " + brand + " password",
+		Body: "Someone asked to reset the password of your " + brand + " account.\n\n" +
+			"Open this link within " + ValidFor(s.resetTTL) + " to choose a new password:\n" + link(token) + "\n\n" +
+			"If you did not ask for this, you can ignore this email.\n",
+	})
+}
+
+// ResetPassword sets a new password with a token sent by RequestReset. A
+// token can only be used once.
+func (s *Service) ResetPassword(ctx context.Context, token string, password string) (User, error) {
+	//The password is checked first so a rejected one does not use up the token
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	reset, err := s.store.TakeReset(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if !s.now().Before(reset.ExpiresAt) {
+		return User{}, ErrInvalidToken
+	}
+	if err := s.store.SetPasswordHash(ctx, reset.UserID, hash); err != nil {
+		return User{}, err
+	}
+	return s.store.User(ctx, reset.UserID)
+}
+
+// ValidFor describes how long a reset link works, such as "1 hour" or "30 minutes"
+func ValidFor(ttl time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case ttl >= 24*time.Hour && ttl%(24*time.Hour) == 0:
+		return plural(int64(ttl/(24*time.Hour)), "day")
+	case ttl >= time.Hour && ttl%time.Hour == 0:
+		return plural(int64(ttl/time.Hour), "hour")
+	case ttl >= time.Minute:
+		return plural(int64(ttl/time.Minute), "minute")
+	}
+	return ttl.String()
+}
+
+// NormalizeEmail checks email is a single bare address and lower cases it
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	parsed, err := netmail.ParseAddress(email)
+	if err != nil || parsed.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len([]rune(password)) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	if len(password) > 72 {
+		return "", ErrLongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("accounts: hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	dummyOnce sync.Once
+	dummy     []byte
+)
+
+func dummyHash() []byte {
+	dummyOnce.Do(func() {
+		dummy, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	})
+	return dummy
+}
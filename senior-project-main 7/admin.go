@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/accounts"
+	"test/blob"
+	"test/orders"
+	"test/orgs"
+	"test/payment"
+	"test/storage"
+)
+
+//Orders listed on each page of the admin dashboard
+const adminPageSize = 50
+
+//Months shown in the orders and revenue summary, the current one included
+const adminSummaryMonths = 12
+
+//Values shown on the admin dashboard
+type AdminPageVariables struct {
+	Brand    Branding
+	Email    string
+	Filter   AdminFilter
+	Statuses []orders.Status
+	Orders   []AdminOrderRow
+	Total    int
+	Page     int
+	Pages    int
+	PrevURL  string
+	NextURL  string
+	Months   []AdminMonth
+	Error    string
+}
+
+//Filters of the admin dashboard as they were typed in
+type AdminFilter struct {
+	Search string
+	Status string
+	Type   string
+	State  string
+	Email  string
+	From   string
+	To     string
+}
+
+//One order of the admin dashboard
+type AdminOrderRow struct {
+	storage.OrderListing
+	Organization string
+	Price        int
+	Placed       string
+}
+
+//Orders and revenue of one month, Bar is the revenue as a percentage of the best month
+type AdminMonth struct {
+	Month   string
+	Orders  int
+	Revenue int
+	Bar     int
+}
+
+//Values shown on the admin page of one order
+type AdminOrderPageVariables struct {
+	Brand        Branding
+	Order        storage.OrderRecord
+	Organization orgs.Organization
+	Account      string
+	Price        int
+	Placed       string
+	Card         string
+	Status       orders.Order
+	StatusError  string
+	NREL         *storage.ReportData
+	Monthly      []storage.MonthlyNREL
+	Invoice      *storage.Artifact
+	Files        []AdminFile
+}
+
+//A file of a report kept in the blob store
+type AdminFile struct {
+	Name        string
+	ContentType string
+	Size        string
+	Modified    string
+}
+
+//Names of the report files the admin page looks for in the blob store
+var adminFileNames = []string{
+	"radiance.json",
+	"pdf/basic-report.pdf",
+	"pdf/advanced-report.pdf",
+	"images/overhead.jpg",
+	"images/north.jpg",
+	"images/south.jpg",
+	"images/east.jpg",
+	"images/west.jpg",
+}
+
+//This function adds the admin pages to the report server
+func (s *server) handleAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("/admin", s.adminOrders)
+	mux.HandleFunc("/admin/orders/", s.adminOrder)
+}
+
+//This function returns the signed in admin. Other users get a 404 so the admin pages cannot be found
+func (s *server) requireAdmin(w http.ResponseWriter, r *http.Request) (accounts.User, bool) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return user, false
+	}
+	if !user.Admin {
+		http.NotFound(w, r)
+		return user, false
+	}
+	return user, true
+}
+
+//This function lists the orders matching the filters of the dashboard, with the orders and revenue of the last months
+func (s *server) adminOrders(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	query := r.URL.Query()
+	vars := AdminPageVariables{
+		Brand:    brandOf(s.defaultOrg),
+		Email:    user.Email,
+		Statuses: orders.Statuses,
+		Filter: AdminFilter{
+			Search: strings.TrimSpace(query.Get("q")),
+			Status: query.Get("status"),
+			Type:   query.Get("type"),
+			State:  strings.TrimSpace(query.Get("state")),
+			Email:  strings.TrimSpace(query.Get("email")),
+			From:   query.Get("from"),
+			To:     query.Get("to"),
+		},
+	}
+	filter, problem := vars.Filter.storageFilter()
+	vars.Error = problem
+	vars.Page, _ = strconv.Atoi(query.Get("page"))
+	if vars.Page < 1 {
+		vars.Page = 1
+	}
+	filter.Limit = adminPageSize
+	filter.Offset = (vars.Page - 1) * adminPageSize
+
+	organization := s.organizationIndex(ctx)
+	listings, total, err := s.store.SearchOrders(ctx, filter)
+	if err != nil {
+		log.Print(err.Error())
+		vars.Error = "The orders are unavailable right now, please try again later."
+	}
+	for _, listing := range listings {
+		org := organization(listing.OrgID)
+		vars.Orders = append(vars.Orders, AdminOrderRow{
+			OrderListing: listing,
+			Organization: org.Name,
+			Price:        orderPrice(org, listing.OrderRecord),
+			Placed:       formatAdminTime(listing.CreatedAt),
+		})
+	}
+	vars.Total = total
+	vars.Pages = (total + adminPageSize - 1) / adminPageSize
+	if vars.Page > 1 {
+		vars.PrevURL = adminPageURL(query, vars.Page-1)
+	}
+	if vars.Page < vars.Pages {
+		vars.NextURL = adminPageURL(query, vars.Page+1)
+	}
+
+	vars.Months, err = s.adminMonths(ctx, organization)
+	if err != nil {
+		log.Print(err.Error())
+	}
+	t, err := template.ParseFiles("html/admin.html", "html/brand.html")
+	if err != nil {
+		log.Print("template parsing error: ", err)
+		return
+	}
+	if err := t.Execute(w, vars); err != nil {
+		log.Print("template executing error: ", err)
+	}
+}
+
+//This function turns the filters of the dashboard into a storage filter, with a message for a date it cannot read. The dates are days, both included
+func (f AdminFilter) storageFilter() (storage.OrderFilter, string) {
+	filter := storage.OrderFilter{
+		Search:     f.Search,
+		Status:     orders.Status(f.Status),
+		ReportType: f.Type,
+		State:      f.State,
+		Email:      f.Email,
+	}
+	var err error
+	if f.From != "" {
+		if filter.From, err = time.ParseInLocation("2006-01-02", f.From, time.Local); err != nil {
+			return filter, fmt.Sprintf("The from date %q is not a date, it is ignored.", f.From)
+		}
+	}
+	if f.To != "" {
+		if filter.To, err = time.ParseInLocation("2006-01-02", f.To, time.Local); err != nil {
+			return filter, fmt.Sprintf("The to date %q is not a date, it is ignored.", f.To)
+		}
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	return filter, ""
+}
+
+//This function returns the dashboard URL of another page with the same filters
+func adminPageURL(query url.Values, page int) string {
+	values := url.Values{}
+	for name, value := range query {
+		values[name] = value
+	}
+	values.Set("page", strconv.Itoa(page))
+	return "/admin?" + values.Encode()
+}
+
+//This function counts the orders and revenue of the last months, oldest first
+func (s *server) adminMonths(ctx context.Context, organization func(int64) orgs.Organization) ([]AdminMonth, error) {
+	now := time.Now().UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-adminSummaryMonths, 0)
+	months := make([]AdminMonth, adminSummaryMonths)
+	for i := range months {
+		months[i].Month = first.AddDate(0, i, 0).Format("Jan 2006")
+	}
+	totals, err := s.store.OrderTotals(ctx, first)
+	if err != nil {
+		return months, err
+	}
+	for _, total := range totals {
+		i := (total.Month.Year()-first.Year())*12 + int(total.Month.Month()) - int(first.Month())
+		if i < 0 || i >= len(months) {
+			continue
+		}
+		record := storage.OrderRecord{ReportType: total.ReportType, Price: total.Price}
+		months[i].Orders += total.Count
+		months[i].Revenue += total.Count * orderPrice(organization(total.OrgID), record)
+	}
+	best := 0
+	for _, month := range months {
+		if month.Revenue > best {
+			best = month.Revenue
+		}
+	}
+	for i := range months {
+		if best > 0 {
+			months[i].Bar = months[i].Revenue * 100 / best
+		}
+	}
+	return months, nil
+}
+
+//This function shows an order with its EagleView status, NREL data, invoice and stored files
+func (s *server) adminOrder(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireAdmin(w, r); !ok {
+		return
+	}
+	ctx := r.Context()
+	reportId, file := splitAdminPath(r.URL.Path)
+	record, err := s.store.OrderDetails(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "The order is unavailable right now", http.StatusInternalServerError)
+		return
+	}
+	if file != "" {
+		s.adminFile(w, r, reportId, file)
+		return
+	}
+
+	org := s.organization(ctx, record.OrgID)
+	vars := AdminOrderPageVariables{
+		Brand:        brandOf(s.defaultOrg),
+		Order:        record,
+		Organization: org,
+		Price:        orderPrice(org, record),
+		Placed:       formatAdminTime(record.CreatedAt),
+	}
+	if record.PaymentID != "" {
+		vars.Card = payment.Brand(record.CardBrand).Name() + " ending " + record.CardLast4
+	}
+	if record.UserID != 0 {
+		if account, err := s.users.User(ctx, record.UserID); err == nil {
+			vars.Account = account.Email
+		} else if !errors.Is(err, accounts.ErrNotFound) {
+			log.Print(err.Error())
+		}
+	}
+	if vars.Status, err = s.orderStatus(ctx, reportId); err != nil {
+		log.Print(err.Error())
+		vars.StatusError = "The status of this report is unavailable right now."
+	}
+	if data, err := s.store.ReportData(ctx, reportId); err == nil {
+		vars.NREL = &data
+		if vars.Monthly, err = s.store.NRELMonthly(ctx, reportId); err != nil {
+			log.Print(err.Error())
+		}
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		log.Print(err.Error())
+	}
+	if invoice, err := s.store.Artifact(ctx, reportId, storage.ArtifactInvoice); err == nil {
+		vars.Invoice = &invoice
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		log.Print(err.Error())
+	}
+	for _, name := range adminFileNames {
+		info, err := s.blobs.Stat(ctx, blob.ReportKey(reportId, name))
+		if err != nil {
+			if !errors.Is(err, blob.ErrNotFound) {
+				log.Print(err.Error())
+			}
+			continue
+		}
+		vars.Files = append(vars.Files, AdminFile{
+			Name:        name,
+			ContentType: info.ContentType,
+			Size:        formatSize(info.Size),
+			Modified:    formatAdminTime(info.Modified),
+		})
+	}
+
+	t, err := template.ParseFiles("html/adminOrder.html", "html/brand.html")
+	if err != nil {
+		log.Print("template parsing error: ", err)
+		return
+	}
+	if err := t.Execute(w, vars); err != nil {
+		log.Print("template executing error: ", err)
+	}
+}
+
+//This function serves the invoice or a stored file of an order
+func (s *server) adminFile(w http.ResponseWriter, r *http.Request, reportId string, file string) {
+	if file == "invoice.pdf" {
+		invoice, err := s.store.Artifact(r.Context(), reportId, storage.ArtifactInvoice)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Print(err.Error())
+			http.Error(w, "The invoice is unavailable right now", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", invoice.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=invoice-%s.pdf", reportId))
+		w.Write(invoice.Data)
+		return
+	}
+	name := strings.TrimPrefix(file, "files/")
+	if !adminFileName(name) {
+		http.NotFound(w, r)
+		return
+	}
+	data, info, err := s.blobs.Get(r.Context(), blob.ReportKey(reportId, name))
+	if errors.Is(err, blob.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "The file is unavailable right now", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s-%s", reportId, path.Base(name)))
+	w.Write(data)
+}
+
+//This function tells whether name is one of the report files of the admin page, other blobs are not served
+func adminFileName(name string) bool {
+	for _, fileName := range adminFileNames {
+		if fileName == name {
+			return true
+		}
+	}
+	return false
+}
+
+//This function splits /admin/orders/{reportId}/{file} into the report id and the file, empty for the order page
+func splitAdminPath(urlPath string) (string, string) {
+	rest := strings.TrimPrefix(urlPath, "/admin/orders/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return rest[:i], rest[i+1:]
+	}
+	return rest, ""
+}
+
+//This function returns a lookup of the organizations by id, the default organization for 0 and unknown ids
+func (s *server) organizationIndex(ctx context.Context) func(int64) orgs.Organization {
+	index := map[int64]orgs.Organization{}
+	all, err := s.store.Organizations(ctx)
+	if err != nil {
+		log.Print(err.Error())
+	}
+	for _, org := range all {
+		index[org.ID] = org
+	}
+	return func(id int64) orgs.Organization {
+		if org, ok := index[id]; ok {
+			return org
+		}
+		return s.defaultOrg
+	}
+}
+
+//This function returns what an order was charged, the price of its organization for orders from before prices were recorded
+func orderPrice(org orgs.Organization, record storage.OrderRecord) int {
+	if record.Price != 0 {
+		return record.Price
+	}
+	return org.Price(record.ReportType)
+}
+
+func formatAdminTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("01-02-2006 03:04 PM")
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/accounts"
+	"test/eagleview"
+	"test/orders"
+	"test/payment"
+	"test/postal"
+	"test/radiance"
+	"test/storage"
+)
+
+//The JSON API is versioned by path, breaking changes go to a new prefix
+const apiPrefix = "/api/v1/"
+
+//Body of POST /api/v1/orders
+type APIOrderRequest struct {
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	Email      string `json:"email"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	Zip        string `json:"zip"`
+	ReportType string `json:"reportType"`
+	//PaymentMethod is the id of the card tokenized with the payment provider, card numbers are not accepted
+	PaymentMethod string `json:"paymentMethod"`
+}
+
+type APIOrder struct {
+	ReportID  string    `json:"reportId"`
+	OrderID   int       `json:"orderId,omitempty"`
+	Status    string    `json:"status"`
+	Label     string    `json:"label"`
+	Detail    string    `json:"detail,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type APIRoofs struct {
+	ReportID string            `json:"reportId"`
+	Location radiance.Location `json:"location"`
+	Roofs    []radiance.Roof   `json:"roofs"`
+	Results  []ReportResult    `json:"results"`
+}
+
+type APIMonth struct {
+	Month  int     `json:"month"`
+	AC     float64 `json:"acMonthly"`
+	DC     float64 `json:"dcMonthly"`
+	POA    float64 `json:"poaMonthly"`
+	Solrad float64 `json:"solradMonthly"`
+}
+
+type APINREL struct {
+	ReportID       string     `json:"reportId"`
+	Street         string     `json:"street"`
+	City           string     `json:"city"`
+	State          string     `json:"state"`
+	Zip            string     `json:"zip"`
+	Azimuth        string     `json:"azimuth"`
+	Tilt           string     `json:"tilt"`
+	SolradAnnual   float64    `json:"solradAnnual"`
+	AcAnnual       float64    `json:"acAnnual"`
+	CapacityFactor float64    `json:"capacityFactor"`
+	Monthly        []APIMonth `json:"monthly"`
+}
+
+type APIFile struct {
+	FileType  string    `json:"fileType"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type apiError struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details,omitempty"`
+}
+
+//This function routes every /api/v1/ request, each one made by a signed in user
+func (s *server) api(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.apiUser(w, r)
+	if !ok {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "orders":
+		s.apiCreateOrder(w, r, user)
+	case len(parts) == 2 && parts[0] == "orders":
+		s.apiOrder(w, r, user, parts[1])
+	case len(parts) == 3 && parts[0] == "reports" && parts[2] == "roofs":
+		s.apiRoofs(w, r, user, parts[1])
+	case len(parts) == 3 && parts[0] == "reports" && parts[2] == "nrel":
+		s.apiNREL(w, r, user, parts[1])
+	case len(parts) == 3 && parts[0] == "reports" && parts[2] == "files":
+		s.apiFiles(w, r, user, parts[1])
+	default:
+		writeAPIError(w, http.StatusNotFound, "no such endpoint")
+	}
+}
+
+//This function authenticates an API request with HTTP basic auth or the session of the pages, writing the 401 when it cannot
+func (s *server) apiUser(w http.ResponseWriter, r *http.Request) (accounts.User, bool) {
+	email, password, basic := r.BasicAuth()
+	if !basic {
+		if user, ok := s.currentUser(r); ok {
+			return user, true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="Renulogix API"`)
+		writeAPIError(w, http.StatusUnauthorized, "authentication required")
+		return accounts.User{}, false
+	}
+	user, err := s.users.Authenticate(r.Context(), email, password)
+	if errors.Is(err, accounts.ErrInvalidCredentials) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Renulogix API"`)
+		writeAPIError(w, http.StatusUnauthorized, "invalid email or password")
+		return user, false
+	}
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "accounts are unavailable right now")
+		return user, false
+	}
+	return user, true
+}
+
+//This function places an order of the user with the same checks as the payment page
+func (s *server) apiCreateOrder(w http.ResponseWriter, r *http.Request, user accounts.User) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var body APIOrderRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	//Like the payment page, names and emails are stored lower cased and the address in its canonical form
+	billing := Address{
+		FirstName: strings.ToLower(strings.TrimSpace(body.FirstName)),
+		LastName:  strings.ToLower(strings.TrimSpace(body.LastName)),
+		Email:     strings.ToLower(strings.TrimSpace(body.Email)),
+		Street:    strings.ToLower(strings.TrimSpace(body.Street)),
+		City:      strings.ToLower(strings.TrimSpace(body.City)),
+		State:     strings.ToLower(strings.TrimSpace(body.State)),
+		Zip:       strings.ToLower(strings.TrimSpace(body.Zip)),
+		TypeRep:   strings.ToLower(strings.TrimSpace(body.ReportType)),
+	}
+	paymentMethod := strings.TrimSpace(body.PaymentMethod)
+
+	var problems []string
+	required := map[string]string{
+		"firstName": billing.FirstName, "lastName": billing.LastName, "email": billing.Email,
+	}
+	for _, name := range []string{"firstName", "lastName", "email"} {
+		if required[name] == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	if billing.TypeRep != "basic" && billing.TypeRep != "advanced" {
+		problems = append(problems, `reportType must be "basic" or "advanced"`)
+	}
+	billing, canonical, err := canonicalAddress(billing)
+	var invalidAddress *postal.ValidationError
+	if errors.As(err, &invalidAddress) {
+		for _, field := range []string{"street", "city", "state", "zip"} {
+			if problem, ok := invalidAddress.Fields[field]; ok {
+				problems = append(problems, field+": "+problem)
+			}
+		}
+	}
+	if paymentMethod == "" {
+		problems = append(problems, "paymentMethod is required")
+	}
+	if len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "invalid order", Details: problems})
+		return
+	}
+
+	existing, err := s.store.FindReportByAddress(r.Context(), canonical)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "orders are unavailable right now")
+		return
+	}
+	if existing != "" {
+		writeJSON(w, http.StatusConflict, apiError{Error: "a report was already ordered for this address", Details: []string{"reportId " + existing}})
+		return
+	}
+
+	cardToken, err := s.payments.PaymentMethod(r.Context(), paymentMethod)
+	if errors.Is(err, payment.ErrUnknownMethod) {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "invalid order", Details: []string{"paymentMethod: unknown payment method " + paymentMethod}})
+		return
+	}
+	if err == nil && cardToken.Validate(time.Now()) != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "invalid order", Details: []string{"paymentMethod: the card has expired"}})
+		return
+	}
+	var placed eagleview.OrderStats
+	if err == nil {
+		//Without an EagleView token the order cannot be placed, so the card is not authorized
+		var token eagleview.Token
+		token, err = s.tokens.Token(r.Context())
+		if err == nil {
+			placed, _, err = s.checkout(r.Context(), token, user, billing, cardToken)
+		}
+	}
+	//The API does not return the invoice, an order placed without one is still created
+	if errors.Is(err, errInvoice) {
+		log.Print(err.Error())
+		err = nil
+	}
+	var declined *payment.DeclinedError
+	if errors.As(err, &declined) {
+		writeJSON(w, http.StatusPaymentRequired, apiError{Error: "the card was declined", Details: []string{declined.Reason}})
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusBadGateway, "the order could not be placed")
+		return
+	}
+	reportId := strconv.Itoa(placed.ReportIds[0])
+	order, err := s.store.Order(r.Context(), reportId)
+	if err != nil {
+		order = orders.Order{ReportID: reportId, Status: orders.StatusPlaced, UpdatedAt: time.Now().UTC()}
+	}
+	response := apiOrder(order)
+	response.OrderID = placed.OrderID
+	w.Header().Set("Location", apiPrefix+"orders/"+reportId)
+	writeJSON(w, http.StatusCreated, response)
+}
+
+//This function returns the lifecycle state of an order
+func (s *server) apiOrder(w http.ResponseWriter, r *http.Request, user accounts.User, reportId string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	//Like the lookup page, only reports the user ordered are known, orderStatus would start tracking any id
+	owned, err := s.ownsReport(r.Context(), user, reportId)
+	if err == nil && !owned {
+		writeAPIError(w, http.StatusNotFound, "no order for report "+reportId)
+		return
+	}
+	var order orders.Order
+	if err == nil {
+		order, err = s.orderStatus(r.Context(), reportId)
+	}
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "the order status is unavailable right now")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiOrder(order))
+}
+
+//This function returns the Radiance roofs of a report with the rows shown on the advanced report
+func (s *server) apiRoofs(w http.ResponseWriter, r *http.Request, user accounts.User, reportId string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	data, ok := s.apiReportData(w, r, user, reportId)
+	if !ok {
+		return
+	}
+	model, results, _, err := s.reportResults(r.Context(), data)
+	if err != nil {
+		message, problems := modelProblem(err)
+		//API errors start in lower case like the other messages of the API
+		writeJSON(w, http.StatusBadGateway, apiError{Error: strings.ToLower(message[:1]) + message[1:], Details: problems})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIRoofs{ReportID: reportId, Location: model.Location, Roofs: model.Roofs, Results: results})
+}
+
+//This function returns the NREL result stored for a report
+func (s *server) apiNREL(w http.ResponseWriter, r *http.Request, user accounts.User, reportId string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	data, ok := s.apiReportData(w, r, user, reportId)
+	if !ok {
+		return
+	}
+	monthly, err := s.store.NRELMonthly(r.Context(), reportId)
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "the NREL result is unavailable right now")
+		return
+	}
+	response := APINREL{
+		ReportID:       data.ReportID,
+		Street:         data.Street,
+		City:           data.City,
+		State:          data.State,
+		Zip:            data.Zip,
+		Azimuth:        data.Azimuth,
+		Tilt:           data.Tilt,
+		SolradAnnual:   data.SolradAnnual,
+		AcAnnual:       data.AcAnnual,
+		CapacityFactor: data.CapacityFactor,
+		Monthly:        []APIMonth{},
+	}
+	for _, month := range monthly {
+		response.Monthly = append(response.Monthly, APIMonth{Month: month.Month, AC: month.AC, DC: month.DC, POA: month.POA, Solrad: month.Solrad})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+//This function lists the files EagleView has for a report
+func (s *server) apiFiles(w http.ResponseWriter, r *http.Request, user accounts.User, reportId string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if _, ok := s.apiReportData(w, r, user, reportId); !ok {
+		return
+	}
+	token, err := s.tokens.Token(r.Context())
+	if err != nil {
+		log.Print(err.Error())
+	}
+	var links []eagleview.Links
+	err = eagleview.Retry(r.Context(), s.tokens, token, func(token eagleview.Token) (err error) {
+		links, err = s.ev.FileLinks(r.Context(), token, reportId)
+		return err
+	})
+	var statusErr *eagleview.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		writeAPIError(w, http.StatusNotFound, "EagleView has no files for report "+reportId+" yet")
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusBadGateway, "the file list is not available from EagleView")
+		return
+	}
+	files := []APIFile{}
+	for _, link := range links {
+		files = append(files, APIFile{FileType: link.FileType, URL: link.Link, ExpiresAt: link.ExpireTimestamp})
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+//This function loads the stored report of the user, writing the error response when it cannot
+func (s *server) apiReportData(w http.ResponseWriter, r *http.Request, user accounts.User, reportId string) (storage.ReportData, bool) {
+	//Reports of other users answer like unknown reports
+	owned, err := s.ownsReport(r.Context(), user, reportId)
+	var data storage.ReportData
+	if err == nil && !owned {
+		err = storage.ErrNotFound
+	}
+	if err == nil {
+		data, err = s.reportData(r.Context(), reportId)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "no report "+reportId)
+		return data, false
+	}
+	if err != nil {
+		log.Print(err.Error())
+		writeAPIError(w, http.StatusInternalServerError, "the report is unavailable right now")
+		return data, false
+	}
+	return data, true
+}
+
+func apiOrder(order orders.Order) APIOrder {
+	return APIOrder{
+		ReportID:  order.ReportID,
+		Status:    string(order.Status),
+		Label:     order.Status.Label(),
+		Detail:    order.Detail,
+		UpdatedAt: order.UpdatedAt,
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeAPIError(w, http.StatusMethodNotAllowed, "use "+method)
+	return false
+}
+
+func writeAPIError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, apiError{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Print(err.Error())
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"test/accounts"
+	"test/storage"
+)
+
+//Name of the session cookie shared by both servers
+const sessionName = "cookie-name"
+
+//Values shown on the login, registration and password reset pages
+type AccountPageVariables struct {
+	Brand Branding
+	//Org is the slug of the organization the pages were opened for, empty for the default one
+	Org       string
+	NeedsCode bool
+	Mode      string
+	Title     string
+	Email     string
+	Token     string
+	Next      string
+	Error     string
+	Message   string
+}
+
+//This function adds the account pages to a server, home is where users land after signing in
+func (s *server) handleAccounts(mux *http.ServeMux, home string) {
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) { s.register(w, r, home) })
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) { s.login(w, r, home) })
+	mux.HandleFunc("/logout", s.logout)
+	mux.HandleFunc("/password/forgot", s.forgotPassword)
+	mux.HandleFunc("/password/reset", func(w http.ResponseWriter, r *http.Request) { s.resetPassword(w, r, home) })
+}
+
+//This function creates an account in the organization of the org parameter and signs the new user in
+func (s *server) register(w http.ResponseWriter, r *http.Request, home string) {
+	vars := s.accountPageVariables(r, "register", "Create an account")
+	vars.Next = safeNext(r.FormValue("next"), home)
+	org, found := s.requestOrganization(r)
+	vars.NeedsCode = org.SignupCode != ""
+	if !found {
+		vars.Error = "This organization does not exist, check the link you were given."
+		accountPage(w, vars)
+		return
+	}
+	if r.Method != http.MethodPost {
+		accountPage(w, vars)
+		return
+	}
+	vars.Email = strings.TrimSpace(r.FormValue("email"))
+	if !signupCodeMatches(org, r.FormValue("code")) {
+		vars.Error = "The signup code is not correct, ask " + org.Name + " for it."
+		accountPage(w, vars)
+		return
+	}
+	if r.FormValue("password") != r.FormValue("confirm") {
+		vars.Error = "The passwords do not match."
+		accountPage(w, vars)
+		return
+	}
+	user, err := s.users.Register(r.Context(), org.ID, vars.Email, r.FormValue("password"))
+	if err != nil {
+		vars.Error = accountError(err)
+		accountPage(w, vars)
+		return
+	}
+	s.signIn(w, r, user)
+	http.Redirect(w, r, vars.Next, http.StatusSeeOther)
+}
+
+//This function signs a user in with their email and password
+func (s *server) login(w http.ResponseWriter, r *http.Request, home string) {
+	vars := s.accountPageVariables(r, "login", "Log in")
+	vars.Next = safeNext(r.FormValue("next"), home)
+	if r.Method != http.MethodPost {
+		accountPage(w, vars)
+		return
+	}
+	vars.Email = strings.TrimSpace(r.FormValue("email"))
+	user, err := s.users.Authenticate(r.Context(), vars.Email, r.FormValue("password"))
+	if err != nil {
+		vars.Error = accountError(err)
+		accountPage(w, vars)
+		return
+	}
+	s.signIn(w, r, user)
+	http.Redirect(w, r, vars.Next, http.StatusSeeOther)
+}
+
+//This function ends the session
+func (s *server) logout(w http.ResponseWriter, r *http.Request) {
+	session, _ := cookieStore.Get(r, sessionName)
+	delete(session.Values, "userId")
+	delete(session.Values, "reportId")
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		log.Print(err.Error())
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+//This function emails a password reset link. The answer is the same whether or not the email has an account
+func (s *server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	vars := s.accountPageVariables(r, "forgot", "Reset your password")
+	if r.Method != http.MethodPost {
+		accountPage(w, vars)
+		return
+	}
+	vars.Email = strings.TrimSpace(r.FormValue("email"))
+	err := s.users.RequestReset(r.Context(), vars.Email, func(token string) string {
+		return s.cfg.Server.ReportURL + "/password/reset?token=" + url.QueryEscape(token)
+	})
+	if err != nil {
+		log.Printf("password reset for %s: %v", vars.Email, err)
+	}
+	vars.Message = fmt.Sprintf("If an account exists for %s, we sent it a link to reset the password. The link works for %s.", vars.Email, accounts.ValidFor(s.cfg.Accounts.ResetTTL.Duration))
+	accountPage(w, vars)
+}
+
+//This function sets a new password with the token of a reset link and signs the user in
+func (s *server) resetPassword(w http.ResponseWriter, r *http.Request, home string) {
+	vars := s.accountPageVariables(r, "reset", "Choose a new password")
+	vars.Token = r.FormValue("token")
+	if vars.Token == "" {
+		vars.Error = accountError(accounts.ErrInvalidToken)
+	}
+	if r.Method != http.MethodPost || vars.Token == "" {
+		accountPage(w, vars)
+		return
+	}
+	if r.FormValue("password") != r.FormValue("confirm") {
+		vars.Error = "The passwords do not match."
+		accountPage(w, vars)
+		return
+	}
+	user, err := s.users.ResetPassword(r.Context(), vars.Token, r.FormValue("password"))
+	if err != nil {
+		vars.Error = accountError(err)
+		accountPage(w, vars)
+		return
+	}
+	s.signIn(w, r, user)
+	http.Redirect(w, r, home, http.StatusSeeOther)
+}
+
+//This function returns the message shown for an account error
+func accountError(err error) string {
+	switch {
+	case errors.Is(err, accounts.ErrEmailTaken):
+		return "An account already exists for this email, log in instead."
+	case errors.Is(err, accounts.ErrInvalidEmail):
+		return "Enter a valid email address."
+	case errors.Is(err, accounts.ErrWeakPassword):
+		return fmt.Sprintf("Passwords need at least %d characters.", accounts.MinPasswordLength)
+	case errors.Is(err, accounts.ErrLongPassword):
+		return "Passwords can be at most 72 characters."
+	case errors.Is(err, accounts.ErrInvalidCredentials):
+		return "The email or password is incorrect."
+	case errors.Is(err, accounts.ErrInvalidToken):
+		return "This reset link is invalid or has expired, ask for a new one."
+	}
+	log.Print(err.Error())
+	return "Accounts are unavailable right now, please try again later."
+}
+
+//This function returns the values of an account page branded for the org parameter
+func (s *server) accountPageVariables(r *http.Request, mode string, title string) AccountPageVariables {
+	org, _ := s.requestOrganization(r)
+	vars := AccountPageVariables{Brand: brandOf(org), Mode: mode, Title: title}
+	if org.ID != s.defaultOrg.ID {
+		vars.Org = org.Slug
+	}
+	return vars
+}
+
+//This function displays the account page
+func accountPage(w http.ResponseWriter, vars AccountPageVariables) {
+	t, err := template.ParseFiles("html/account.html", "html/brand.html")
+	if err != nil {
+		log.Print("template parsing error: ", err)
+		return
+	}
+	if err := t.Execute(w, vars); err != nil {
+		log.Print("template executing error: ", err)
+	}
+}
+
+//This function remembers the user in the session, dropping the report of a previous user
+func (s *server) signIn(w http.ResponseWriter, r *http.Request, user accounts.User) {
+	session, _ := cookieStore.Get(r, sessionName)
+	session.Values["userId"] = user.ID
+	delete(session.Values, "reportId")
+	if err := session.Save(r, w); err != nil {
+		log.Print(err.Error())
+	}
+}
+
+//This function returns the signed in user of the request
+func (s *server) currentUser(r *http.Request) (accounts.User, bool) {
+	session, _ := cookieStore.Get(r, sessionName)
+	id, ok := session.Values["userId"].(int64)
+	if !ok {
+		return accounts.User{}, false
+	}
+	user, err := s.users.User(r.Context(), id)
+	if err != nil {
+		if !errors.Is(err, accounts.ErrNotFound) {
+			log.Print(err.Error())
+		}
+		return accounts.User{}, false
+	}
+	return user, true
+}
+
+//This function returns the signed in user, or sends the browser to the login page and returns false
+func (s *server) requireUser(w http.ResponseWriter, r *http.Request) (accounts.User, bool) {
+	user, ok := s.currentUser(r)
+	if ok {
+		return user, true
+	}
+	//A form cannot be resubmitted after the login, the user comes back to its page
+	next := r.URL.Path
+	if r.Method == http.MethodGet {
+		next = r.URL.RequestURI()
+	}
+	http.Redirect(w, r, "/login?next="+url.QueryEscape(next), http.StatusSeeOther)
+	return accounts.User{}, false
+}
+
+//This function tells whether user ordered the report. Reports without an order or ordered before accounts belong to nobody
+func (s *server) ownsReport(ctx context.Context, user accounts.User, reportId string) (bool, error) {
+	owner, err := s.store.ReportOwner(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return owner != 0 && owner == user.ID, nil
+}
+
+//This function only lets the login redirect to paths of the same server
+func safeNext(next string, home string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return home
+	}
+	return next
+}
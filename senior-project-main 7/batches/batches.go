@@ -0,0 +1,178 @@
+package batches
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no batch matches
+var ErrNotFound = errors.New("batches: batch not found")
+
+// ErrConflict is returned when the batch changed status concurrently
+var ErrConflict = errors.New("batches: batch status changed concurrently")
+
+// Status is where a batch is between its upload and its last order
+type Status string
+
+const (
+	// StatusPreview batches were uploaded and priced but not paid for yet
+	StatusPreview Status = "preview"
+	// StatusPlacing batches are paid for and their rows being ordered
+	StatusPlacing Status = "placing"
+	// StatusPlaced batches have every ready row ordered or failed
+	StatusPlaced Status = "placed"
+)
+
+// Label is the status as shown to customers
+func (s Status) Label() string {
+	switch s {
+	case StatusPreview:
+		return "Awaiting payment"
+	case StatusPlacing:
+		return "Placing orders"
+	case StatusPlaced:
+		return "Placed"
+	}
+	return string(s)
+}
+
+// RowStatus is what became of one row of the file
+type RowStatus string
+
+const (
+	// RowReady rows are valid and will be ordered when the batch is paid for
+	RowReady RowStatus = "ready"
+	// RowInvalid rows have an address or report type that cannot be ordered
+	RowInvalid RowStatus = "invalid"
+	// RowDuplicate rows are a property already ordered, earlier in the file
+	// or in OrderHistory
+	RowDuplicate RowStatus = "duplicate"
+	// RowPlacing rows are being ordered with EagleView. The status is saved
+	// before the order is sent, so a row found ordered when a batch is
+	// resumed is known to be the order of the batch itself.
+	RowPlacing RowStatus = "placing"
+	RowPlaced  RowStatus = "placed"
+	RowFailed  RowStatus = "failed"
+)
+
+// Label is the row status as shown to customers
+func (s RowStatus) Label() string {
+	switch s {
+	case RowReady:
+		return "Ready"
+	case RowInvalid:
+		return "Invalid"
+	case RowDuplicate:
+		return "Duplicate"
+	case RowPlacing:
+		return "Ordering"
+	case RowPlaced:
+		return "Ordered"
+	case RowFailed:
+		return "Failed"
+	}
+	return string(s)
+}
+
+// Batch is a file of addresses ordered together. Its orders share the batch
+// id and purchase order number at EagleView, and are paid with one card
+// payment.
+type Batch struct {
+	ID     int64
+	UserID int64
+	// OrgID is the organization the batch is priced and ordered for, 0 for
+	// the default one
+	OrgID int64
+	// Name is the name of the uploaded file
+	Name     string
+	PONumber string
+	Status   Status
+	// FirstName, LastName, Email and the billing address are those of the
+	// payment, empty until the batch is paid for
+	FirstName string
+	LastName  string
+	Email     string
+	Street    string
+	City      string
+	State     string
+	Zip       string
+	// PaymentID is the authorization of the total of the ready rows,
+	// CardBrand and CardLast4 tell the card it was made on
+	PaymentID string
+	CardBrand string
+	CardLast4 string
+	CreatedAt time.Time
+	Rows      []Row
+}
+
+// Row is one address of a batch
+type Row struct {
+	// Line is the line of the row in the file, the header being line 1
+	Line int
+	// Street, City, State and Zip are the canonical address of valid rows,
+	// and the address as written for invalid ones
+	Street      string
+	City        string
+	State       string
+	Zip         string
+	ReportType  string
+	ReferenceID string
+	ClaimNumber string
+	// Price is what the row costs in whole dollars, 0 unless it is ordered
+	Price  int
+	Status RowStatus
+	// Detail is the problem of an invalid row, the report a duplicate is
+	// already ordered as, or why a failed order failed
+	Detail   string
+	ReportID string
+}
+
+// Count returns how many rows have status
+func (b Batch) Count(status RowStatus) int {
+	count := 0
+	for _, row := range b.Rows {
+		if row.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Total returns the price in whole dollars of the rows with status
+func (b Batch) Total(status RowStatus) int {
+	total := 0
+	for _, row := range b.Rows {
+		if row.Status == status {
+			total += row.Price
+		}
+	}
+	return total
+}
+
+// Store persists batches with their rows
+type Store interface {
+	// CreateBatch records an uploaded batch with its rows, returning it with
+	// its id
+	CreateBatch(ctx context.Context, batch Batch) (Batch, error)
+	// Batch returns a batch with its rows in file order
+	Batch(ctx context.Context, id int64) (Batch, error)
+	// UserBatches returns the batches of a user without their rows, most
+	// recent first
+	UserBatches(ctx context.Context, userId int64) ([]Batch, error)
+	// PlacingBatches returns the ids of the batches left placing, such as
+	// those interrupted by a restart
+	PlacingBatches(ctx context.Context) ([]int64, error)
+	// StartBatch records the payment of a previewed batch and moves it to
+	// placing, failing with ErrConflict when it is no longer previewed
+	StartBatch(ctx context.Context, batch Batch) error
+	// ClaimBatch makes claim the only placer of a placing batch until the
+	// time until, failing with ErrConflict when the batch is no longer
+	// placing or another claim on it has not expired. The holder renews its
+	// claim by claiming again.
+	ClaimBatch(ctx context.Context, id int64, claim string, until time.Time) error
+	// FinishBatch moves a placing batch to placed
+	FinishBatch(ctx context.Context, id int64) error
+	// UpdateBatchRow records what became of a row
+	UpdateBatchRow(ctx context.Context, batchId int64, line int, status RowStatus, reportId string, detail string) error
+}
@@ -0,0 +1,152 @@
+package batches
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// columns maps the header names accepted for each field, compared in lower
+// case without spaces, dashes and underscores
+var columns = map[string]string{
+	"street":        "street",
+	"address":       "street",
+	"streetaddress": "street",
+	"address1":      "street",
+	"city":          "city",
+	"state":         "state",
+	"zip":           "zip",
+	"zipcode":       "zip",
+	"postalcode":    "zip",
+	"reporttype":    "reportType",
+	"type":          "reportType",
+	"referenceid":   "referenceId",
+	"reference":     "referenceId",
+	"claimnumber":   "claimNumber",
+	"claim":         "claimNumber",
+}
+
+// requiredColumns must be in the header of every file
+var requiredColumns = []string{"street", "city", "state", "zip"}
+
+// maxFieldLength bounds the reference id and claim number EagleView accepts
+const maxFieldLength = 100
+
+// maxLength bounds every field, longer ones are cut and the row invalid
+const maxLength = 255
+
+// FileError is a problem of the file as a whole, a sentence that can be
+// shown to the customer
+type FileError struct {
+	Problem string
+}
+
+func (e *FileError) Error() string {
+	return "batches: " + e.Problem
+}
+
+// ReadCSV reads the rows of a CSV file with a header line, at most maxRows of
+// them. Rows without a report type take defaultType. The addresses are
+// returned as written for the caller to validate, rows with an unknown report
+// type or an overlong reference are already invalid.
+func ReadCSV(r io.Reader, defaultType string, maxRows int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &FileError{Problem: "The file is empty."}
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		//Spreadsheets saving as UTF-8 start the file with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		name = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if field, ok := columns[name]; ok {
+			if _, seen := index[field]; !seen {
+				index[field] = i
+			}
+		}
+	}
+	var missing []string
+	for _, field := range requiredColumns {
+		if _, ok := index[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &FileError{Problem: fmt.Sprintf("The header line must name the street, city, state and zip columns, it has no %s.", strings.Join(missing, " or "))}
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		value := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if len(rows) == maxRows {
+			return nil, &FileError{Problem: fmt.Sprintf("The file has more than %d addresses, split it into several batches.", maxRows)}
+		}
+		line, _ := reader.FieldPos(0)
+		row := Row{
+			Line:        line,
+			Street:      value("street"),
+			City:        value("city"),
+			State:       value("state"),
+			Zip:         value("zip"),
+			ReportType:  strings.ToLower(value("reportType")),
+			ReferenceID: value("referenceId"),
+			ClaimNumber: value("claimNumber"),
+			Status:      RowReady,
+		}
+		if row.ReportType == "" {
+			row.ReportType = strings.ToLower(defaultType)
+		}
+		long := false
+		for _, field := range []*string{&row.Street, &row.City, &row.State, &row.Zip, &row.ReportType, &row.ReferenceID, &row.ClaimNumber} {
+			if len(*field) > maxLength {
+				*field, long = (*field)[:maxLength], true
+			}
+		}
+		switch {
+		case long:
+			row.Status, row.Detail = RowInvalid, fmt.Sprintf("A field is longer than %d characters", maxLength)
+		case row.ReportType != "basic" && row.ReportType != "advanced":
+			row.Status, row.Detail = RowInvalid, "The report type must be basic or advanced"
+		case len(row.ReferenceID) > maxFieldLength || len(row.ClaimNumber) > maxFieldLength:
+			row.Status, row.Detail = RowInvalid, fmt.Sprintf("The reference id and claim number must be at most %d characters", maxFieldLength)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, &FileError{Problem: "The file has no addresses below its header line."}
+	}
+	return rows, nil
+}
+
+// csvError turns a syntax error of the file into a FileError
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &FileError{Problem: fmt.Sprintf("Line %d of the file is not valid CSV: %v.", parseErr.StartLine, parseErr.Err)}
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package batches
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	longStreet := strings.Repeat("x", maxLength+10)
+	file := "\ufeffStreet Address, City,state,ZIP-Code,Report_Type,Reference ID,claim\n" +
+		"85 N Raymond Ave, Pasadena, CA, 91103, Advanced, REF-1, CL-1\n" +
+		",,,,,,\n" +
+		"1 Main St,Pasadena,CA,91103,,,\n" +
+		"2 Main St,Pasadena,CA,91103,premium,,\n" +
+		"3 Main St,Pasadena,CA,91103,basic," + strings.Repeat("r", maxFieldLength+1) + ",\n" +
+		"4 Main St,Pasadena\n" +
+		"\"5 Main St, Apt 2\",Pasadena,CA,91103\n" +
+		longStreet + ",Pasadena,CA,91103\n"
+	rows, err := ReadCSV(strings.NewReader(file), "Basic", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 2, Street: "85 N Raymond Ave", City: "Pasadena", State: "CA", Zip: "91103", ReportType: "advanced", ReferenceID: "REF-1", ClaimNumber: "CL-1", Status: RowReady},
+		{Line: 4, Street: "1 Main St", City: "Pasadena", State: "CA", Zip: "91103", ReportType: "basic", Status: RowReady},
+		{Line: 5, Street: "2 Main St", City: "Pasadena", State: "CA", Zip: "91103", ReportType: "premium", Status: RowInvalid},
+		{Line: 6, Street: "3 Main St", City: "Pasadena", State: "CA", Zip: "91103", ReportType: "basic", ReferenceID: strings.Repeat("r", maxFieldLength+1), Status: RowInvalid},
+		{Line: 7, Street: "4 Main St", City: "Pasadena", ReportType: "basic", Status: RowReady},
+		{Line: 8, Street: "5 Main St, Apt 2", City: "Pasadena", State: "CA", Zip: "91103", ReportType: "basic", Status: RowReady},
+		{Line: 9, Street: longStreet[:maxLength], City: "Pasadena", State: "CA", Zip: "91103", ReportType: "basic", Status: RowInvalid},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, row := range rows {
+		if (row.Detail == "") != (want[i].Status == RowReady) {
+			t.Errorf("row %d: status %s with detail %q", i, row.Status, row.Detail)
+		}
+		row.Detail = ""
+		if row != want[i] {
+			t.Errorf("row %d = %+v\nwant %+v", i, row, want[i])
+		}
+	}
+}
+
+func TestReadCSVFirstColumnWins(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader("address,street,city,state,zip\n1 Main St,2 Main St,Pasadena,CA,91103\n"), "basic", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Street != "1 Main St" {
+		t.Errorf("Street = %q, want the first street column", rows[0].Street)
+	}
+}
+
+func TestReadCSVFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		maxRows int
+		// problem is a part of the problem of the FileError
+		problem string
+	}{
+		{name: "empty", file: "", problem: "empty"},
+		{name: "header only", file: "street,city,state,zip\n", problem: "no addresses"},
+		{name: "blank rows only", file: "street,city,state,zip\n,,,\n\n", problem: "no addresses"},
+		{name: "missing columns", file: "street,city\n1 Main St,Pasadena\n", problem: "it has no state or zip"},
+		{name: "unknown columns", file: "where,town,region,code\n1 Main St,Pasadena,CA,91103\n", problem: "no street or city or state or zip"},
+		{name: "too many rows", file: "street,city,state,zip\n1 A St,B,CA,91103\n2 A St,B,CA,91103\n3 A St,B,CA,91103\n", maxRows: 2, problem: "more than 2 addresses"},
+		{name: "invalid csv", file: "street,city,state,zip\n\"1 Main St,Pasadena,CA,91103\n", problem: "Line 2 of the file is not valid CSV"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			maxRows := test.maxRows
+			if maxRows == 0 {
+				maxRows = 10
+			}
+			rows, err := ReadCSV(strings.NewReader(test.file), "basic", maxRows)
+			var fileErr *FileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("err = %v, rows = %+v, want a FileError", err, rows)
+			}
+			if !strings.Contains(fileErr.Problem, test.problem) {
+				t.Errorf("problem = %q, want it to contain %q", fileErr.Problem, test.problem)
+			}
+		})
+	}
+}
+
+func TestReadCSVMaxRows(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader("street,city,state,zip\n1 A St,B,CA,91103\n\n2 A St,B,CA,91103\n"), "basic", 2)
+	if err != nil || len(rows) != 2 {
+		t.Errorf("ReadCSV = %d rows, %v, want the 2 rows allowed", len(rows), err)
+	}
+}
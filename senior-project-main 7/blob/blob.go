@@ -0,0 +1,62 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get when no object is stored under the key
+var ErrNotFound = errors.New("blob: object not found")
+
+// Info describes a stored object
+type Info struct {
+	ContentType string
+	// Metadata holds short values kept with the object, such as the report
+	// id. Names are lower case.
+	Metadata map[string]string
+	// Size and Modified are filled in by Get and Stat
+	Size     int64
+	Modified time.Time
+}
+
+// Store keeps objects under slash separated keys such as
+// reports/1234/radiance.json
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, info Info) error
+	Get(ctx context.Context, key string) ([]byte, Info, error)
+	// Stat returns the Info of an object without reading its content
+	Stat(ctx context.Context, key string) (Info, error)
+	// Delete removes the object, a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// ReportKey names the object of a report, for example
+// ReportKey("1234", "images/north.jpg") is reports/1234/images/north.jpg
+func ReportKey(reportId string, name string) string {
+	return "reports/" + reportId + "/" + name
+}
+
+// ValidKey rejects keys that are empty, absolute or that step out of their
+// directory, so every backend maps a key to the same place
+func ValidKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("blob: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("blob: invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+func lowerMetadata(metadata map[string]string) map[string]string {
+	lowered := make(map[string]string, len(metadata))
+	for name, value := range metadata {
+		lowered[strings.ToLower(name)] = value
+	}
+	return lowered
+}
@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps objects as files under a directory: the content under data/
+// and the content type and metadata as JSON under meta/
+type Local struct {
+	dir string
+}
+
+type localMeta struct {
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewLocal stores objects under dir, creating it when missing
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("blob: %w", err)
+	}
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte, info Info) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(localMeta{ContentType: info.ContentType, Metadata: lowerMetadata(info.Metadata)})
+	if err != nil {
+		return err
+	}
+	//The metadata is written last so a reader never sees it without the content
+	if err := writeFile(l.dataPath(key), data); err != nil {
+		return fmt.Errorf("blob: %w", err)
+	}
+	if err := writeFile(l.metaPath(key), meta); err != nil {
+		return fmt.Errorf("blob: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, Info, error) {
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	data, err := os.ReadFile(l.dataPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("blob: %w", err)
+	}
+	info.Size = int64(len(data))
+	return data, info, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidKey(key); err != nil {
+		return Info{}, err
+	}
+	metaData, err := os.ReadFile(l.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("blob: %w", err)
+	}
+	var meta localMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		return Info{}, fmt.Errorf("blob: reading metadata of %s: %w", key, err)
+	}
+	stat, err := os.Stat(l.dataPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("blob: %w", err)
+	}
+	return Info{ContentType: meta.ContentType, Metadata: meta.Metadata, Size: stat.Size(), Modified: stat.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	for _, path := range []string{l.metaPath(key), l.dataPath(key)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("blob: %w", err)
+		}
+	}
+	return nil
+}
+
+func (l *Local) dataPath(key string) string {
+	return filepath.Join(l.dir, "data", filepath.FromSlash(key))
+}
+
+func (l *Local) metaPath(key string) string {
+	return filepath.Join(l.dir, "meta", filepath.FromSlash(key)+".json")
+}
+
+// writeFile replaces path atomically by renaming a temporary file over it
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
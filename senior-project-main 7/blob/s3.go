@@ -0,0 +1,263 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const metaPrefix = "X-Amz-Meta-"
+
+// S3Config locates an S3 compatible bucket. Objects are addressed path style
+// (endpoint/bucket/key), which AWS and MinIO both accept.
+type S3Config struct {
+	// Endpoint is the service URL, such as https://s3.us-east-1.amazonaws.com
+	// or http://localhost:9000 for MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Client defaults to a client with a 60 second timeout
+	Client *http.Client
+}
+
+// S3 stores objects in a bucket, signing each request with AWS Signature
+// Version 4
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 checks the configuration, it does not contact the bucket
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("blob: s3 endpoint must be an http(s) URL, got %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("blob: s3 needs a bucket, region, access key and secret key")
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &S3{cfg: cfg, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, info Info) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	if info.ContentType != "" {
+		req.Header.Set("Content-Type", info.ContentType)
+	}
+	for name, value := range lowerMetadata(info.Metadata) {
+		req.Header.Set(metaPrefix+name, value)
+	}
+	resp, err := s.do(req, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, Info, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, Info{}, err
+	}
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("blob: reading %s: %w", key, err)
+	}
+	info := headerInfo(resp.Header)
+	info.Size = int64(len(data))
+	return data, info, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	if err := ValidKey(key); err != nil {
+		return Info{}, err
+	}
+	req, err := s.request(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	info := headerInfo(resp.Header)
+	info.Size = resp.ContentLength
+	return info, nil
+}
+
+// headerInfo reads the content type, metadata and modification time of an
+// object from the headers of a GET or HEAD response
+func headerInfo(header http.Header) Info {
+	info := Info{ContentType: header.Get("Content-Type"), Metadata: map[string]string{}}
+	for name := range header {
+		if strings.HasPrefix(name, metaPrefix) {
+			info.Metadata[strings.ToLower(strings.TrimPrefix(name, metaPrefix))] = header.Get(name)
+		}
+	}
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.Modified = modified
+	}
+	return info
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) request(ctx context.Context, method string, key string, data []byte) (*http.Request, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.cfg.Bucket + "/" + key
+	target.RawPath = s.endpoint.Path + "/" + uriEncode(s.cfg.Bucket) + "/" + uriEncode(key)
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("blob: %w", err)
+	}
+	req.ContentLength = int64(len(data))
+	return req, nil
+}
+
+// do signs and sends req, turning error responses into errors
+func (s *S3) do(req *http.Request, payload []byte) (*http.Response, error) {
+	sign(req, payload, s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, s.now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("blob: s3 %s: %w", req.Method, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var s3Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(body, &s3Error) == nil && s3Error.Code != "" {
+		return nil, fmt.Errorf("blob: s3 %s %s: %s %s: %s", req.Method, req.URL.Path, resp.Status, s3Error.Code, s3Error.Message)
+	}
+	return nil, fmt.Errorf("blob: s3 %s %s: %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// sign adds the AWS Signature Version 4 headers to req. Every header already
+// set on req is signed along with the host.
+func sign(req *http.Request, payload []byte, region string, accessKey string, secretKey string, now time.Time) {
+	payloadSum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(payloadSum[:])
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if name == "Authorization" {
+			continue
+		}
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // object requests carry no query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestSum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent encodes everything but the unreserved characters and
+// slashes, as Signature Version 4 expects for object keys
+func uriEncode(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~', b == '/':
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"test/config"
+	"test/orgs"
+	"test/proposal"
+	"test/storage"
+)
+
+//Branding of the organization a page is shown for
+type Branding struct {
+	Slug         string
+	Name         string
+	PrimaryColor string
+	AccentColor  string
+}
+
+//This function returns the branding shown on the pages of an organization
+func brandOf(org orgs.Organization) Branding {
+	return Branding{Slug: org.Slug, Name: org.Name, PrimaryColor: org.PrimaryColor, AccentColor: org.AccentColor}
+}
+
+//This function returns the organization with id, the default organization for 0 or when it cannot be loaded
+func (s *server) organization(ctx context.Context, id int64) orgs.Organization {
+	if id == 0 || id == s.defaultOrg.ID {
+		return s.defaultOrg
+	}
+	org, err := s.store.Organization(ctx, id)
+	if err != nil {
+		log.Printf("loading organization %d: %v", id, err)
+		return s.defaultOrg
+	}
+	return org
+}
+
+//This function returns the organization named in the org parameter of the request, the default organization without one
+func (s *server) requestOrganization(r *http.Request) (orgs.Organization, bool) {
+	slug := strings.TrimSpace(r.FormValue("org"))
+	if slug == "" || slug == s.defaultOrg.Slug {
+		return s.defaultOrg, true
+	}
+	org, err := s.store.OrganizationBySlug(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, orgs.ErrNotFound) {
+			log.Print(err.Error())
+		}
+		return s.defaultOrg, false
+	}
+	return org, true
+}
+
+//This function returns the branding of the signed in user's organization, or of the org parameter for visitors
+func (s *server) userBranding(r *http.Request) Branding {
+	if user, ok := s.currentUser(r); ok {
+		return brandOf(s.organization(r.Context(), user.OrgID))
+	}
+	org, _ := s.requestOrganization(r)
+	return brandOf(org)
+}
+
+//This function serves the logo of an organization at /branding/{slug}/logo
+func (s *server) brandLogo(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/branding/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "logo" {
+		http.NotFound(w, r)
+		return
+	}
+	org := s.defaultOrg
+	if parts[0] != s.defaultOrg.Slug {
+		var err error
+		org, err = s.store.OrganizationBySlug(r.Context(), parts[0])
+		if errors.Is(err, orgs.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Print(err.Error())
+			http.Error(w, "The logo is unavailable right now", http.StatusInternalServerError)
+			return
+		}
+	}
+	file, err := os.Open(org.Logo)
+	if err != nil {
+		log.Printf("logo of organization %s: %v", org.Slug, err)
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+//This function returns the proposal branding of an organization, the logo is left out when its file is missing
+func proposalBranding(org orgs.Organization) proposal.Branding {
+	branding := proposal.Branding{
+		Name:         org.Name,
+		AddressLines: []string{org.Address, strings.TrimSpace(org.Address2 + " " + org.PostalCode)},
+		Color:        orgs.RGB(org.AccentColor),
+	}
+	if logo, err := ioutil.ReadFile(org.Logo); err == nil {
+		branding.Logo = logo
+	} else {
+		log.Print(err.Error())
+	}
+	return branding
+}
+
+//This function stores the organizations of the configuration, returning the default one
+func syncOrganizations(ctx context.Context, store storage.Store, cfg *config.Config) (orgs.Organization, error) {
+	for _, company := range cfg.Organizations {
+		if _, err := store.SaveOrganization(ctx, company.Organization()); err != nil {
+			return orgs.Organization{}, fmt.Errorf("saving organization %s: %w", company.Slug, err)
+		}
+	}
+	org, err := store.SaveOrganization(ctx, cfg.Company.Organization())
+	if err != nil {
+		return org, fmt.Errorf("saving organization %s: %w", cfg.Company.Slug, err)
+	}
+	return org, nil
+}
+
+//This function tells whether code is the signup code of the organization
+func signupCodeMatches(org orgs.Organization, code string) bool {
+	return org.SignupCode == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(code)), []byte(org.SignupCode)) == 1
+}
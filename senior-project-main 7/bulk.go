@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	generator "github.com/angelodlfrtr/go-invoice-generator"
+	"test/accounts"
+	"test/batches"
+	"test/orgs"
+	"test/payment"
+	"test/postal"
+	"test/storage"
+)
+
+//Largest CSV file accepted for a bulk order
+const maxBulkUploadBytes = 5 << 20
+
+//Longest purchase order number EagleView accepts
+const maxPONumberLength = 100
+
+//Seconds between refreshes of the page of a batch while its orders are placed
+const bulkRefreshSeconds = 5
+
+//Values shown on the bulk order upload page
+type BulkPageVariables struct {
+	Brand         Branding
+	BasicPrice    int
+	AdvancedPrice int
+	MaxRows       int
+	Batches       []BulkBatchRow
+	Form          url.Values
+	Error         string
+}
+
+//One batch of the list of the upload page
+type BulkBatchRow struct {
+	batches.Batch
+	Created string
+}
+
+//Values shown on the page of one batch, with the payment form while it awaits payment
+type BulkBatchPageVariables struct {
+	Brand      Branding
+	Batch      batches.Batch
+	Created    string
+	Ready      int
+	Duplicates int
+	Invalid    int
+	Placed     int
+	Failed     int
+	//Total is the price of the ready rows until the batch is paid for, then of the rows ordered
+	Total    int
+	Refresh  int
+	Card     string
+	CardForm CardForm
+	//Form holds the values sent when the payment form is shown again with errors, the card itself never reaches the server
+	Form          url.Values
+	AddressErrors map[string]string
+	Error         string
+}
+
+//This function adds the bulk order pages to the order server
+func (s *server) handleBulk(mux *http.ServeMux) {
+	mux.HandleFunc("/bulk", s.bulkUpload)
+	mux.HandleFunc("/bulk/", s.bulkBatch)
+}
+
+//This function lists the batches of the user and reads a new CSV file of addresses into a batch to preview
+func (s *server) bulkUpload(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
+	}
+	org := s.organization(r.Context(), user.OrgID)
+	page := BulkPageVariables{Brand: brandOf(org), BasicPrice: org.BasicPrice, AdvancedPrice: org.AdvancedPrice, MaxRows: s.cfg.Orders.BatchMaxRows}
+	list, err := s.store.UserBatches(r.Context(), user.ID)
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "Bulk orders are unavailable right now, please try again later", http.StatusInternalServerError)
+		return
+	}
+	for _, batch := range list {
+		page.Batches = append(page.Batches, BulkBatchRow{Batch: batch, Created: formatAdminTime(batch.CreatedAt)})
+	}
+	if r.Method != http.MethodPost {
+		bulkPage(w, "html/bulk.html", http.StatusOK, page)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkUploadBytes)
+	if err := r.ParseMultipartForm(maxBulkUploadBytes); err != nil {
+		page.Error = fmt.Sprintf("Upload a CSV file of at most %d MB.", maxBulkUploadBytes>>20)
+		bulkPage(w, "html/bulk.html", http.StatusRequestEntityTooLarge, page)
+		return
+	}
+	page.Form = url.Values{}
+	for _, field := range []string{"reportType", "poNumber"} {
+		page.Form.Set(field, r.FormValue(field))
+	}
+	reportType := strings.ToLower(r.FormValue("reportType"))
+	if reportType != "basic" {
+		reportType = "advanced"
+	}
+	poNumber := strings.TrimSpace(r.FormValue("poNumber"))
+	if len(poNumber) > maxPONumberLength {
+		page.Error = fmt.Sprintf("The purchase order number must be at most %d characters.", maxPONumberLength)
+		bulkPage(w, "html/bulk.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		page.Error = "Choose the CSV file of the addresses to order."
+		bulkPage(w, "html/bulk.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+	defer file.Close()
+
+	rows, err := batches.ReadCSV(file, reportType, s.cfg.Orders.BatchMaxRows)
+	var fileErr *batches.FileError
+	if errors.As(err, &fileErr) {
+		page.Error = fileErr.Problem
+		bulkPage(w, "html/bulk.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+	if err == nil {
+		err = s.previewRows(r.Context(), org.Price, rows)
+	}
+	var batch batches.Batch
+	if err == nil {
+		name := path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+		if len(name) > 255 {
+			name = name[:255]
+		}
+		batch, err = s.store.CreateBatch(r.Context(), batches.Batch{
+			UserID:   user.ID,
+			OrgID:    org.ID,
+			Name:     name,
+			PONumber: poNumber,
+			Status:   batches.StatusPreview,
+			Rows:     rows,
+		})
+	}
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "Bulk orders are unavailable right now, please try again later", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/bulk/"+strconv.FormatInt(batch.ID, 10), http.StatusSeeOther)
+}
+
+//This function validates the addresses of the ready rows and prices them. Rows repeating a property of an earlier row or of OrderHistory are duplicates.
+//The addresses of valid rows are put in their canonical form, as orders store them
+func (s *server) previewRows(ctx context.Context, price func(reportType string) int, rows []batches.Row) error {
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != batches.RowReady {
+			continue
+		}
+		address, canonical, err := canonicalAddress(Address{Street: row.Street, City: row.City, State: row.State, Zip: row.Zip})
+		var invalidAddress *postal.ValidationError
+		if errors.As(err, &invalidAddress) {
+			var problems []string
+			for _, field := range []string{"street", "city", "state", "zip"} {
+				if problem, ok := invalidAddress.Fields[field]; ok {
+					problems = append(problems, problem)
+				}
+			}
+			row.Status, row.Detail = batches.RowInvalid, strings.Join(problems, "; ")
+			continue
+		}
+		row.Street, row.City, row.State, row.Zip = address.Street, address.City, address.State, address.Zip
+		if line, ok := seen[canonical.Key()]; ok {
+			row.Status, row.Detail = batches.RowDuplicate, fmt.Sprintf("Same property as line %d", line)
+			continue
+		}
+		seen[canonical.Key()] = row.Line
+
+		report, err := s.store.FindReportByAddress(ctx, canonical)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		if report != "" {
+			row.Status, row.Detail = batches.RowDuplicate, "Already ordered as report "+report
+			continue
+		}
+		row.Price = price(row.ReportType)
+	}
+	return nil
+}
+
+//This function shows a batch of the user, takes the payment of a previewed batch and downloads the invoice of a placed one
+func (s *server) bulkBatch(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
+	}
+	//The path is /bulk/{id} or /bulk/{id}/invoice
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/bulk/"), "/", 2)
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	file := ""
+	if len(parts) == 2 {
+		file = parts[1]
+	}
+	if err != nil || (file != "" && file != "invoice") {
+		http.NotFound(w, r)
+		return
+	}
+	batch, err := s.store.Batch(r.Context(), id)
+	//Batches of other users answer as if they did not exist
+	if errors.Is(err, batches.ErrNotFound) || (err == nil && batch.UserID != user.ID) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "Bulk orders are unavailable right now, please try again later", http.StatusInternalServerError)
+		return
+	}
+	org := s.organization(r.Context(), batch.OrgID)
+
+	if file == "invoice" {
+		if batch.Status != batches.StatusPlaced || batch.Count(batches.RowPlaced) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		invoice, err := batchInvoice(org, batch)
+		if err != nil {
+			log.Printf("invoice of batch %d: %v", batch.ID, err)
+			http.Error(w, "The invoice is unavailable right now, please try again later", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=batch-%d-invoice.pdf", batch.ID))
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(invoice)
+		return
+	}
+
+	page := bulkBatchPageVariables(brandOf(org), batch)
+	page.CardForm = s.cardForm()
+	if r.Method != http.MethodPost || batch.Status != batches.StatusPreview {
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+		bulkPage(w, "html/bulkBatch.html", http.StatusOK, page)
+		return
+	}
+	if page.Ready == 0 {
+		page.Error = "No address of this batch can be ordered."
+		bulkPage(w, "html/bulkBatch.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+
+	//The rows carry their own report type, the payment form only gives the billing address and card
+	input := paymentFormInput(r)
+	billing := input.Billing
+	page.Form, page.AddressErrors = input.Form, input.AddressErrors
+	if input.PaymentMethod == "" {
+		page.Error = "Enter your card."
+	}
+	if page.Error != "" || page.AddressErrors != nil {
+		bulkPage(w, "html/bulkBatch.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+
+	//The total of the ready rows is authorized, only the rows EagleView accepts are captured once the batch is placed
+	cardToken, err := s.payments.PaymentMethod(r.Context(), input.PaymentMethod)
+	if errors.Is(err, payment.ErrUnknownMethod) {
+		page.Error = "Your card could not be read, please enter it again."
+		bulkPage(w, "html/bulkBatch.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+	var invalid *payment.ValidationError
+	if err == nil && errors.As(cardToken.Validate(time.Now()), &invalid) {
+		page.CardForm.Errors = invalid.Fields
+		bulkPage(w, "html/bulkBatch.html", http.StatusUnprocessableEntity, page)
+		return
+	}
+	if err == nil {
+		description := fmt.Sprintf("%s batch %d, %d reports", org.Name, batch.ID, page.Ready)
+		batch.PaymentID, err = s.payments.Authorize(r.Context(), cardToken, page.Total*100, description)
+	}
+	var declined *payment.DeclinedError
+	if errors.As(err, &declined) {
+		page.Error = declined.Reason + " Please use another card."
+		bulkPage(w, "html/bulkBatch.html", http.StatusPaymentRequired, page)
+		return
+	}
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "The payment could not be taken, please try again later", http.StatusBadGateway)
+		return
+	}
+	batch.FirstName, batch.LastName, batch.Email = billing.FirstName, billing.LastName, billing.Email
+	batch.Street, batch.City, batch.State, batch.Zip = billing.Street, billing.City, billing.State, billing.Zip
+	batch.CardBrand, batch.CardLast4 = string(cardToken.Brand), cardToken.Last4
+	if err := s.store.StartBatch(r.Context(), batch); err != nil {
+		//The batch was paid for by another request in the meantime, or cannot be started, this authorization is not needed
+		if refundErr := s.payments.Refund(r.Context(), batch.PaymentID); refundErr != nil {
+			log.Printf("releasing payment %s: %v", batch.PaymentID, refundErr)
+		}
+		if !errors.Is(err, batches.ErrConflict) {
+			log.Print(err.Error())
+			http.Error(w, "Bulk orders are unavailable right now, please try again later", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		go s.placeBatch(context.Background(), batch.ID)
+	}
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+//This function counts the rows of a batch for its page
+func bulkBatchPageVariables(brand Branding, batch batches.Batch) BulkBatchPageVariables {
+	page := BulkBatchPageVariables{
+		Brand:      brand,
+		Batch:      batch,
+		Created:    formatAdminTime(batch.CreatedAt),
+		Ready:      batch.Count(batches.RowReady) + batch.Count(batches.RowPlacing),
+		Duplicates: batch.Count(batches.RowDuplicate),
+		Invalid:    batch.Count(batches.RowInvalid),
+		Placed:     batch.Count(batches.RowPlaced),
+		Failed:     batch.Count(batches.RowFailed),
+	}
+	switch batch.Status {
+	case batches.StatusPreview:
+		page.Total = batch.Total(batches.RowReady)
+	case batches.StatusPlacing:
+		page.Total = batch.Total(batches.RowPlaced)
+		page.Refresh = bulkRefreshSeconds
+	default:
+		page.Total = batch.Total(batches.RowPlaced)
+	}
+	if batch.CardLast4 != "" {
+		page.Card = fmt.Sprintf("%s ending %s", payment.Brand(batch.CardBrand).Name(), batch.CardLast4)
+	}
+	return page
+}
+
+//This function shows a bulk order page with the HTTP status given
+func bulkPage(w http.ResponseWriter, file string, status int, page interface{}) {
+	t, err := template.ParseFiles(file, "html/brand.html")
+	if err != nil {
+		log.Print("template parsing error: ", err)
+		http.Error(w, "The page is unavailable right now", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if err := t.Execute(w, page); err != nil {
+		log.Print("template executing error: ", err)
+	}
+}
+
+//This function orders the ready rows of a paid batch one by one with EagleView, under the batch id and purchase order number of the batch
+//The payment is then captured for the rows ordered, or released when none was. Batches interrupted by a restart are placed again by resumeBatches.
+func (s *server) placeBatch(ctx context.Context, id int64) {
+	//Every instance resumes the placing batches when it starts, the claim keeps the rows of a batch from being ordered twice
+	claim, err := newBatchClaim()
+	if err == nil {
+		err = s.store.ClaimBatch(ctx, id, claim, time.Now().Add(batchClaimTTL))
+	}
+	if errors.Is(err, batches.ErrConflict) {
+		log.Printf("batch %d is placed by another process", id)
+		return
+	}
+	if err != nil {
+		log.Printf("claiming batch %d: %v", id, err)
+		return
+	}
+	batch, err := s.store.Batch(ctx, id)
+	if err != nil {
+		log.Printf("placing batch %d: %v", id, err)
+		return
+	}
+	org := s.organization(ctx, batch.OrgID)
+	buyer := accounts.User{ID: batch.UserID, OrgID: batch.OrgID}
+	charge := PaymentInfo{Card: payment.Token{Brand: payment.Brand(batch.CardBrand), Last4: batch.CardLast4}, PaymentID: batch.PaymentID}
+
+	//Once EagleView cannot give a token the rows left are failed, and only the rows already placed are captured
+	unreachable := false
+	for i := range batch.Rows {
+		row := &batch.Rows[i]
+		if row.Status != batches.RowReady && row.Status != batches.RowPlacing {
+			continue
+		}
+		if unreachable {
+			row.Status, row.Detail = batches.RowFailed, "EagleView could not be reached, it was not charged"
+			if err := s.store.UpdateBatchRow(ctx, batch.ID, row.Line, row.Status, row.ReportID, row.Detail); err != nil {
+				log.Printf("saving line %d of batch %d: %v", row.Line, batch.ID, err)
+			}
+			continue
+		}
+		//The claim is renewed with every row, a process that lost it leaves the batch to the one that took it over
+		if err := s.store.ClaimBatch(ctx, batch.ID, claim, time.Now().Add(batchClaimTTL)); err != nil {
+			log.Printf("placing batch %d stopped at line %d: %v", batch.ID, row.Line, err)
+			return
+		}
+		address := Address{
+			Street:    row.Street,
+			City:      row.City,
+			State:     row.State,
+			Zip:       row.Zip,
+			TypeRep:   row.ReportType,
+			Email:     batch.Email,
+			FirstName: batch.FirstName,
+			LastName:  batch.LastName,
+		}
+		//The property may have been ordered since the preview, or by this batch before a restart interrupted it
+		report := ""
+		if canonical, err := postal.Parse(row.Street, row.City, row.State, row.Zip); err == nil {
+			report, err = s.store.FindReportByAddress(ctx, canonical)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("placing line %d of batch %d: %v", row.Line, batch.ID, err)
+			}
+		}
+		switch {
+		case report != "" && row.Status == batches.RowPlacing:
+			//The row was being ordered when the batch stopped, the report is its own
+			row.Status, row.ReportID = batches.RowPlaced, report
+		case report != "":
+			row.Status, row.Detail = batches.RowDuplicate, "Already ordered as report "+report
+		default:
+			//The token is fetched for every row, the cached one may have expired since the batch started
+			token, err := s.tokens.Token(ctx)
+			if err != nil {
+				log.Printf("placing line %d of batch %d: %v", row.Line, batch.ID, err)
+				unreachable = true
+				row.Status, row.Detail = batches.RowFailed, "EagleView could not be reached, it was not charged"
+				break
+			}
+			//The row is marked before the order is sent, so a restart after EagleView took it does not count it as a duplicate
+			if err := s.store.UpdateBatchRow(ctx, batch.ID, row.Line, batches.RowPlacing, "", ""); err != nil {
+				log.Printf("saving line %d of batch %d: %v", row.Line, batch.ID, err)
+				row.Status, row.Detail = batches.RowFailed, "The order could not be saved, it was not charged"
+				break
+			}
+			order, err := s.order(ctx, token, buyer, org, address, charge, OrderReference{
+				BatchID:     strconv.FormatInt(batch.ID, 10),
+				PONumber:    batch.PONumber,
+				ClaimNumber: row.ClaimNumber,
+				ReferenceID: row.ReferenceID,
+			})
+			if err != nil {
+				log.Printf("placing line %d of batch %d: %v", row.Line, batch.ID, err)
+				row.Status, row.Detail = batches.RowFailed, "EagleView could not take the order, it was not charged"
+			} else {
+				row.Status, row.ReportID = batches.RowPlaced, strconv.Itoa(order.ReportIds[0])
+			}
+		}
+		if err := s.store.UpdateBatchRow(ctx, batch.ID, row.Line, row.Status, row.ReportID, row.Detail); err != nil {
+			log.Printf("saving line %d of batch %d: %v", row.Line, batch.ID, err)
+		}
+	}
+
+	//A failed capture is logged and the payment id shown on the batch page to settle it with the provider
+	if amount := batch.Total(batches.RowPlaced) * 100; amount > 0 {
+		if err := s.payments.Capture(ctx, batch.PaymentID, amount); err != nil {
+			log.Printf("capturing payment %s: %v", batch.PaymentID, err)
+		}
+	} else if err := s.payments.Refund(ctx, batch.PaymentID); err != nil {
+		log.Printf("releasing payment %s: %v", batch.PaymentID, err)
+	}
+	if err := s.store.FinishBatch(ctx, batch.ID); err != nil {
+		log.Printf("finishing batch %d: %v", batch.ID, err)
+	}
+}
+
+//How long a claim on a batch lasts without being renewed. It is renewed with every row, whose order takes at most a
+//token fetch and two EagleView calls.
+const batchClaimTTL = 10 * time.Minute
+
+//This function returns a random claim on a batch, one for every time the batch is placed
+func newBatchClaim() (string, error) {
+	claim := make([]byte, 16)
+	if _, err := rand.Read(claim); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(claim), nil
+}
+
+//This function places again the batches left placing when the application stopped. Batches still placed by another
+//instance are left to it.
+func (s *server) resumeBatches(ctx context.Context) {
+	ids, err := s.store.PlacingBatches(ctx)
+	if err != nil {
+		log.Printf("resuming batches: %v", err)
+		return
+	}
+	for _, id := range ids {
+		log.Printf("resuming batch %d", id)
+		s.placeBatch(ctx, id)
+	}
+}
+
+//This function builds the invoice of the rows of a batch ordered with EagleView, one line per report
+func batchInvoice(company orgs.Organization, batch batches.Batch) ([]byte, error) {
+	billing := Address{
+		FirstName: batch.FirstName,
+		LastName:  batch.LastName,
+		Street:    batch.Street,
+		City:      batch.City,
+		State:     batch.State,
+		Zip:       batch.Zip,
+	}
+	notes := fmt.Sprintf("Batch ID: %d", batch.ID)
+	if batch.PONumber != "" {
+		notes += "\nPO Number: " + batch.PONumber
+	}
+	var items []*generator.Item
+	for _, row := range batch.Rows {
+		if row.Status != batches.RowPlaced {
+			continue
+		}
+		items = append(items, &generator.Item{
+			Name:        fmt.Sprintf("%s report %s", row.ReportType, row.ReportID),
+			Description: fmt.Sprintf("%s, %s, %s %s", row.Street, row.City, row.State, row.Zip),
+			UnitCost:    strconv.Itoa(row.Price),
+			Quantity:    "1",
+		})
+	}
+	return invoiceDocument(company, billing, batch.CreatedAt, notes, items)
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options limit what the cache keeps. A zero value disables the limit.
+type Options struct {
+	// TTL is how long an entry is served after it was stored
+	TTL time.Duration
+	// MaxBytes bounds the size of the stored content, the least recently
+	// used entries are evicted first
+	MaxBytes int64
+}
+
+// Cache stores report artifacts on disk. Content is stored once per SHA-256
+// digest under objects/, and each key points at a digest from a small JSON
+// file under index/. The modification time of an index file is the last
+// time the entry was read.
+type Cache struct {
+	dir  string
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// refs counts the entries pointing at each digest
+	refs  map[string]int
+	sizes map[string]int64
+	total int64
+}
+
+type entry struct {
+	Key       string    `json:"key"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	accessed  time.Time
+}
+
+// Key names the artifact of fileType for a report
+func Key(reportId string, fileType string) string {
+	return reportId + "/" + fileType
+}
+
+// Open loads the cache kept in dir, creating the directory when missing
+func Open(dir string, opts Options) (*Cache, error) {
+	for _, sub := range []string{"objects", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("cache: %w", err)
+		}
+	}
+	c := &Cache{
+		dir:     dir,
+		opts:    opts,
+		now:     time.Now,
+		entries: map[string]*entry{},
+		refs:    map[string]int{},
+		sizes:   map[string]int64{},
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	files, err := os.ReadDir(filepath.Join(c.dir, "index"))
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(c.dir, "index", file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cache: %w", err)
+		}
+		var e entry
+		info, statErr := file.Info()
+		if err := json.Unmarshal(data, &e); err != nil || statErr != nil || e.Key == "" || len(e.Digest) != sha256.Size*2 {
+			//A half written index file is dropped rather than failing startup
+			log.Printf("cache: dropping unreadable index file %s", path)
+			os.Remove(path)
+			continue
+		}
+		if _, err := os.Stat(c.objectPath(e.Digest)); err != nil {
+			os.Remove(path)
+			continue
+		}
+		e.accessed = info.ModTime()
+		c.add(&e)
+	}
+	return nil
+}
+
+// Get returns the content stored under key
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e) {
+		c.remove(e)
+		ok = false
+	}
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	accessed := c.now()
+	e.accessed = accessed
+	digest := e.Digest
+	c.mu.Unlock()
+
+	data, err := os.ReadFile(c.objectPath(digest))
+	if err != nil {
+		log.Printf("cache: reading %s: %v", key, err)
+		c.drop(key, digest)
+		return nil, false
+	}
+	os.Chtimes(c.indexPath(key), accessed, accessed)
+	return data, true
+}
+
+// Put stores data under key, replacing what was stored before
+func (c *Cache) Put(key string, data []byte) error {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	now := c.now()
+	e := &entry{Key: key, Digest: digest, Size: int64(len(data)), CreatedAt: now, accessed: now}
+	index, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	//Content is written before the index points at it, so a crash leaves at worst an unused object
+	objectPath := c.objectPath(digest)
+	if _, err := os.Stat(objectPath); errors.Is(err, fs.ErrNotExist) {
+		if err := writeFile(objectPath, data); err != nil {
+			return fmt.Errorf("cache: %w", err)
+		}
+	}
+	if err := writeFile(c.indexPath(key), index); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	//The new entry is counted before the old one is released so shared content is kept
+	old := c.entries[key]
+	c.add(e)
+	if old != nil {
+		c.unref(old)
+	}
+	c.evict()
+	return nil
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+}
+
+// drop removes key after its content could not be read, unless a concurrent
+// Put already pointed it at other content
+func (c *Cache) drop(key string, digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && e.Digest == digest {
+		c.remove(e)
+	}
+}
+
+// Size returns the bytes of content currently stored
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+func (c *Cache) add(e *entry) {
+	c.entries[e.Key] = e
+	if c.refs[e.Digest] == 0 {
+		c.sizes[e.Digest] = e.Size
+		c.total += e.Size
+	}
+	c.refs[e.Digest]++
+}
+
+// remove drops an entry and its index file, and the content when no other entry uses it
+func (c *Cache) remove(e *entry) {
+	delete(c.entries, e.Key)
+	os.Remove(c.indexPath(e.Key))
+	c.unref(e)
+}
+
+// unref releases the content of an entry, deleting it when no entry uses it anymore
+func (c *Cache) unref(e *entry) {
+	c.refs[e.Digest]--
+	if c.refs[e.Digest] > 0 {
+		return
+	}
+	delete(c.refs, e.Digest)
+	c.total -= c.sizes[e.Digest]
+	delete(c.sizes, e.Digest)
+	os.Remove(c.objectPath(e.Digest))
+}
+
+func (c *Cache) expired(e *entry) bool {
+	return c.opts.TTL > 0 && c.now().Sub(e.CreatedAt) > c.opts.TTL
+}
+
+// evict removes expired entries, then the least recently used ones until the
+// content fits in MaxBytes
+func (c *Cache) evict() {
+	var live []*entry
+	for _, e := range c.entries {
+		if c.expired(e) {
+			c.remove(e)
+			continue
+		}
+		live = append(live, e)
+	}
+	if c.opts.MaxBytes <= 0 || c.total <= c.opts.MaxBytes {
+		return
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].accessed.Before(live[j].accessed) })
+	for _, e := range live {
+		if c.total <= c.opts.MaxBytes {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+func (c *Cache) objectPath(digest string) string {
+	return filepath.Join(c.dir, "objects", digest[:2], digest)
+}
+
+func (c *Cache) indexPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(sum[:])+".json")
+}
+
+// writeFile replaces path atomically by renaming a temporary file over it
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// clock is a settable time for the cache under test
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func openCache(t *testing.T, dir string, opts Options) (*Cache, *clock) {
+	t.Helper()
+	c, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := &clock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c.now = clk.Now
+	return c, clk
+}
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "no ttl", ttl: 0, elapsed: 1000 * time.Hour, want: true},
+		{name: "fresh", ttl: time.Hour, elapsed: 59 * time.Minute, want: true},
+		{name: "at the ttl", ttl: time.Hour, elapsed: time.Hour, want: true},
+		{name: "expired", ttl: time.Hour, elapsed: time.Hour + time.Second, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, clk := openCache(t, t.TempDir(), Options{TTL: test.ttl})
+			if err := c.Put("r/file", []byte("content")); err != nil {
+				t.Fatal(err)
+			}
+			clk.Advance(test.elapsed)
+			data, ok := c.Get("r/file")
+			if ok != test.want || (ok && string(data) != "content") {
+				t.Fatalf("Get = %q, %t, want %t", data, ok, test.want)
+			}
+			if !test.want {
+				if size := c.Size(); size != 0 {
+					t.Errorf("Size = %d after expiry, want 0", size)
+				}
+				if _, err := os.Stat(c.objectPath(digestOf("content"))); !os.IsNotExist(err) {
+					t.Errorf("expired content is still on disk: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	tests := []struct {
+		name string
+		// read are the keys read between storing a, b and c, in order
+		read    []string
+		evicted string
+	}{
+		{name: "oldest", evicted: "a"},
+		{name: "read keeps an entry", read: []string{"a"}, evicted: "b"},
+		{name: "last read wins", read: []string{"b", "a"}, evicted: "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, clk := openCache(t, t.TempDir(), Options{MaxBytes: 10})
+			for _, key := range []string{"a", "b"} {
+				clk.Advance(time.Second)
+				if err := c.Put(key, []byte(key+"aaa")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, key := range test.read {
+				clk.Advance(time.Second)
+				if _, ok := c.Get(key); !ok {
+					t.Fatalf("Get(%q) missed before eviction", key)
+				}
+			}
+			clk.Advance(time.Second)
+			if err := c.Put("c", []byte("caaa")); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"a", "b", "c"} {
+				_, ok := c.Get(key)
+				if ok == (key == test.evicted) {
+					t.Errorf("Get(%q) = %t, want %t", key, ok, key != test.evicted)
+				}
+			}
+			if size := c.Size(); size != 8 {
+				t.Errorf("Size = %d, want 8", size)
+			}
+		})
+	}
+}
+
+func TestCacheSharedContent(t *testing.T) {
+	c, _ := openCache(t, t.TempDir(), Options{})
+	for _, key := range []string{"one/file", "two/file"} {
+		if err := c.Put(key, []byte("same")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size := c.Size(); size != 4 {
+		t.Errorf("Size = %d, want the shared content counted once", size)
+	}
+	c.Delete("one/file")
+	if data, ok := c.Get("two/file"); !ok || string(data) != "same" {
+		t.Errorf("Get(two/file) = %q, %t after deleting the other key", data, ok)
+	}
+	if err := c.Put("two/file", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.objectPath(digestOf("same"))); !os.IsNotExist(err) {
+		t.Errorf("replaced content is still on disk: %v", err)
+	}
+}
+
+func TestCacheMissingContent(t *testing.T) {
+	c, _ := openCache(t, t.TempDir(), Options{})
+	if err := c.Put("r/file", []byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(c.objectPath(digestOf("content")))
+	if _, ok := c.Get("r/file"); ok {
+		t.Fatal("Get found content that is gone")
+	}
+	if _, ok := c.entries["r/file"]; ok {
+		t.Error("the entry of missing content was kept")
+	}
+}
+
+func TestCacheDropKeepsConcurrentPut(t *testing.T) {
+	c, _ := openCache(t, t.TempDir(), Options{})
+	if err := c.Put("r/file", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	// A Get that failed reading the old content must not remove the new one
+	c.drop("r/file", digestOf("old"))
+	if data, ok := c.Get("r/file"); !ok || string(data) != "new" {
+		t.Errorf("Get = %q, %t, want the content of the concurrent Put", data, ok)
+	}
+	c.drop("r/file", digestOf("new"))
+	if _, ok := c.Get("r/file"); ok {
+		t.Error("drop kept the entry it was called for")
+	}
+}
+
+func TestCacheReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := openCache(t, dir, Options{})
+	if err := c.Put("r/file", []byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index", "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, _ := openCache(t, dir, Options{})
+	if data, ok := reopened.Get("r/file"); !ok || string(data) != "content" {
+		t.Errorf("Get = %q, %t after reopening", data, ok)
+	}
+	if size := reopened.Size(); size != 7 {
+		t.Errorf("Size = %d, want 7", size)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index", "broken.json")); !os.IsNotExist(err) {
+		t.Errorf("the unreadable index file was kept: %v", err)
+	}
+}
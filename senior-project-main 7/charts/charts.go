@@ -0,0 +1,162 @@
+package charts
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// Months labels the twelve values of a PVWatts monthly series
+var Months = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// Chart describes a single series drawn as an inline SVG
+type Chart struct {
+	Title  string
+	Unit   string
+	Labels []string
+	Values []float64
+	// Color is any SVG fill/stroke color
+	Color  string
+	Width  int
+	Height int
+}
+
+const (
+	marginLeft   = 60
+	marginRight  = 20
+	marginTop    = 40
+	marginBottom = 40
+	gridLines    = 5
+)
+
+func (c Chart) withDefaults() Chart {
+	if c.Width <= 0 {
+		c.Width = 640
+	}
+	if c.Height <= 0 {
+		c.Height = 320
+	}
+	if c.Color == "" {
+		c.Color = "#89db52"
+	}
+	return c
+}
+
+// Bar renders the chart as vertical bars
+func Bar(c Chart) template.HTML {
+	c = c.withDefaults()
+	var b strings.Builder
+	scale := c.open(&b)
+	slot := c.plotWidth() / float64(len(c.Values))
+	for i, value := range c.Values {
+		barHeight := scale(0) - scale(value)
+		x := marginLeft + float64(i)*slot + slot*0.15
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s %s</title></rect>`,
+			x, scale(value), slot*0.7, math.Max(barHeight, 0), html.EscapeString(c.Color),
+			html.EscapeString(c.label(i)), formatValue(value), html.EscapeString(c.Unit))
+	}
+	return c.close(&b)
+}
+
+// Line renders the chart as a polyline with a marker per value
+func Line(c Chart) template.HTML {
+	c = c.withDefaults()
+	var b strings.Builder
+	scale := c.open(&b)
+	slot := c.plotWidth() / float64(len(c.Values))
+	points := make([]string, len(c.Values))
+	for i, value := range c.Values {
+		points[i] = fmt.Sprintf("%.1f,%.1f", marginLeft+float64(i)*slot+slot/2, scale(value))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(points, " "), html.EscapeString(c.Color))
+	for i, value := range c.Values {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %s %s</title></circle>`,
+			marginLeft+float64(i)*slot+slot/2, scale(value), html.EscapeString(c.Color),
+			html.EscapeString(c.label(i)), formatValue(value), html.EscapeString(c.Unit))
+	}
+	return c.close(&b)
+}
+
+func (c Chart) plotWidth() float64 {
+	return float64(c.Width - marginLeft - marginRight)
+}
+
+func (c Chart) plotHeight() float64 {
+	return float64(c.Height - marginTop - marginBottom)
+}
+
+func (c Chart) label(i int) string {
+	if i < len(c.Labels) {
+		return c.Labels[i]
+	}
+	return ""
+}
+
+// open writes the frame, title, grid and axis labels and returns the
+// function mapping a value to its y coordinate
+func (c Chart) open(b *strings.Builder) func(float64) float64 {
+	top := niceMax(c.Values)
+	scale := func(value float64) float64 {
+		return marginTop + c.plotHeight()*(1-math.Max(value, 0)/top)
+	}
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" class="chart" viewBox="0 0 %d %d" width="%d" height="%d" role="img" aria-label="%s">`,
+		c.Width, c.Height, c.Width, c.Height, html.EscapeString(c.Title))
+	fmt.Fprintf(b, `<text x="%d" y="22" font-size="16" font-family="Tahoma, Verdana, sans-serif">%s</text>`,
+		marginLeft, html.EscapeString(c.Title))
+
+	for i := 0; i <= gridLines; i++ {
+		value := top * float64(i) / gridLines
+		y := scale(value)
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`, marginLeft, y, c.Width-marginRight, y)
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" font-size="11" text-anchor="end" font-family="Tahoma, Verdana, sans-serif">%s</text>`,
+			marginLeft-6, y+4, formatValue(value))
+	}
+	fmt.Fprintf(b, `<text x="12" y="%.1f" font-size="11" font-family="Tahoma, Verdana, sans-serif" transform="rotate(-90 12 %.1f)" text-anchor="middle">%s</text>`,
+		marginTop+c.plotHeight()/2, marginTop+c.plotHeight()/2, html.EscapeString(c.Unit))
+
+	if len(c.Values) > 0 {
+		slot := c.plotWidth() / float64(len(c.Values))
+		for i := range c.Values {
+			fmt.Fprintf(b, `<text x="%.1f" y="%d" font-size="11" text-anchor="middle" font-family="Tahoma, Verdana, sans-serif">%s</text>`,
+				marginLeft+float64(i)*slot+slot/2, c.Height-marginBottom+16, html.EscapeString(c.label(i)))
+		}
+	}
+	return scale
+}
+
+func (c Chart) close(b *strings.Builder) template.HTML {
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`,
+		marginLeft, c.Height-marginBottom, c.Width-marginRight, c.Height-marginBottom)
+	b.WriteString("</svg>")
+	//Every piece of text written into the SVG above is escaped
+	return template.HTML(b.String())
+}
+
+// niceMax rounds the largest value up to 1, 2 or 5 times a power of ten so
+// the grid lines fall on readable numbers
+func niceMax(values []float64) float64 {
+	max := 0.0
+	for _, value := range values {
+		max = math.Max(max, value)
+	}
+	if max <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(max)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if step*magnitude >= max {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatValue(value float64) string {
+	if value >= 100 || value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.1f", value)
+}
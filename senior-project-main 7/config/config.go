@@ -0,0 +1,674 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"test/orgs"
+)
+
+// Config is every setting the application reads at startup
+type Config struct {
+	Server     Server     `json:"server"`
+	Session    Session    `json:"session"`
+	Database   Database   `json:"database"`
+	EagleView  EagleView  `json:"eagleview"`
+	NREL       NREL       `json:"nrel"`
+	Orders     Orders     `json:"orders"`
+	Cache      Cache      `json:"cache"`
+	Blob       Blob       `json:"blob"`
+	Mail       Mail       `json:"mail"`
+	Payment    Payment    `json:"payment"`
+	Accounts   Accounts   `json:"accounts"`
+	Production Production `json:"production"`
+	Sizing     Sizing     `json:"sizing"`
+	Finance    Finance    `json:"finance"`
+	Company    Company    `json:"company"`
+	// Organizations are the partner installers ordering under their own
+	// brand, Company is the default organization
+	Organizations []Company `json:"organizations"`
+}
+
+type Server struct {
+	// ReportAddr serves the report lookup and display pages
+	ReportAddr string `json:"report_addr"`
+	// OrderAddr serves the payment page
+	OrderAddr string `json:"order_addr"`
+	// ReportURL is the public URL of ReportAddr, used for redirects between the two servers
+	ReportURL string `json:"report_url"`
+}
+
+type Session struct {
+	Key string `json:"key"`
+}
+
+type Database struct {
+	// Driver is "mysql", or "sqlite" to run offline with a local file
+	Driver string `json:"driver"`
+	// DSN is the MySQL data source name, or the SQLite file path
+	DSN string `json:"dsn"`
+}
+
+type EagleView struct {
+	BaseURL         string   `json:"base_url"`
+	IntegrationsURL string   `json:"integrations_url"`
+	SourceID        string   `json:"source_id"`
+	ClientSecret    string   `json:"client_secret"`
+	Username        string   `json:"username"`
+	Password        string   `json:"password"`
+	Timeout         Duration `json:"timeout"`
+}
+
+type NREL struct {
+	BaseURL string   `json:"base_url"`
+	APIKey  string   `json:"api_key"`
+	Timeout Duration `json:"timeout"`
+}
+
+type Orders struct {
+	// PollInterval is how often unfinished orders are checked with EagleView
+	PollInterval Duration `json:"poll_interval"`
+	// BatchMaxRows is the most addresses a bulk order file may hold
+	BatchMaxRows int `json:"batch_max_rows"`
+}
+
+// Cache keeps the files of finished reports on disk so repeat views do not
+// download them from EagleView again
+type Cache struct {
+	Dir string   `json:"dir"`
+	TTL Duration `json:"ttl"`
+	// MaxSizeMB bounds the disk space used, least recently viewed files go first
+	MaxSizeMB int `json:"max_size_mb"`
+}
+
+// Blob keeps the Radiance models, report PDFs and images of finished orders
+// where every instance of the application can read them
+type Blob struct {
+	// Driver is "local" to keep the files under Dir, or "s3" for an S3
+	// compatible bucket such as MinIO
+	Driver string `json:"driver"`
+	Dir    string `json:"dir"`
+	S3     S3     `json:"s3"`
+}
+
+type S3 struct {
+	// Endpoint is the service URL, for example http://localhost:9000 for MinIO
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// Mail sends the emails of the application, such as password resets and
+// order notifications
+type Mail struct {
+	// Transport is "smtp", "log" to write the emails to the application log,
+	// or "file" to write them as .eml files under Dir
+	Transport string `json:"transport"`
+	From      string `json:"from"`
+	Dir       string `json:"dir"`
+	SMTP      SMTP   `json:"smtp"`
+}
+
+type SMTP struct {
+	// Addr is the host:port of the server, localhost:1025 for MailHog
+	Addr     string `json:"addr"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Payment charges the orders. Cards are tokenized in the browser, only the
+// id of the payment method reaches the application.
+type Payment struct {
+	// Provider is "stripe", or "fake" to accept test cards without charging
+	// them
+	Provider string `json:"provider"`
+	Stripe   Stripe `json:"stripe"`
+}
+
+type Stripe struct {
+	BaseURL   string `json:"base_url"`
+	SecretKey string `json:"secret_key"`
+	// PublishableKey is given to Stripe.js on the payment pages to create
+	// the payment method of the card
+	PublishableKey string   `json:"publishable_key"`
+	Timeout        Duration `json:"timeout"`
+}
+
+type Accounts struct {
+	// ResetTTL is how long a password reset link can be used
+	ResetTTL Duration `json:"reset_ttl"`
+}
+
+// Production holds the assumptions of the per roof facet PVWatts estimates
+type Production struct {
+	// UsableAreaFraction is the share of a facet that can hold modules
+	UsableAreaFraction float64 `json:"usable_area_fraction"`
+	// ModuleKwPerSqM is the nameplate DC capacity per square meter of modules
+	ModuleKwPerSqM float64 `json:"module_kw_per_sq_m"`
+	Losses         float64 `json:"losses"`
+	ArrayType      int     `json:"array_type"`
+	ModuleType     int     `json:"module_type"`
+}
+
+// Sizing holds the module catalog and the layout rules of the system
+// recommendation
+type Sizing struct {
+	Modules []Module `json:"modules"`
+	// Module names the catalog entry used for layouts
+	Module string `json:"module"`
+	// EdgeSetbackFt is kept clear along the eaves and rakes of a facet
+	EdgeSetbackFt float64 `json:"edge_setback_ft"`
+	// RidgeSetbackFt is kept clear below the ridge for fire access
+	RidgeSetbackFt float64 `json:"ridge_setback_ft"`
+	// MinTSRF skips facets with a lower total solar resource fraction, 0 to 1
+	MinTSRF float64 `json:"min_tsrf"`
+}
+
+// Module is one PV module of the catalog, dimensions in meters
+type Module struct {
+	Name   string  `json:"name"`
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+	Watts  float64 `json:"watts"`
+}
+
+// SelectedModule returns the catalog entry named by Module
+func (s Sizing) SelectedModule() (Module, bool) {
+	for _, module := range s.Modules {
+		if module.Name == s.Module {
+			return module, true
+		}
+	}
+	return Module{}, false
+}
+
+// Finance holds the economic assumptions of the savings analysis. Rates are
+// percentages per year.
+type Finance struct {
+	// UtilityRate is the first year price of grid electricity in $/kWh
+	UtilityRate    float64 `json:"utility_rate"`
+	RateEscalation float64 `json:"rate_escalation"`
+	// CostPerWatt is the installed system price in $ per W DC
+	CostPerWatt float64 `json:"cost_per_watt"`
+	Degradation float64 `json:"degradation"`
+	// IncentivePercent is the share of the price returned, such as the federal tax credit
+	IncentivePercent float64 `json:"incentive_percent"`
+	// IncentiveAmount is a fixed rebate in $
+	IncentiveAmount float64 `json:"incentive_amount"`
+	DiscountRate    float64 `json:"discount_rate"`
+	Years           int     `json:"years"`
+}
+
+// Company is an organization: the seller printed on invoices and proposals,
+// the branding of its pages and its prices
+type Company struct {
+	Slug       string `json:"slug"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Address2   string `json:"address2"`
+	PostalCode string `json:"postal_code"`
+	Logo       string `json:"logo"`
+	// PrimaryColor and AccentColor are written #rrggbb
+	PrimaryColor string `json:"primary_color"`
+	AccentColor  string `json:"accent_color"`
+	// BasicPrice and AdvancedPrice are in whole dollars
+	BasicPrice    int `json:"basic_price"`
+	AdvancedPrice int `json:"advanced_price"`
+	// SignupCode is asked when registering with the organization, none when empty
+	SignupCode string `json:"signup_code"`
+}
+
+// Organization returns the company as a tenant
+func (c Company) Organization() orgs.Organization {
+	return orgs.Organization{
+		Slug:          c.Slug,
+		Name:          c.Name,
+		Address:       c.Address,
+		Address2:      c.Address2,
+		PostalCode:    c.PostalCode,
+		Logo:          c.Logo,
+		PrimaryColor:  c.PrimaryColor,
+		AccentColor:   c.AccentColor,
+		BasicPrice:    c.BasicPrice,
+		AdvancedPrice: c.AdvancedPrice,
+		SignupCode:    c.SignupCode,
+	}
+}
+
+// Duration is a time.Duration written as "30s" or "2m" in the config file
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default returns the settings used when neither the file nor the
+// environment provides a value. Secrets have no default.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			ReportAddr: ":9090",
+			OrderAddr:  ":8888",
+			ReportURL:  "http://localhost:9090",
+		},
+		Database: Database{
+			Driver: "mysql",
+		},
+		EagleView: EagleView{
+			BaseURL:         "https://webservices.eagleview.com",
+			IntegrationsURL: "https://webservices-integrations.eagleview.com",
+			Timeout:         Duration{60 * time.Second},
+		},
+		NREL: NREL{
+			BaseURL: "https://developer.nrel.gov/api/pvwatts/v6.json",
+			Timeout: Duration{30 * time.Second},
+		},
+		Orders: Orders{
+			PollInterval: Duration{5 * time.Minute},
+			BatchMaxRows: 500,
+		},
+		Cache: Cache{
+			Dir:       "artifact-cache",
+			TTL:       Duration{30 * 24 * time.Hour},
+			MaxSizeMB: 1024,
+		},
+		Blob: Blob{
+			Driver: "local",
+			Dir:    "blobs",
+			S3: S3{
+				Region: "us-east-1",
+			},
+		},
+		Mail: Mail{
+			Transport: "log",
+			From:      "Renulogix <no-reply@renulogix.com>",
+			Dir:       "mail-outbox",
+			SMTP: SMTP{
+				Addr: "localhost:1025",
+			},
+		},
+		Payment: Payment{
+			Provider: "fake",
+			Stripe: Stripe{
+				BaseURL: "https://api.stripe.com",
+				Timeout: Duration{30 * time.Second},
+			},
+		},
+		Accounts: Accounts{
+			ResetTTL: Duration{time.Hour},
+		},
+		Production: Production{
+			UsableAreaFraction: 0.7,
+			ModuleKwPerSqM:     0.19,
+			Losses:             14,
+			ArrayType:          1,
+			ModuleType:         1,
+		},
+		Sizing: Sizing{
+			Modules: []Module{
+				{Name: "Standard 370W", Length: 1.690, Width: 1.046, Watts: 370},
+				{Name: "Standard 400W", Length: 1.755, Width: 1.038, Watts: 400},
+				{Name: "High Efficiency 430W", Length: 1.722, Width: 1.134, Watts: 430},
+			},
+			Module:         "Standard 400W",
+			EdgeSetbackFt:  1.5,
+			RidgeSetbackFt: 3,
+			MinTSRF:        0.75,
+		},
+		Finance: Finance{
+			UtilityRate:      0.15,
+			RateEscalation:   2.5,
+			CostPerWatt:      3,
+			Degradation:      0.5,
+			IncentivePercent: 30,
+			DiscountRate:     5,
+			Years:            25,
+		},
+		Company: Company{
+			Slug:          "renulogix",
+			Name:          "Renulogix",
+			Address:       "85 N Raymond Ave",
+			Address2:      "Pasadena, CA",
+			PostalCode:    "91103",
+			Logo:          "./Pics/RenuLogix-Logo.png",
+			PrimaryColor:  "#89db52",
+			AccentColor:   "#4c9900",
+			BasicPrice:    75,
+			AdvancedPrice: 100,
+		},
+	}
+}
+
+// Load reads the defaults, then the JSON file at path (skipped when path is
+// empty), then the environment overrides, and validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("config: parsing %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envVars maps each environment override to the field it replaces
+func (c *Config) envVars() map[string]interface{} {
+	return map[string]interface{}{
+		"SERVER_REPORT_ADDR":             &c.Server.ReportAddr,
+		"SERVER_ORDER_ADDR":              &c.Server.OrderAddr,
+		"SERVER_REPORT_URL":              &c.Server.ReportURL,
+		"SESSION_KEY":                    &c.Session.Key,
+		"DATABASE_DRIVER":                &c.Database.Driver,
+		"DATABASE_DSN":                   &c.Database.DSN,
+		"EAGLEVIEW_BASE_URL":             &c.EagleView.BaseURL,
+		"EAGLEVIEW_INTEGRATIONS_URL":     &c.EagleView.IntegrationsURL,
+		"EAGLEVIEW_SOURCE_ID":            &c.EagleView.SourceID,
+		"EAGLEVIEW_CLIENT_SECRET":        &c.EagleView.ClientSecret,
+		"EAGLEVIEW_USERNAME":             &c.EagleView.Username,
+		"EAGLEVIEW_PASSWORD":             &c.EagleView.Password,
+		"EAGLEVIEW_TIMEOUT":              &c.EagleView.Timeout,
+		"NREL_BASE_URL":                  &c.NREL.BaseURL,
+		"NREL_API_KEY":                   &c.NREL.APIKey,
+		"NREL_TIMEOUT":                   &c.NREL.Timeout,
+		"ORDERS_POLL_INTERVAL":           &c.Orders.PollInterval,
+		"ORDERS_BATCH_MAX_ROWS":          &c.Orders.BatchMaxRows,
+		"CACHE_DIR":                      &c.Cache.Dir,
+		"CACHE_TTL":                      &c.Cache.TTL,
+		"CACHE_MAX_SIZE_MB":              &c.Cache.MaxSizeMB,
+		"BLOB_DRIVER":                    &c.Blob.Driver,
+		"BLOB_DIR":                       &c.Blob.Dir,
+		"BLOB_S3_ENDPOINT":               &c.Blob.S3.Endpoint,
+		"BLOB_S3_REGION":                 &c.Blob.S3.Region,
+		"BLOB_S3_BUCKET":                 &c.Blob.S3.Bucket,
+		"BLOB_S3_ACCESS_KEY":             &c.Blob.S3.AccessKey,
+		"BLOB_S3_SECRET_KEY":             &c.Blob.S3.SecretKey,
+		"MAIL_TRANSPORT":                 &c.Mail.Transport,
+		"MAIL_FROM":                      &c.Mail.From,
+		"MAIL_DIR":                       &c.Mail.Dir,
+		"MAIL_SMTP_ADDR":                 &c.Mail.SMTP.Addr,
+		"MAIL_SMTP_USERNAME":             &c.Mail.SMTP.Username,
+		"MAIL_SMTP_PASSWORD":             &c.Mail.SMTP.Password,
+		"PAYMENT_PROVIDER":               &c.Payment.Provider,
+		"PAYMENT_STRIPE_BASE_URL":        &c.Payment.Stripe.BaseURL,
+		"PAYMENT_STRIPE_SECRET_KEY":      &c.Payment.Stripe.SecretKey,
+		"PAYMENT_STRIPE_PUBLISHABLE_KEY": &c.Payment.Stripe.PublishableKey,
+		"PAYMENT_STRIPE_TIMEOUT":         &c.Payment.Stripe.Timeout,
+		"ACCOUNTS_RESET_TTL":             &c.Accounts.ResetTTL,
+		"SIZING_MODULE":                  &c.Sizing.Module,
+		"SIZING_EDGE_SETBACK_FT":         &c.Sizing.EdgeSetbackFt,
+		"SIZING_RIDGE_SETBACK_FT":        &c.Sizing.RidgeSetbackFt,
+		"SIZING_MIN_TSRF":                &c.Sizing.MinTSRF,
+		"FINANCE_UTILITY_RATE":           &c.Finance.UtilityRate,
+		"FINANCE_RATE_ESCALATION":        &c.Finance.RateEscalation,
+		"FINANCE_COST_PER_WATT":          &c.Finance.CostPerWatt,
+		"FINANCE_DEGRADATION":            &c.Finance.Degradation,
+		"FINANCE_INCENTIVE_PERCENT":      &c.Finance.IncentivePercent,
+		"FINANCE_INCENTIVE_AMOUNT":       &c.Finance.IncentiveAmount,
+		"FINANCE_DISCOUNT_RATE":          &c.Finance.DiscountRate,
+		"FINANCE_YEARS":                  &c.Finance.Years,
+		"COMPANY_NAME":                   &c.Company.Name,
+		"COMPANY_ADDRESS":                &c.Company.Address,
+		"COMPANY_ADDRESS2":               &c.Company.Address2,
+		"COMPANY_POSTAL_CODE":            &c.Company.PostalCode,
+		"COMPANY_LOGO":                   &c.Company.Logo,
+		"COMPANY_SLUG":                   &c.Company.Slug,
+		"COMPANY_PRIMARY_COLOR":          &c.Company.PrimaryColor,
+		"COMPANY_ACCENT_COLOR":           &c.Company.AccentColor,
+		"COMPANY_BASIC_PRICE":            &c.Company.BasicPrice,
+		"COMPANY_ADVANCED_PRICE":         &c.Company.AdvancedPrice,
+		"COMPANY_SIGNUP_CODE":            &c.Company.SignupCode,
+	}
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for name, field := range c.envVars() {
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		switch f := field.(type) {
+		case *string:
+			*f = value
+		case *Duration:
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			f.Duration = parsed
+		case *int:
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			*f = parsed
+		case *float64:
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			*f = parsed
+		}
+	}
+	return nil
+}
+
+// ValidationError lists every problem found by Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "config: invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate reports all missing or malformed settings at once
+func (c *Config) Validate() error {
+	var problems []string
+	required := func(value string, name string, env string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("%s is required (or set %s)", name, env))
+		}
+	}
+	httpURL := func(value string, name string) {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s must be an http(s) URL, got %q", name, value))
+		}
+	}
+
+	required(c.Server.ReportAddr, "server.report_addr", "SERVER_REPORT_ADDR")
+	required(c.Server.OrderAddr, "server.order_addr", "SERVER_ORDER_ADDR")
+	httpURL(c.Server.ReportURL, "server.report_url")
+	required(c.Session.Key, "session.key", "SESSION_KEY")
+
+	switch c.Database.Driver {
+	case "mysql", "sqlite":
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver %q is not supported", c.Database.Driver))
+	}
+	required(c.Database.DSN, "database.dsn", "DATABASE_DSN")
+
+	httpURL(c.EagleView.BaseURL, "eagleview.base_url")
+	httpURL(c.EagleView.IntegrationsURL, "eagleview.integrations_url")
+	required(c.EagleView.SourceID, "eagleview.source_id", "EAGLEVIEW_SOURCE_ID")
+	required(c.EagleView.ClientSecret, "eagleview.client_secret", "EAGLEVIEW_CLIENT_SECRET")
+	required(c.EagleView.Username, "eagleview.username", "EAGLEVIEW_USERNAME")
+	required(c.EagleView.Password, "eagleview.password", "EAGLEVIEW_PASSWORD")
+	if c.EagleView.Timeout.Duration <= 0 {
+		problems = append(problems, "eagleview.timeout must be positive")
+	}
+
+	httpURL(c.NREL.BaseURL, "nrel.base_url")
+	required(c.NREL.APIKey, "nrel.api_key", "NREL_API_KEY")
+	if c.NREL.Timeout.Duration <= 0 {
+		problems = append(problems, "nrel.timeout must be positive")
+	}
+
+	if c.Orders.PollInterval.Duration <= 0 {
+		problems = append(problems, "orders.poll_interval must be positive")
+	}
+	if c.Orders.BatchMaxRows <= 0 {
+		problems = append(problems, "orders.batch_max_rows must be positive")
+	}
+
+	required(c.Cache.Dir, "cache.dir", "CACHE_DIR")
+	if c.Cache.TTL.Duration <= 0 {
+		problems = append(problems, "cache.ttl must be positive")
+	}
+	if c.Cache.MaxSizeMB <= 0 {
+		problems = append(problems, "cache.max_size_mb must be positive")
+	}
+
+	switch c.Blob.Driver {
+	case "local":
+		required(c.Blob.Dir, "blob.dir", "BLOB_DIR")
+	case "s3":
+		httpURL(c.Blob.S3.Endpoint, "blob.s3.endpoint")
+		required(c.Blob.S3.Region, "blob.s3.region", "BLOB_S3_REGION")
+		required(c.Blob.S3.Bucket, "blob.s3.bucket", "BLOB_S3_BUCKET")
+		required(c.Blob.S3.AccessKey, "blob.s3.access_key", "BLOB_S3_ACCESS_KEY")
+		required(c.Blob.S3.SecretKey, "blob.s3.secret_key", "BLOB_S3_SECRET_KEY")
+	default:
+		problems = append(problems, fmt.Sprintf("blob.driver %q is not supported", c.Blob.Driver))
+	}
+
+	switch c.Mail.Transport {
+	case "log":
+	case "file":
+		required(c.Mail.Dir, "mail.dir", "MAIL_DIR")
+	case "smtp":
+		required(c.Mail.SMTP.Addr, "mail.smtp.addr", "MAIL_SMTP_ADDR")
+	default:
+		problems = append(problems, fmt.Sprintf("mail.transport %q is not supported", c.Mail.Transport))
+	}
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		problems = append(problems, fmt.Sprintf("mail.from must be an email address, got %q", c.Mail.From))
+	}
+
+	switch c.Payment.Provider {
+	case "fake":
+	case "stripe":
+		httpURL(c.Payment.Stripe.BaseURL, "payment.stripe.base_url")
+		required(c.Payment.Stripe.SecretKey, "payment.stripe.secret_key", "PAYMENT_STRIPE_SECRET_KEY")
+		required(c.Payment.Stripe.PublishableKey, "payment.stripe.publishable_key", "PAYMENT_STRIPE_PUBLISHABLE_KEY")
+		if c.Payment.Stripe.Timeout.Duration <= 0 {
+			problems = append(problems, "payment.stripe.timeout must be positive")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("payment.provider %q is not supported", c.Payment.Provider))
+	}
+
+	if c.Accounts.ResetTTL.Duration <= 0 {
+		problems = append(problems, "accounts.reset_ttl must be positive")
+	}
+
+	if c.Production.UsableAreaFraction <= 0 || c.Production.UsableAreaFraction > 1 {
+		problems = append(problems, "production.usable_area_fraction must be between 0 and 1")
+	}
+	if c.Production.ModuleKwPerSqM <= 0 {
+		problems = append(problems, "production.module_kw_per_sq_m must be positive")
+	}
+	if c.Production.Losses < -5 || c.Production.Losses > 99 {
+		problems = append(problems, "production.losses must be between -5 and 99 percent")
+	}
+	if c.Production.ArrayType < 0 || c.Production.ArrayType > 4 {
+		problems = append(problems, "production.array_type must be between 0 and 4")
+	}
+	if c.Production.ModuleType < 0 || c.Production.ModuleType > 2 {
+		problems = append(problems, "production.module_type must be between 0 and 2")
+	}
+
+	for _, module := range c.Sizing.Modules {
+		if module.Length <= 0 || module.Width <= 0 || module.Watts <= 0 {
+			problems = append(problems, fmt.Sprintf("sizing.modules %q needs a positive length, width and watts", module.Name))
+		}
+	}
+	if _, ok := c.Sizing.SelectedModule(); !ok {
+		problems = append(problems, fmt.Sprintf("sizing.module %q is not in sizing.modules", c.Sizing.Module))
+	}
+	if c.Sizing.EdgeSetbackFt < 0 || c.Sizing.RidgeSetbackFt < 0 {
+		problems = append(problems, "sizing setbacks must not be negative")
+	}
+	if c.Sizing.MinTSRF < 0 || c.Sizing.MinTSRF > 1 {
+		problems = append(problems, "sizing.min_tsrf must be between 0 and 1")
+	}
+
+	if c.Finance.UtilityRate <= 0 {
+		problems = append(problems, "finance.utility_rate must be positive")
+	}
+	if c.Finance.CostPerWatt <= 0 {
+		problems = append(problems, "finance.cost_per_watt must be positive")
+	}
+	if c.Finance.Degradation < 0 || c.Finance.Degradation >= 100 {
+		problems = append(problems, "finance.degradation must be between 0 and 100 percent")
+	}
+	if c.Finance.IncentivePercent < 0 || c.Finance.IncentivePercent > 100 {
+		problems = append(problems, "finance.incentive_percent must be between 0 and 100")
+	}
+	if c.Finance.IncentiveAmount < 0 {
+		problems = append(problems, "finance.incentive_amount must not be negative")
+	}
+	if c.Finance.DiscountRate <= -100 {
+		problems = append(problems, "finance.discount_rate must be above -100 percent")
+	}
+	if c.Finance.Years <= 0 || c.Finance.Years > 50 {
+		problems = append(problems, "finance.years must be between 1 and 50")
+	}
+
+	required(c.Company.Name, "company.name", "COMPANY_NAME")
+	slugs := map[string]string{}
+	for i, company := range append([]Company{c.Company}, c.Organizations...) {
+		name := "company"
+		if i > 0 {
+			name = fmt.Sprintf("organizations[%d]", i-1)
+		}
+		for _, problem := range company.Organization().Validate() {
+			problems = append(problems, name+": "+problem)
+		}
+		if other, ok := slugs[company.Slug]; ok {
+			problems = append(problems, fmt.Sprintf("%s: slug %q is already used by %s", name, company.Slug, other))
+		}
+		slugs[company.Slug] = name
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
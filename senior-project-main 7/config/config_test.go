@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig is the default configuration with every secret set
+func validConfig() *Config {
+	c := Default()
+	c.Session.Key = "session-key"
+	c.Database.DSN = "renulogix.db"
+	c.EagleView.SourceID = "source"
+	c.EagleView.ClientSecret = "secret"
+	c.EagleView.Username = "user"
+	c.EagleView.Password = "password"
+	c.NREL.APIKey = "nrel-key"
+	return c
+}
+
+// lookupMap returns an os.LookupEnv reading from env
+func lookupMap(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	c := Default()
+	err := c.applyEnv(lookupMap(map[string]string{
+		"DATABASE_DRIVER":       "sqlite",
+		"EAGLEVIEW_TIMEOUT":     "90s",
+		"ORDERS_BATCH_MAX_ROWS": "20",
+		"FINANCE_UTILITY_RATE":  "0.21",
+		"FINANCE_YEARS":         "30",
+		"SERVER_REPORT_ADDR":    "",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Database.Driver != "sqlite" || c.EagleView.Timeout.Duration != 90*time.Second || c.Orders.BatchMaxRows != 20 ||
+		c.Finance.UtilityRate != 0.21 || c.Finance.Years != 30 {
+		t.Errorf("applyEnv left %+v %+v %+v %+v", c.Database, c.EagleView, c.Orders, c.Finance)
+	}
+	if c.Server.ReportAddr != "" {
+		t.Errorf("ReportAddr = %q, want the empty value set", c.Server.ReportAddr)
+	}
+	if c.Server.OrderAddr != Default().Server.OrderAddr {
+		t.Errorf("OrderAddr = %q, want the default kept", c.Server.OrderAddr)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	for name, value := range map[string]string{
+		"EAGLEVIEW_TIMEOUT":     "soon",
+		"ORDERS_BATCH_MAX_ROWS": "2.5",
+		"FINANCE_YEARS":         "thirty",
+		"FINANCE_UTILITY_RATE":  "cheap",
+	} {
+		err := Default().applyEnv(lookupMap(map[string]string{name: value}))
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s=%s: err = %v, want an error naming the variable", name, value, err)
+		}
+	}
+}
+
+func TestEnvVarsTypes(t *testing.T) {
+	// a field of any other type would be skipped by applyEnv without a word
+	for name, field := range Default().envVars() {
+		switch field.(type) {
+		case *string, *Duration, *int, *float64:
+		default:
+			t.Errorf("%s is a %T, which applyEnv does not set", name, field)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid configuration: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		// problems are parts of the problems expected, one each
+		problems []string
+	}{
+		{name: "no secrets", change: func(c *Config) { *c = *Default() }, problems: []string{
+			"session.key is required (or set SESSION_KEY)", "database.dsn", "eagleview.source_id", "eagleview.client_secret",
+			"eagleview.username", "eagleview.password", "nrel.api_key",
+		}},
+		{name: "driver", change: func(c *Config) { c.Database.Driver = "postgres" }, problems: []string{`database.driver "postgres"`}},
+		{name: "url", change: func(c *Config) { c.EagleView.BaseURL = "webservices.eagleview.com" }, problems: []string{"eagleview.base_url must be an http(s) URL"}},
+		{name: "timeout", change: func(c *Config) { c.NREL.Timeout.Duration = 0 }, problems: []string{"nrel.timeout"}},
+		{name: "s3", change: func(c *Config) { c.Blob.Driver = "s3" }, problems: []string{
+			"blob.s3.endpoint", "blob.s3.bucket", "blob.s3.access_key", "blob.s3.secret_key",
+		}},
+		{name: "stripe", change: func(c *Config) { c.Payment.Provider = "stripe" }, problems: []string{
+			"payment.stripe.secret_key", "payment.stripe.publishable_key",
+		}},
+		{name: "mail from", change: func(c *Config) { c.Mail.From = "nobody" }, problems: []string{"mail.from"}},
+		{name: "finance years", change: func(c *Config) { c.Finance.Years = 51 }, problems: []string{"finance.years"}},
+		{name: "sizing module", change: func(c *Config) { c.Sizing.Module = "Unknown" }, problems: []string{`sizing.module "Unknown"`}},
+		{name: "duplicate slug", change: func(c *Config) { c.Organizations = []Company{c.Company} }, problems: []string{"organizations[0]: slug"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := validConfig()
+			test.change(c)
+			err := c.Validate()
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			for _, want := range test.problems {
+				found := false
+				for _, problem := range invalid.Problems {
+					found = found || strings.Contains(problem, want)
+				}
+				if !found {
+					t.Errorf("no problem mentions %q in\n%v", want, err)
+				}
+			}
+			if len(invalid.Problems) != len(test.problems) {
+				t.Errorf("%d problems, want %d:\n%v", len(invalid.Problems), len(test.problems), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package eagleview
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default endpoints of the production EagleView web services
+const (
+	DefaultBaseURL         = "https://webservices.eagleview.com"
+	DefaultIntegrationsURL = "https://webservices-integrations.eagleview.com"
+)
+
+// Client is every EagleView call the application makes
+type Client interface {
+	Token(ctx context.Context) (Token, error)
+	RefreshToken(ctx context.Context, refreshToken string) (Token, error)
+	PlaceOrder(ctx context.Context, token Token, order OrderInfo) (OrderStats, error)
+	GetReportFile(ctx context.Context, token Token, reportId string, fileType int, fileFormat int) ([]byte, error)
+	GetReport(ctx context.Context, token Token, reportId string) (Report, error)
+	FileLinks(ctx context.Context, token Token, reportId string) ([]Links, error)
+}
+
+// Config holds the endpoints, credentials and transport used by HTTPClient
+type Config struct {
+	BaseURL         string
+	IntegrationsURL string
+	SourceID        string
+	ClientSecret    string
+	Username        string
+	Password        string
+	HTTPClient      *http.Client
+}
+
+// StatusError is returned when EagleView answers with a non 200 status
+type StatusError struct {
+	Code   int
+	Status string
+	Body   []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("eagleview: unexpected status %s", e.Status)
+}
+
+// HTTPClient is the Client talking to the real (or a fake) EagleView server
+type HTTPClient struct {
+	cfg  Config
+	http *http.Client
+}
+
+// New returns an HTTPClient, falling back to the production URLs when unset
+func New(cfg Config) *HTTPClient {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.IntegrationsURL == "" {
+		cfg.IntegrationsURL = DefaultIntegrationsURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	cfg.IntegrationsURL = strings.TrimRight(cfg.IntegrationsURL, "/")
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &HTTPClient{cfg: cfg, http: httpClient}
+}
+
+// Token generates an authentication token with the password grant
+func (c *HTTPClient) Token(ctx context.Context) (Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", "password")
+	data.Set("username", c.cfg.Username)
+	data.Set("password", c.cfg.Password)
+	return c.requestToken(ctx, data)
+}
+
+// RefreshToken exchanges a refresh token for a new access token
+func (c *HTTPClient) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	return c.requestToken(ctx, data)
+}
+
+func (c *HTTPClient) requestToken(ctx context.Context, data url.Values) (Token, error) {
+	var token Token
+	body := data.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+"/Token", strings.NewReader(body))
+	if err != nil {
+		return token, err
+	}
+	sEnc := b64.StdEncoding.EncodeToString([]byte(c.cfg.SourceID + ":" + c.cfg.ClientSecret))
+	req.Header.Add("Authorization", "Basic "+sEnc)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(body)))
+
+	bodyBytes, err := c.do(req)
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(bodyBytes, &token)
+	return token, err
+}
+
+// PlaceOrder places an order for the reports described in order
+func (c *HTTPClient) PlaceOrder(ctx context.Context, token Token, order OrderInfo) (OrderStats, error) {
+	var stats OrderStats
+	jsonData, err := json.Marshal(order)
+	if err != nil {
+		return stats, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.IntegrationsURL+"/v2/Order/PlaceOrder", bytes.NewReader(jsonData))
+	if err != nil {
+		return stats, err
+	}
+	authorize(req, token)
+	req.Header.Add("Content-Type", "application/json")
+
+	bodyBytes, err := c.do(req)
+	if err != nil {
+		return stats, err
+	}
+	err = json.Unmarshal(bodyBytes, &stats)
+	return stats, err
+}
+
+// GetReportFile retrieves a report file (PDF or image) of the given type and format
+func (c *HTTPClient) GetReportFile(ctx context.Context, token Token, reportId string, fileType int, fileFormat int) ([]byte, error) {
+	query := url.Values{}
+	query.Set("reportId", reportId)
+	query.Set("fileType", strconv.Itoa(fileType))
+	query.Set("fileFormat", strconv.Itoa(fileFormat))
+	req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.BaseURL+"/v1/File/GetReportFile?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, token)
+	return c.do(req)
+}
+
+// GetReport retrieves the v3 report details, used to check whether a report is ready
+func (c *HTTPClient) GetReport(ctx context.Context, token Token, reportId string) (Report, error) {
+	var report Report
+	req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.BaseURL+"/v3/Report/GetReport?reportId="+url.QueryEscape(reportId), nil)
+	if err != nil {
+		return report, err
+	}
+	authorize(req, token)
+
+	bodyBytes, err := c.do(req)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(bodyBytes, &report)
+	return report, err
+}
+
+// FileLinks lists the download links of every file attached to a report
+func (c *HTTPClient) FileLinks(ctx context.Context, token Token, reportId string) ([]Links, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.BaseURL+"/v1/reports/"+url.PathEscape(reportId)+"/file-links", nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, token)
+
+	bodyBytes, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var links Link
+	err = json.Unmarshal(bodyBytes, &links)
+	return links.Links, err
+}
+
+func authorize(req *http.Request, token Token) {
+	req.Header.Add("Authorization", token.TokenType+" "+token.AccessToken)
+}
+
+func (c *HTTPClient) do(req *http.Request) ([]byte, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status, Body: bodyBytes}
+	}
+	return bodyBytes, nil
+}
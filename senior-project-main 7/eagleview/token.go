@@ -0,0 +1,155 @@
+package eagleview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenSource hands out a valid access token
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+	// Invalidate drops the cached token after EagleView rejected it, unless
+	// it was already replaced by another token than rejected
+	Invalidate(rejected Token)
+}
+
+// Unauthorized reports whether err is EagleView rejecting the access token
+func Unauthorized(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusUnauthorized
+}
+
+// Retry calls fn with token. When EagleView rejects it with a 401, such as a
+// token revoked before its expiry, the token is invalidated and fn called
+// once more with a fresh one from tokens.
+func Retry(ctx context.Context, tokens TokenSource, token Token, fn func(token Token) error) error {
+	err := fn(token)
+	if !Unauthorized(err) {
+		return err
+	}
+	tokens.Invalidate(token)
+	if token, err = tokens.Token(ctx); err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+// DefaultRefreshLeeway is how long before expiry a cached token is replaced
+const DefaultRefreshLeeway = 2 * time.Minute
+
+// TokenManager caches the EagleView access token and refreshes it before it
+// expires. Concurrent callers needing a new token share a single request.
+type TokenManager struct {
+	client  Client
+	leeway  time.Duration
+	timeout time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	token    Token
+	expiry   time.Time
+	inflight *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token Token
+	err   error
+}
+
+// NewTokenManager returns a TokenManager fetching tokens through client
+func NewTokenManager(client Client, leeway time.Duration) *TokenManager {
+	if leeway <= 0 {
+		leeway = DefaultRefreshLeeway
+	}
+	return &TokenManager{
+		client:  client,
+		leeway:  leeway,
+		timeout: time.Minute,
+		now:     time.Now,
+	}
+}
+
+// Token returns the cached token, or waits for a fresh one when it is
+// missing or about to expire
+func (m *TokenManager) Token(ctx context.Context) (Token, error) {
+	m.mu.Lock()
+	if m.token.AccessToken != "" && m.now().Add(m.leeway).Before(m.expiry) {
+		token := m.token
+		m.mu.Unlock()
+		return token, nil
+	}
+	call := m.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		m.inflight = call
+		go m.fetch(call, m.token.RefreshToken)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	}
+}
+
+// Invalidate drops the cached access token when it is rejected, e.g. after
+// EagleView refused it. A token already refreshed by a concurrent caller that
+// got the same 401 is kept. The refresh token is kept so the next call can
+// still use the refresh grant.
+func (m *TokenManager) Invalidate(rejected Token) {
+	m.mu.Lock()
+	if m.token.AccessToken == rejected.AccessToken {
+		m.token.AccessToken = ""
+		m.expiry = time.Time{}
+	}
+	m.mu.Unlock()
+}
+
+// fetch runs detached from the caller's context so one cancelled request does
+// not fail everybody else waiting on the same refresh
+func (m *TokenManager) fetch(call *tokenCall, refreshToken string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	issued := m.now()
+	var token Token
+	var err error
+	if refreshToken != "" {
+		token, err = m.client.RefreshToken(ctx, refreshToken)
+	}
+	if refreshToken == "" || err != nil {
+		token, err = m.client.Token(ctx)
+	}
+
+	m.mu.Lock()
+	if err == nil {
+		if token.RefreshToken == "" {
+			token.RefreshToken = refreshToken
+		}
+		m.token = token
+		m.expiry = tokenExpiry(token, issued)
+	}
+	m.inflight = nil
+	m.mu.Unlock()
+
+	call.token, call.err = token, err
+	close(call.done)
+}
+
+// tokenExpiry prefers expires_in counted from when the request was sent and
+// falls back to the absolute .expires timestamp
+func tokenExpiry(token Token, issued time.Time) time.Time {
+	if token.ExpiresIn > 0 {
+		return issued.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if expires, err := http.ParseTime(token.Expires); err == nil {
+		return expires
+	}
+	return issued
+}
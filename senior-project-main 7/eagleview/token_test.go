@@ -0,0 +1,259 @@
+package eagleview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTokenClient answers the password and refresh grants, counting them
+type fakeTokenClient struct {
+	Client
+
+	// release, when set, holds every grant until it is closed
+	release    chan struct{}
+	refreshErr error
+
+	passwords int32
+	refreshes int32
+}
+
+func (c *fakeTokenClient) Token(ctx context.Context) (Token, error) {
+	if c.release != nil {
+		<-c.release
+	}
+	n := atomic.AddInt32(&c.passwords, 1)
+	return Token{AccessToken: "password-" + strconv.Itoa(int(n)), ExpiresIn: 600, RefreshToken: "refresh"}, nil
+}
+
+func (c *fakeTokenClient) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	if c.release != nil {
+		<-c.release
+	}
+	if c.refreshErr != nil {
+		return Token{}, c.refreshErr
+	}
+	n := atomic.AddInt32(&c.refreshes, 1)
+	return Token{AccessToken: "refreshed-" + strconv.Itoa(int(n)), ExpiresIn: 600}, nil
+}
+
+func TestTokenManagerRefresh(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		refreshErr error
+		want       string
+		passwords  int32
+		refreshes  int32
+	}{
+		{name: "cached", elapsed: time.Minute, want: "password-1", passwords: 1},
+		{name: "inside leeway", elapsed: 9 * time.Minute, want: "refreshed-1", passwords: 1, refreshes: 1},
+		{name: "expired", elapsed: time.Hour, want: "refreshed-1", passwords: 1, refreshes: 1},
+		{name: "refresh rejected", elapsed: time.Hour, refreshErr: errors.New("invalid_grant"), want: "password-2", passwords: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &fakeTokenClient{refreshErr: test.refreshErr}
+			now := start
+			m := NewTokenManager(client, 2*time.Minute)
+			m.now = func() time.Time { return now }
+
+			if _, err := m.Token(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(test.elapsed)
+			token, err := m.Token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.AccessToken != test.want {
+				t.Errorf("token = %q, want %q", token.AccessToken, test.want)
+			}
+			if client.passwords != test.passwords || client.refreshes != test.refreshes {
+				t.Errorf("grants = %d password %d refresh, want %d and %d", client.passwords, client.refreshes, test.passwords, test.refreshes)
+			}
+		})
+	}
+}
+
+func TestTokenManagerCoalesces(t *testing.T) {
+	client := &fakeTokenClient{release: make(chan struct{})}
+	m := NewTokenManager(client, 0)
+
+	const callers = 8
+	var wg sync.WaitGroup
+	tokens := make([]Token, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = m.Token(context.Background())
+		}(i)
+	}
+	// Every caller is waiting on the single request once it is in flight
+	for {
+		m.mu.Lock()
+		inflight := m.inflight != nil
+		m.mu.Unlock()
+		if inflight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	if client.passwords != 1 {
+		t.Errorf("%d token requests, want 1", client.passwords)
+	}
+	for i := range tokens {
+		if errs[i] != nil || tokens[i].AccessToken != "password-1" {
+			t.Errorf("caller %d got %q, %v", i, tokens[i].AccessToken, errs[i])
+		}
+	}
+}
+
+func TestTokenManagerCancelledCaller(t *testing.T) {
+	client := &fakeTokenClient{release: make(chan struct{})}
+	m := NewTokenManager(client, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.Token(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	// The cancelled caller does not fail the refresh for the next one
+	close(client.release)
+	token, err := m.Token(context.Background())
+	if err != nil || token.AccessToken != "password-1" {
+		t.Errorf("got %q, %v", token.AccessToken, err)
+	}
+}
+
+func TestTokenManagerInvalidate(t *testing.T) {
+	client := &fakeTokenClient{}
+	m := NewTokenManager(client, 0)
+	rejected, err := m.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Invalidate(rejected)
+	token, err := m.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "refreshed-1" || token.RefreshToken != "refresh" {
+		t.Errorf("token = %+v, want the refresh grant keeping the refresh token", token)
+	}
+}
+
+func TestTokenManagerInvalidateConcurrent401(t *testing.T) {
+	client := &fakeTokenClient{}
+	m := NewTokenManager(client, 0)
+	rejected, err := m.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first caller to get the 401 refreshes the token, the others got
+	// the 401 for the same old token and must keep the refreshed one
+	m.Invalidate(rejected)
+	refreshed, err := m.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		m.Invalidate(rejected)
+		token, err := m.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != refreshed.AccessToken {
+			t.Errorf("token = %q after a stale invalidation, want %q", token.AccessToken, refreshed.AccessToken)
+		}
+	}
+	if client.refreshes != 1 || client.passwords != 1 {
+		t.Errorf("grants = %d password %d refresh, want 1 and 1", client.passwords, client.refreshes)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	issued := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		token Token
+		want  time.Time
+	}{
+		{name: "expires in", token: Token{ExpiresIn: 3600, Expires: "Wed, 01 May 2024 20:00:00 GMT"}, want: issued.Add(time.Hour)},
+		{name: "expires", token: Token{Expires: "Wed, 01 May 2024 20:00:00 GMT"}, want: issued.Add(8 * time.Hour)},
+		{name: "neither", token: Token{Expires: "tomorrow"}, want: issued},
+	}
+	for _, test := range tests {
+		if got := tokenExpiry(test.token, issued); !got.Equal(test.want) {
+			t.Errorf("%s: expiry = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// fakeTokenSource hands out numbered tokens and records invalidations
+type fakeTokenSource struct {
+	issued      int
+	invalidated []string
+}
+
+func (s *fakeTokenSource) Token(ctx context.Context) (Token, error) {
+	s.issued++
+	return Token{AccessToken: strconv.Itoa(s.issued)}, nil
+}
+
+func (s *fakeTokenSource) Invalidate(rejected Token) {
+	s.invalidated = append(s.invalidated, rejected.AccessToken)
+}
+
+func TestRetry(t *testing.T) {
+	unauthorized := &StatusError{Code: http.StatusUnauthorized, Status: "401 Unauthorized"}
+	notFound := &StatusError{Code: http.StatusNotFound, Status: "404 Not Found"}
+	tests := []struct {
+		name        string
+		errs        []error
+		wantErr     error
+		calls       int
+		invalidated int
+	}{
+		{name: "accepted", errs: []error{nil}, calls: 1},
+		{name: "other error", errs: []error{notFound}, wantErr: notFound, calls: 1},
+		{name: "rejected once", errs: []error{unauthorized, nil}, calls: 2, invalidated: 1},
+		{name: "rejected twice", errs: []error{unauthorized, unauthorized}, wantErr: unauthorized, calls: 2, invalidated: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := &fakeTokenSource{}
+			var used []string
+			err := Retry(context.Background(), tokens, Token{AccessToken: "stale"}, func(token Token) error {
+				used = append(used, token.AccessToken)
+				return test.errs[len(used)-1]
+			})
+			if err != test.wantErr {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+			if len(used) != test.calls || len(tokens.invalidated) != test.invalidated {
+				t.Errorf("%d calls and %d invalidations, want %d and %d", len(used), len(tokens.invalidated), test.calls, test.invalidated)
+			}
+			for _, rejected := range tokens.invalidated {
+				if rejected != "stale" {
+					t.Errorf("invalidated %q, want the rejected token", rejected)
+				}
+			}
+			if len(used) == 2 && (used[0] != "stale" || used[1] != "1") {
+				t.Errorf("tokens used = %v, want the stale one then a fresh one", used)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package eagleview
+
+import "time"
+
+// Request and response bodies exchanged with the EagleView web services
+type OrderInfo struct {
+	OrderReports []OrderReports `json:"OrderReports"`
+	// CreditCardData pays the order with a card, without it the order is
+	// billed to the EagleView account
+	CreditCardData *CreditCardData `json:"CreditCardData,omitempty"`
+}
+
+type OrderReports struct {
+	ReportAddresses            []ReportAddresses `json:"ReportAddresses"`
+	BuildingID                 string            `json:"BuildingId"`
+	PrimaryProductID           int               `json:"PrimaryProductId"`
+	DeliveryProductID          int               `json:"DeliveryProductId"`
+	MeasurementInstructionType int               `json:"MeasurementInstructionType"`
+	ClaimNumber                string            `json:"ClaimNumber"`
+	ClaimInfo                  string            `json:"ClaimInfo"`
+	BatchID                    string            `json:"BatchId"`
+	CatID                      string            `json:"CatId"`
+	ChangesInLast4Years        bool              `json:"ChangesInLast4Years"`
+	PONumber                   string            `json:"PONumber"`
+	Comments                   string            `json:"Comments"`
+	ReferenceID                string            `json:"ReferenceId"`
+	InsuredName                string            `json:"InsuredName"`
+}
+
+type ReportAddresses struct {
+	Address                  string `json:"Address"`
+	City                     string `json:"City"`
+	State                    string `json:"State"`
+	Zip                      string `json:"Zip"`
+	AddressType              int    `json:"AddressType"`
+	VerifierUsedID           int    `json:"VerifierUsedId"`
+	MapperUsedID             int    `json:"MapperUsedId"`
+	VerificationResultTypeID int    `json:"VerificationResultTypeId"`
+}
+
+type CreditCardData struct {
+	CardFirstName    string `json:"CardFirstName"`
+	CardLastName     string `json:"CardLastName"`
+	ExpirationMonth  int    `json:"ExpirationMonth"`
+	ExpirationYear   int    `json:"ExpirationYear"`
+	CreditCardNumber string `json:"CreditCardNumber"`
+	CreditCardType   int    `json:"CreditCardType"`
+}
+
+type OrderStats struct {
+	OrderID   int   `json:"OrderId"`
+	ReportIds []int `json:"ReportIds"`
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	AsClientID   string `json:"as:client_id"`
+	Issued       string `json:".issued"`
+	Expires      string `json:".expires"`
+}
+
+// Report is the part of the v3 GetReport response used to follow an order
+type Report struct {
+	ReportId  int    `json:"ReportId"`
+	Status    string `json:"Status"`
+	SubStatus string `json:"SubStatus"`
+	StatusId  int    `json:"StatusId"`
+}
+
+type Link struct {
+	Links []Links `json:"Links"`
+}
+
+type Links struct {
+	Link            string    `json:"Link"`
+	ExpireTimestamp time.Time `json:"ExpireTimestamp"`
+	FileType        string    `json:"FileType"`
+}
+
+// Product ids sent as PrimaryProductId when placing an order
+const (
+	ProductBasic    = 11
+	ProductAdvanced = 62
+)
+
+// File types accepted by GetReportFile
+const (
+	FileBasicReport    = 3
+	FileTopImage       = 6
+	FileNorthImage     = 22
+	FileSouthImage     = 23
+	FileEastImage      = 24
+	FileWestImage      = 25
+	FileAdvancedReport = 75
+)
+
+// File formats accepted by GetReportFile
+const (
+	FormatImage = 1
+	FormatPDF   = 2
+)
+
+// File type of the Radiance deliverable in the file-links listing
+const RadianceDeliverableJSON = "RadianceDeliverableJSON"
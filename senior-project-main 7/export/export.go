@@ -0,0 +1,114 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Table is a header row followed by data rows of the same width
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Field is one labelled value of the summary sheet
+type Field struct {
+	Label string
+	Value interface{}
+}
+
+// Sheet names of the workbook
+const (
+	SummarySheet = "Summary"
+	FacetSheet   = "Roof Facets"
+)
+
+// WriteCSV writes the table as comma separated values
+func WriteCSV(w io.Writer, table Table) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(table.Rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteXLSX writes a workbook with the summary on the first sheet and the
+// table on the second. Cells that hold numbers are stored as numbers so they
+// can be used in formulas.
+func WriteXLSX(w io.Writer, summary []Field, table Table) error {
+	book := excelize.NewFile()
+	defer book.Close()
+
+	if err := book.SetSheetName("Sheet1", SummarySheet); err != nil {
+		return err
+	}
+	for i, field := range summary {
+		if err := setRow(book, SummarySheet, i+1, []interface{}{field.Label, field.Value}); err != nil {
+			return err
+		}
+	}
+	if err := book.SetColWidth(SummarySheet, "A", "A", 30); err != nil {
+		return err
+	}
+	if err := book.SetColWidth(SummarySheet, "B", "B", 40); err != nil {
+		return err
+	}
+
+	if _, err := book.NewSheet(FacetSheet); err != nil {
+		return err
+	}
+	header := make([]interface{}, len(table.Header))
+	for i, title := range table.Header {
+		header[i] = title
+	}
+	if err := setRow(book, FacetSheet, 1, header); err != nil {
+		return err
+	}
+	bold, err := book.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	if len(table.Header) > 0 {
+		last, err := excelize.CoordinatesToCellName(len(table.Header), 1)
+		if err != nil {
+			return err
+		}
+		if err := book.SetCellStyle(FacetSheet, "A1", last, bold); err != nil {
+			return err
+		}
+	}
+	for i, row := range table.Rows {
+		values := make([]interface{}, len(row))
+		for j, cell := range row {
+			values[j] = cellValue(cell)
+		}
+		if err := setRow(book, FacetSheet, i+2, values); err != nil {
+			return err
+		}
+	}
+	return book.Write(w)
+}
+
+func setRow(book *excelize.File, sheet string, row int, values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, row)
+	if err != nil {
+		return err
+	}
+	return book.SetSheetRow(sheet, cell, &values)
+}
+
+// cellValue turns numeric text into a number, leaving values such as the
+// "6/12" pitch as text
+func cellValue(text string) interface{} {
+	if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+		return number
+	}
+	return text
+}
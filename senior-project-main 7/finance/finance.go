@@ -0,0 +1,142 @@
+package finance
+
+import (
+	"errors"
+	"math"
+)
+
+// Assumptions are the economic inputs of an analysis. Rates are percentages.
+type Assumptions struct {
+	// UtilityRate is the price of grid electricity in $/kWh in the first year
+	UtilityRate float64
+	// RateEscalation is the yearly increase of the utility rate
+	RateEscalation float64
+	// CostPerWatt is the installed system price in $ per W DC
+	CostPerWatt float64
+	// Degradation is the yearly loss of module output
+	Degradation float64
+	// IncentivePercent is the share of the system price paid back, such as a tax credit
+	IncentivePercent float64
+	// IncentiveAmount is a fixed rebate in $
+	IncentiveAmount float64
+	// DiscountRate is used for the net present value
+	DiscountRate float64
+	// Years is the length of the cash flow table
+	Years int
+}
+
+// Year is one row of the cash flow table. Year 0 is the purchase.
+type Year struct {
+	Year       int
+	Production float64
+	Rate       float64
+	Savings    float64
+	CashFlow   float64
+	Cumulative float64
+}
+
+// Analysis is the financial picture of a system over its lifetime
+type Analysis struct {
+	SystemKw         float64
+	AnnualProduction float64
+	SystemCost       float64
+	Incentives       float64
+	NetCost          float64
+	FirstYearSavings float64
+	LifetimeSavings  float64
+	// PaybackYears is the net cost divided by the first year savings
+	PaybackYears float64
+	// BreakEvenYear is the first year the cumulative cash flow is positive, 0 when it never is
+	BreakEvenYear int
+	NPV           float64
+	// IRR is a percentage, only meaningful when HasIRR is set
+	IRR      float64
+	HasIRR   bool
+	CashFlow []Year
+}
+
+// ErrNoProduction is returned for a system without production or size
+var ErrNoProduction = errors.New("finance: the system has no production")
+
+// Analyze projects the savings of a systemKw system producing annualProduction
+// kWh in its first year
+func Analyze(systemKw float64, annualProduction float64, a Assumptions) (Analysis, error) {
+	if systemKw <= 0 || annualProduction <= 0 {
+		return Analysis{}, ErrNoProduction
+	}
+	if a.Years <= 0 {
+		a.Years = 25
+	}
+
+	result := Analysis{
+		SystemKw:         systemKw,
+		AnnualProduction: annualProduction,
+		SystemCost:       systemKw * 1000 * a.CostPerWatt,
+	}
+	result.Incentives = math.Min(result.SystemCost*a.IncentivePercent/100+a.IncentiveAmount, result.SystemCost)
+	result.NetCost = result.SystemCost - result.Incentives
+
+	flows := make([]float64, a.Years+1)
+	flows[0] = -result.NetCost
+	result.CashFlow = append(result.CashFlow, Year{CashFlow: flows[0], Cumulative: flows[0]})
+	cumulative := flows[0]
+	for year := 1; year <= a.Years; year++ {
+		production := annualProduction * math.Pow(1-a.Degradation/100, float64(year-1))
+		rate := a.UtilityRate * math.Pow(1+a.RateEscalation/100, float64(year-1))
+		savings := production * rate
+		flows[year] = savings
+		cumulative += savings
+		if cumulative >= 0 && result.BreakEvenYear == 0 {
+			result.BreakEvenYear = year
+		}
+		result.LifetimeSavings += savings
+		result.CashFlow = append(result.CashFlow, Year{
+			Year:       year,
+			Production: production,
+			Rate:       rate,
+			Savings:    savings,
+			CashFlow:   savings,
+			Cumulative: cumulative,
+		})
+	}
+
+	result.FirstYearSavings = flows[1]
+	if result.FirstYearSavings > 0 {
+		result.PaybackYears = result.NetCost / result.FirstYearSavings
+	}
+	result.NPV = NPV(a.DiscountRate, flows)
+	result.IRR, result.HasIRR = IRR(flows)
+	return result, nil
+}
+
+// NPV discounts flows at rate percent per year, flows[0] being today
+func NPV(rate float64, flows []float64) float64 {
+	var total float64
+	for year, flow := range flows {
+		total += flow / math.Pow(1+rate/100, float64(year))
+	}
+	return total
+}
+
+// IRR finds the rate in percent at which the NPV of flows is zero. It
+// returns false when there is no rate between -99% and 1000%.
+func IRR(flows []float64) (float64, bool) {
+	low, high := -99.0, 1000.0
+	lowNPV, highNPV := NPV(low, flows), NPV(high, flows)
+	if math.IsNaN(lowNPV) || math.IsNaN(highNPV) || lowNPV*highNPV > 0 {
+		return 0, false
+	}
+	for i := 0; i < 200 && high-low > 1e-7; i++ {
+		mid := (low + high) / 2
+		midNPV := NPV(mid, flows)
+		if midNPV == 0 {
+			return mid, true
+		}
+		if (midNPV > 0) == (lowNPV > 0) {
+			low, lowNPV = mid, midNPV
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, true
+}
@@ -0,0 +1,141 @@
+package finance
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNPV(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		flows []float64
+		want  float64
+	}{
+		{name: "no flows", rate: 5, want: 0},
+		{name: "undiscounted", rate: 0, flows: []float64{-100, 50, 50}, want: 0},
+		{name: "at the return", rate: 10, flows: []float64{-100, 110}, want: 0},
+		{name: "three years", rate: 5, flows: []float64{-1000, 300, 400, 500}, want: 80.444876},
+		{name: "negative rate", rate: -50, flows: []float64{-100, 50}, want: 0},
+	}
+	for _, test := range tests {
+		if got := NPV(test.rate, test.flows); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: NPV = %f, want %f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIRR(t *testing.T) {
+	tests := []struct {
+		name   string
+		flows  []float64
+		want   float64
+		wantOK bool
+	}{
+		{name: "one year", flows: []float64{-100, 110}, want: 10, wantOK: true},
+		{name: "two years", flows: []float64{-100, 60, 60}, want: 13.066239, wantOK: true},
+		{name: "five years", flows: []float64{-70000, 12000, 15000, 18000, 21000, 26000}, want: 8.663095, wantOK: true},
+		{name: "loss", flows: []float64{-100, 50}, want: -50, wantOK: true},
+		{name: "no outlay", flows: []float64{100, 10}},
+		{name: "no return", flows: []float64{-100, 0}},
+	}
+	for _, test := range tests {
+		got, ok := IRR(test.flows)
+		if ok != test.wantOK || math.Abs(got-test.want) > 1e-5 {
+			t.Errorf("%s: IRR = %f, %t, want %f, %t", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	base := Assumptions{UtilityRate: 0.2, CostPerWatt: 3, IncentivePercent: 30, Years: 10}
+	tests := []struct {
+		name        string
+		systemKw    float64
+		production  float64
+		a           Assumptions
+		netCost     float64
+		payback     float64
+		breakEven   int
+		lifetime    float64
+		npv         float64
+		years       int
+		wantErr     error
+		wantHasIRR  bool
+		firstSaving float64
+	}{
+		{
+			name: "flat rate", systemKw: 5, production: 7000, a: base,
+			netCost: 10500, payback: 7.5, breakEven: 8, lifetime: 14000, npv: 3500, years: 10,
+			wantHasIRR: true, firstSaving: 1400,
+		},
+		{
+			name: "incentives capped at the price", systemKw: 5, production: 7000,
+			a:       Assumptions{UtilityRate: 0.2, CostPerWatt: 3, IncentiveAmount: 20000, Years: 10},
+			netCost: 0, payback: 0, breakEven: 1, lifetime: 14000, npv: 14000, years: 10, firstSaving: 1400,
+		},
+		{
+			name: "default lifetime", systemKw: 5, production: 7000,
+			a:       Assumptions{UtilityRate: 0.2, CostPerWatt: 3, IncentivePercent: 30},
+			netCost: 10500, payback: 7.5, breakEven: 8, lifetime: 35000, npv: 24500, years: 25,
+			wantHasIRR: true, firstSaving: 1400,
+		},
+		{name: "no size", systemKw: 0, production: 7000, a: base, wantErr: ErrNoProduction},
+		{name: "no production", systemKw: 5, production: 0, a: base, wantErr: ErrNoProduction},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Analyze(test.systemKw, test.production, test.a)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			near := func(field string, got float64, want float64) {
+				if math.Abs(got-want) > 1e-6 {
+					t.Errorf("%s = %f, want %f", field, got, want)
+				}
+			}
+			near("NetCost", got.NetCost, test.netCost)
+			near("PaybackYears", got.PaybackYears, test.payback)
+			near("LifetimeSavings", got.LifetimeSavings, test.lifetime)
+			near("NPV", got.NPV, test.npv)
+			near("FirstYearSavings", got.FirstYearSavings, test.firstSaving)
+			if got.BreakEvenYear != test.breakEven {
+				t.Errorf("BreakEvenYear = %d, want %d", got.BreakEvenYear, test.breakEven)
+			}
+			if len(got.CashFlow) != test.years+1 {
+				t.Errorf("%d cash flow rows, want %d", len(got.CashFlow), test.years+1)
+			}
+			if got.HasIRR != test.wantHasIRR {
+				t.Errorf("HasIRR = %t, want %t", got.HasIRR, test.wantHasIRR)
+			}
+		})
+	}
+}
+
+func TestAnalyzeEscalationAndDegradation(t *testing.T) {
+	a := Assumptions{UtilityRate: 0.1, RateEscalation: 10, Degradation: 50, CostPerWatt: 1, Years: 3}
+	got, err := Analyze(1, 1000, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Year{
+		{Year: 0, CashFlow: -1000, Cumulative: -1000},
+		{Year: 1, Production: 1000, Rate: 0.1, Savings: 100, CashFlow: 100, Cumulative: -900},
+		{Year: 2, Production: 500, Rate: 0.11, Savings: 55, CashFlow: 55, Cumulative: -845},
+		{Year: 3, Production: 250, Rate: 0.121, Savings: 30.25, CashFlow: 30.25, Cumulative: -814.75},
+	}
+	for i, year := range got.CashFlow {
+		w := want[i]
+		if year.Year != w.Year || math.Abs(year.Production-w.Production) > 1e-9 || math.Abs(year.Rate-w.Rate) > 1e-9 ||
+			math.Abs(year.Savings-w.Savings) > 1e-9 || math.Abs(year.Cumulative-w.Cumulative) > 1e-9 {
+			t.Errorf("year %d = %+v, want %+v", i, year, w)
+		}
+	}
+	if got.BreakEvenYear != 0 {
+		t.Errorf("BreakEvenYear = %d, want 0 for a system that never pays back", got.BreakEvenYear)
+	}
+}
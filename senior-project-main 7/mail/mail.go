@@ -0,0 +1,275 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message is a plain text email, with optional attachments
+type Message struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent along with a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTP sends messages through an SMTP server, such as MailHog on
+// localhost:1025 during development
+type SMTP struct {
+	// Addr is the host:port of the server
+	Addr string
+	From string
+	// Username and Password are optional, the server must offer STARTTLS for
+	// them to be sent
+	Username string
+	Password string
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	from, err := netmail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("mail: sender %q: %w", s.From, err)
+	}
+	data, err := encode(s.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, auth, from.Address, msg.To, data); err != nil {
+		// A connection cut short by ctx fails with a network error that hides why
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("mail: sending to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail bounded by ctx: the connection takes the deadline of
+// ctx and is closed when ctx is cancelled, so a stalled server cannot hold
+// the caller forever
+func (s *SMTP) send(ctx context.Context, auth smtp.Auth, from string, to string, data []byte) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Log writes messages to the application log instead of sending them, so
+// links such as password resets can be followed during development
+type Log struct {
+	From string
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	var attached string
+	for _, attachment := range msg.Attachments {
+		attached += fmt.Sprintf("\n[attached %s, %d bytes]", attachment.Filename, len(attachment.Data))
+	}
+	log.Printf("mail from %s to %s: %s\n%s%s", l.From, msg.To, msg.Subject, msg.Body, attached)
+	return nil
+}
+
+// File writes each message with its attachments to a .eml file under Dir,
+// which mail clients open as is
+type File struct {
+	Dir  string
+	From string
+}
+
+var fileCount uint64
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := encode(f.From, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000000"), atomic.AddUint64(&fileCount, 1))
+	if err := os.WriteFile(filepath.Join(f.Dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	log.Printf("mail to %s written to %s", msg.To, filepath.Join(f.Dir, name))
+	return nil
+}
+
+// encode renders msg as an RFC 5322 message with a quoted-printable body.
+// Messages with attachments are sent as multipart/mixed, the body first.
+func encode(from string, msg Message, date time.Time) ([]byte, error) {
+	headers := []string{from, msg.To, msg.Subject}
+	for _, attachment := range msg.Attachments {
+		headers = append(headers, attachment.Filename, attachment.ContentType)
+	}
+	for _, header := range headers {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, fmt.Errorf("mail: header %q contains a line break", header)
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if len(msg.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeBody(&buf, msg.Body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", parts.Boundary())
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBody(part, msg.Body); err != nil {
+		return nil, err
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeBody(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data in lines of 76 characters, as RFC 2045 requires
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		line := encoded
+		if len(line) > 76 {
+			line = line[:76]
+		}
+		encoded = encoded[len(line):]
+		if _, err := io.WriteString(w, line+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers one SMTP session on l with the replies of a minimal server,
+// sending the commands it received on commands
+func serve(l net.Listener, commands chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		commands <- line
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250 test")
+		case "DATA":
+			reply("354 go ahead")
+			for {
+				data, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if data == ".\r\n" {
+					break
+				}
+			}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	commands := make(chan string, 20)
+	go serve(l, commands)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sender := &SMTP{Addr: l.Addr().String(), From: "Orders <orders@example.com>"}
+	if err := sender.Send(ctx, Message{To: "jane@example.com", Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(commands) > 0 {
+		got = append(got, <-commands)
+	}
+	want := []string{"EHLO localhost", "MAIL FROM:<orders@example.com>", "RCPT TO:<jane@example.com>", "DATA", "QUIT"}
+	for i, command := range want {
+		if i >= len(got) || !strings.HasPrefix(got[i], command) {
+			t.Fatalf("commands = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSMTPSendStalledServer(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{name: "deadline", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 100*time.Millisecond)
+		}, want: context.DeadlineExceeded},
+		{name: "cancelled", ctx: func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		}, want: context.Canceled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The server accepts the connection and never greets
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			go func() {
+				conn, err := l.Accept()
+				if err == nil {
+					defer conn.Close()
+					time.Sleep(5 * time.Second)
+				}
+			}()
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+			start := time.Now()
+			sender := &SMTP{Addr: l.Addr().String(), From: "orders@example.com"}
+			err = sender.Send(ctx, Message{To: "jane@example.com", Subject: "Hello", Body: "Hi"})
+			if !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Send returned after %v", elapsed)
+			}
+		})
+	}
+}
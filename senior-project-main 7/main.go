@@ -2,13 +2,13 @@ package main
 
 import (
 	"bytes"
-	"database/sql"
-	b64 "encoding/base64"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"image/jpeg"
-	"image/png"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -20,139 +20,49 @@ import (
 	"time"
 
 	generator "github.com/angelodlfrtr/go-invoice-generator"
-	creditcard "github.com/durango/go-credit-card"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
-	"github.com/melbahja/got"
+	"test/accounts"
+	"test/blob"
+	"test/cache"
+	"test/charts"
+	"test/config"
+	"test/eagleview"
+	"test/export"
+	"test/finance"
+	"test/mail"
+	"test/notify"
+	"test/nrel"
+	"test/orders"
+	"test/orgs"
+	"test/payment"
+	"test/postal"
+	"test/production"
+	"test/proposal"
+	"test/radiance"
+	"test/sizing"
+	"test/storage"
+	"test/thumbnail"
 )
 
 //##############################################################################################
 //Various Struct that are utilized throughout code from inputs, storing data, etc..
-type OrderInfo struct {
-	OrderReports   []OrderReports `json:"OrderReports"`
-	CreditCardData CreditCardData `json:"CreditCardData"`
-}
-
-type OrderReports struct {
-	ReportAddresses            []ReportAddresses `json:"ReportAddresses"`
-	BuildingID                 string            `json:"BuildingId"`
-	PrimaryProductID           int               `json:"PrimaryProductId"`
-	DeliveryProductID          int               `json:"DeliveryProductId"`
-	MeasurementInstructionType int               `json:"MeasurementInstructionType"`
-	ClaimNumber                string            `json:"ClaimNumber"`
-	ClaimInfo                  string            `json:"ClaimInfo"`
-	BatchID                    string            `json:"BatchId"`
-	CatID                      string            `json:"CatId"`
-	ChangesInLast4Years        bool              `json:"ChangesInLast4Years"`
-	PONumber                   string            `json:"PONumber"`
-	Comments                   string            `json:"Comments"`
-	ReferenceID                string            `json:"ReferenceId"`
-	InsuredName                string            `json:"InsuredName"`
-}
-
-type ReportAddresses struct {
-	Address                  string `json:"Address"`
-	City                     string `json:"City"`
-	State                    string `json:"State"`
-	Zip                      string `json:"Zip"`
-	AddressType              int    `json:"AddressType"`
-	VerifierUsedID           int    `json:"VerifierUsedId"`
-	MapperUsedID             int    `json:"MapperUsedId"`
-	VerificationResultTypeID int    `json:"VerificationResultTypeId"`
-}
-
-type OrderStats struct {
-	OrderID   int   `json:"OrderId"`
-	ReportIds []int `json:"ReportIds"`
-}
-
-type input struct {
-	reportNum string
-}
-
-type queryResult struct {
-	street       string
-	city         string
-	state        string
-	zipcode      string
-	azimuth      string
-	tilt         string
-	solar_annual float64
-	ac_annual    float64
-	reportId     string
-}
-
 type PageVariables struct {
-	Address    string
-	Ac_annual  float64
-	ReportId   string
-	TopImage   string
-	NorthImage string
-	SouthImage string
-	EastImage  string
-	WestImage  string
-	JsonMes    []ReportResult
-}
-
-type Response struct {
-	Inputs struct {
-		address        string `json:"address"`
-		SystemCapacity string `json:"system_capacity"`
-		Lat            string `json:"lat"`
-		Lon            string `json:"lon"`
-		Azimuth        string `json:"azimuth"`
-		Tilt           string `json:"tilt"`
-		ArrayType      string `json:"array_type"`
-		ModuleType     string `json:"module_type"`
-		Losses         string `json:"losses"`
-	} `json:"inputs"`
-	Errors   []interface{} `json:"errors"`
-	Warnings []interface{} `json:"warnings"`
-	Version  string        `json:"version"`
-	SscInfo  struct {
-		Version int    `json:"version"`
-		Build   string `json:"build"`
-	} `json:"ssc_info"`
-	StationInfo struct {
-		Lat               float64 `json:"lat"`
-		Lon               float64 `json:"lon"`
-		Elev              float64 `json:"elev"`
-		Tz                int     `json:"tz"`
-		Location          string  `json:"location"`
-		City              string  `json:"city"`
-		State             string  `json:"state"`
-		SolarResourceFile string  `json:"solar_resource_file"`
-		Distance          int     `json:"distance"`
-	} `json:"station_info"`
-	Outputs struct {
-		AcMonthly      []float64 `json:"ac_monthly"`
-		PoaMonthly     []float64 `json:"poa_monthly"`
-		SolradMonthly  []float64 `json:"solrad_monthly"`
-		DcMonthly      []float64 `json:"dc_monthly"`
-		AcAnnual       float64   `json:"ac_annual"`
-		SolradAnnual   float64   `json:"solrad_annual"`
-		CapacityFactor float64   `json:"capacity_factor"`
-	} `json:"outputs"`
-}
-
-type Token struct {
-	AccessToken  string `json:"access_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
-	AsClientID   string `json:"as:client_id"`
-	Issued       string `json:".issued"`
-	Expires      string `json:".expires"`
-}
-
-type Link struct {
-	Links []Links `json:"Links"`
-}
-type Links struct {
-	Link            string    `json:"Link"`
-	ExpireTimestamp time.Time `json:"ExpireTimestamp"`
-	FileType        string    `json:"FileType"`
+	Brand           Branding
+	Address         string
+	Ac_annual       float64
+	SystemKw        float64
+	AcAnnual        float64
+	Panels          int
+	Module          string
+	CapacityFactor  float64
+	ReportId        string
+	JsonMes         []ReportResult
+	SolarAccess     *SolarAccessMatrix
+	ModelError      string
+	ModelProblems   []string
+	ProductionChart template.HTML
+	IrradianceChart template.HTML
+	Finance         *finance.Analysis
 }
 
 type ReportResult struct {
@@ -164,41 +74,44 @@ type ReportResult struct {
 	Tsrf          string `json:"TSRF"`
 	Sa            string `json:"SA"`
 	SunHours      string `json:"sunhours"`
+	Panels        string `json:"panels"`
+	SystemKw      string `json:"systemKw"`
+	AcAnnual      string `json:"acAnnual"`
+	//Solar access of each month from January, empty for the months the Radiance model has no value for
+	SolarAccessMonthly []string `json:"solarAccessMonthly,omitempty"`
 }
 
-type ExportEV struct {
-	Reportid string   `json:"reportid"`
-	Location Location `json:"location"`
-	Roofs    []Roofs  `json:"roofs"`
+//Facet by month solar access table of the advanced report
+type SolarAccessMatrix struct {
+	Months []string
+	Rows   []SolarAccessRow
 }
-type Location struct {
-	Latitude         float64 `json:"latitude"`
-	Longitude        float64 `json:"longitude"`
-	Northorientation float64 `json:"northorientation"`
-	Address          string  `json:"address"`
-	City             string  `json:"city"`
-	Postal           string  `json:"postal"`
-	State            string  `json:"state"`
-}
-type Irradiance struct {
-	Tsrf float64 `json:"TSRF"`
-	Sa   float64 `json:"SA"`
+
+type SolarAccessRow struct {
+	Designator string
+	Values     []string
 }
-type Roofs struct {
-	Designator    string       `json:"designator"`
-	Unroundedsize string       `json:"unroundedsize"`
-	Pitch         string       `json:"pitch"`
-	PitchDeg      string       `json:"pitchDeg"`
-	ID            string       `json:"id"`
-	Orientation   float64      `json:"orientation"`
-	Irradiance    []Irradiance `json:"irradiance"`
+
+//Irradiance entries of one facet sorted into the annual value, the monthly values and any other scenarios
+type FacetIrradiance struct {
+	Annual    radiance.Irradiance
+	HasAnnual bool
+	Monthly   [12]*radiance.Irradiance
+	Scenarios []radiance.Irradiance
 }
 
+//Payment of an order, the card itself only known to the payment provider by its token
 type PaymentInfo struct {
-	ExpireMonth int
-	ExpireYear  int
-	CardNum     string
-	CardType    int
+	Card      payment.Token
+	PaymentID string
+}
+
+//References of an order at EagleView, set for the orders of a batch
+type OrderReference struct {
+	BatchID     string
+	PONumber    string
+	ClaimNumber string
+	ReferenceID string
 }
 
 type Address struct {
@@ -212,219 +125,455 @@ type Address struct {
 	LastName  string
 }
 
-type CreditCardData struct {
-	CardFirstName    string `json:"CardFirstName"`
-	CardLastName     string `json:"CardLastName"`
-	ExpirationMonth  int    `json:"ExpirationMonth"`
-	ExpirationYear   int    `json:"ExpirationYear"`
-	CreditCardNumber string `json:"CreditCardNumber"`
-	CreditCardType   int    `json:"CreditCardType"`
-}
-
 //##############################################################################################
 
-//Session Variable for redirecting
+//Session Variable for redirecting, the key is set from the configuration in main
 var (
-	store = sessions.NewCookieStore([]byte(os.Getenv("SESSION_KEY")))
+	cookieStore *sessions.CookieStore
 )
 
-//This Function checks if user report number exist after order was places and check if its ready
-func lookUpPage(w http.ResponseWriter, r *http.Request) {
-	var key = securecookie.GenerateRandomKey(32)
+//server holds the dependencies shared by the HTTP handlers
+type server struct {
+	cfg        *config.Config
+	store      storage.Store
+	ev         eagleview.Client
+	tokens     *eagleview.TokenManager
+	poller     *orders.Poller
+	nrel       *nrel.Client
+	production *production.Estimator
+	sizing     sizing.Config
+	finance    finance.Assumptions
+	cache      *cache.Cache
+	blobs      blob.Store
+	users      *accounts.Service
+	notifier   *notify.Notifier
+	payments   payment.Provider
+	//defaultOrg is the organization of the company section, used for users and orders without one
+	defaultOrg orgs.Organization
+}
+
+//Values shown on the report lookup page
+type FormPageVariables struct {
+	Brand   Branding
+	Email   string
+	Reports []storage.OrderRecord
+}
+
+//Values shown on the payment page
+type PaymentPageVariables struct {
+	Brand         Branding
+	BasicPrice    int
+	AdvancedPrice int
+	CardForm      CardForm
+	//Form holds the values sent when the page is shown again with errors, the card itself never reaches the server
+	Form url.Values
+	//AddressErrors are the problems of the billing address by field: street, city, state and zip
+	AddressErrors map[string]string
+	Error         string
+}
 
-	os.Setenv(string(key), "SESSION_KEY")
-	session, _ := store.Get(r, "cookie-name")
+//Values of the card fields of the payment pages, whose script tokenizes the card with the provider before the form is sent
+type CardForm struct {
+	//Provider is "stripe" or "fake", the fake provider tokenizes at /payment/cards
+	Provider string
+	//PublishableKey is the key of Stripe.js
+	PublishableKey string
+	//Errors are the problems of the card found by the server by field, only expiry
+	Errors map[string]string
+}
+
+//Values shown on the order status page
+type StatusPageVariables struct {
+	Brand     Branding
+	ReportId  string
+	Status    string
+	Label     string
+	Detail    string
+	UpdatedAt string
+	Message   string
+}
+
+//This Function checks if user report number exist after order was places and check if its ready
+func (s *server) lookUpPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
+	}
+	session, _ := cookieStore.Get(r, sessionName)
+	brand := brandOf(s.organization(r.Context(), user.OrgID))
 
-	session.Values["authenticated"] = true
 	if r.Method == "GET" {
-		t, _ := template.ParseFiles("html/formpage.html")
-		t.Execute(w, nil)
+		reports, err := s.store.UserOrders(r.Context(), user.ID)
+		if err != nil {
+			log.Print(err.Error())
+		}
+		t, _ := template.ParseFiles("html/formpage.html", "html/brand.html")
+		t.Execute(w, FormPageVariables{Brand: brand, Email: user.Email, Reports: reports})
 	} else {
 		r.ParseForm()
-		var userInput input
-		userInput.reportNum = r.FormValue("address")
-		db, _ := connectDb()
-		reportType := checkDb(db, userInput)
-		token, _ := eagleViewToken()
-		if reportType.Valid {
-			var reportData []byte
-			var reportLength int64
-			var resp string
-
-			if reportType.String == "Basic" {
-				reportData, reportLength, resp = getReportFile(token, userInput.reportNum, 3, 2)
-				if resp == "200 OK" && reportLength > 0 {
-					downloadPDF(w, r, reportData)
-				}
-			} else {
-				_, reportLength, resp = getReportFile(token, userInput.reportNum, 75, 2)
-				if resp == "200 OK" && reportLength > 0 {
-					session.Values["reportId"] = userInput.reportNum
-					session.Save(r, w)
-					http.Redirect(w, r, "/reportDisplay", http.StatusFound)
-				}
+		reportNum := strings.TrimSpace(r.FormValue("address"))
+		reportType, err := s.store.ReportType(r.Context(), reportNum)
+		//Reports of other users are not found either, so report ids cannot be probed
+		if err == nil {
+			var owned bool
+			if owned, err = s.ownsReport(r.Context(), user, reportNum); err == nil && !owned {
+				err = storage.ErrNotFound
 			}
-		} else {
-			http.Redirect(w, r, "/formpage", http.StatusFound)
 		}
-		db.Close()
-	}
-}
+		if errors.Is(err, storage.ErrNotFound) {
+			statusPage(w, brand, StatusPageVariables{ReportId: reportNum, Message: "We could not find an order for this report id."})
+			return
+		}
+		if err != nil {
+			log.Print(err.Error())
+			statusPage(w, brand, StatusPageVariables{ReportId: reportNum, Message: "The status of this report is unavailable right now, please try again later."})
+			return
+		}
 
-//This Function place order for report and inputs data for NREL based on retrieved values
-func order(token Token, addressInput Address, payment PaymentInfo, db *sql.DB) OrderStats {
-	order, response := placeOrder(token, addressInput, payment)
+		order, err := s.orderStatus(r.Context(), reportNum)
+		if err != nil {
+			log.Print(err.Error())
+			statusPage(w, brand, StatusPageVariables{ReportId: reportNum, Message: "The status of this report is unavailable right now, please try again later."})
+			return
+		}
+		if order.Status != orders.StatusCompleted {
+			statusPage(w, brand, statusPageVariables(order, ""))
+			return
+		}
 
-	if response == "200 OK" {
-		userDataToDb(addressInput, db, order)
-		responseObject, _ := NRELData(addressInput)
-		nrelToDb(responseObject, db, addressInput)
+		token, err := s.tokens.Token(r.Context())
+		if err != nil {
+			log.Print(err.Error())
+		}
+		if strings.EqualFold(reportType, "Basic") {
+			reportData, err := s.reportFile(r.Context(), token, reportNum, eagleview.FileBasicReport, eagleview.FormatPDF)
+			if err == nil && len(reportData) > 0 {
+				downloadPDF(w, r, reportData)
+				return
+			}
+		} else {
+			reportData, err := s.reportFile(r.Context(), token, reportNum, eagleview.FileAdvancedReport, eagleview.FormatPDF)
+			if err == nil && len(reportData) > 0 {
+				session.Values["reportId"] = reportNum
+				session.Save(r, w)
+				http.Redirect(w, r, "/reportDisplay", http.StatusFound)
+				return
+			}
+		}
+		statusPage(w, brand, statusPageVariables(order, "The report is ready but could not be downloaded from EagleView, please try again later."))
 	}
-	return order
 }
 
-//This function stores user information into the database
-func userDataToDb(userInput Address, db *sql.DB, order OrderStats) {
-	insert, err := db.Query("INSERT INTO OrderHistory VALUES (?,?,?,?,?,?,?,?,?)", userInput.FirstName, userInput.LastName, userInput.Email, userInput.Street, userInput.City, userInput.State, userInput.Zip, order.ReportIds[0], userInput.TypeRep)
+//This function returns the lifecycle state of an order, refreshed from EagleView when it is not finished
+func (s *server) orderStatus(ctx context.Context, reportId string) (orders.Order, error) {
+	order, err := s.store.Order(ctx, reportId)
+	if errors.Is(err, orders.ErrNotFound) {
+		//Orders placed before statuses were tracked start out as placed
+		if err = s.store.CreateOrder(ctx, reportId); err == nil {
+			order, err = s.store.Order(ctx, reportId)
+		}
+	}
 	if err != nil {
-		panic(err.Error())
+		return order, err
 	}
-	insert.Close()
-}
-
-//This function establishes connection to the AWS DB instance
-func connectDb() (*sql.DB, error) {
-	//For establishing connection to created aws db
-	//AWS Master User with Endpoint placed after the @ symbol and db instance name after / symbol
-	db, err := sql.Open("mysql", "master:Facompilers@tcp(database-1.cu9z37ygfbm2.us-west-1.rds.amazonaws.com:3306)/database-1")
 
+	refreshed, err := s.poller.Check(ctx, order)
 	if err != nil {
-		fmt.Print(err.Error())
+		log.Printf("checking report %s: %v", reportId, err)
+		return order, nil
 	}
-
-	return db, err
+	return refreshed, nil
 }
 
-//This function checks if the report type of the report from the report id
-func checkDb(db *sql.DB, userInput input) sql.NullString {
-	res, err := db.Query("SELECT reportType FROM OrderHistory where reportId=(?)", userInput.reportNum)
-	if err != nil {
-		panic(err.Error())
-	}
-	var reportId sql.NullString
-	for res.Next() {
-		err = res.Scan(&reportId)
-		if err != nil {
-			panic(err.Error())
-		}
+func statusPageVariables(order orders.Order, message string) StatusPageVariables {
+	return StatusPageVariables{
+		ReportId:  order.ReportID,
+		Status:    string(order.Status),
+		Label:     order.Status.Label(),
+		Detail:    order.Detail,
+		UpdatedAt: order.UpdatedAt.Local().Format("01-02-2006 03:04 PM"),
+		Message:   message,
 	}
-	return reportId
 }
 
-//This function checks if the order exist when the user tries to places an order, so they do not reorder
-func checkExistingOrder(db *sql.DB, address Address) sql.NullString {
-	res, err := db.Query("SELECT reportId FROM OrderHistory where street=(?) and city=(?) and state=(?) and zipcode=(?)", address.Street, address.City, address.State, address.Zip)
+//This function displays the order status page
+func statusPage(w http.ResponseWriter, brand Branding, vars StatusPageVariables) {
+	vars.Brand = brand
+	t, err := template.ParseFiles("html/status.html", "html/brand.html")
 	if err != nil {
-		panic(err.Error())
+		log.Print("template parsing error: ", err)
+		return
 	}
-	var reportId sql.NullString
-	for res.Next() {
-		err = res.Scan(&reportId)
-		if err != nil {
-			panic(err.Error())
-		}
+	if err := t.Execute(w, vars); err != nil {
+		log.Print("template executing error: ", err)
 	}
-	return reportId
 }
 
-//This function retrieves data from NREL API
-func NRELData(address Address) (Response, string) {
-	result := strings.ReplaceAll(fmt.Sprintf("%s,%s,%s %s", address.Street, address.City, address.State, address.Zip), " ", "")
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://developer.nrel.gov/api/pvwatts/v6.json?api_key=bBU3vPPeFsRjGPWEjNUcU0Z4RNpvMZ4ufmlXiYCF&address=%s&system_capacity=0.08&azimuth=180&tilt=40&array_type=1&module_type=1&losses=10", result), nil)
+//This Function place order for report and inputs data for NREL based on retrieved values, the order belongs to buyer and their organization
+func (s *server) order(ctx context.Context, token eagleview.Token, buyer accounts.User, org orgs.Organization, addressInput Address, charge PaymentInfo, ref OrderReference) (eagleview.OrderStats, error) {
+	order, err := s.placeOrder(ctx, token, addressInput, ref)
 	if err != nil {
-		log.Print(err.Error())
+		return order, err
 	}
-
-	resp, err := client.Do(req)
+	if len(order.ReportIds) == 0 {
+		return order, errors.New("eagleview returned no report id for the order")
+	}
+	reportId := strconv.Itoa(order.ReportIds[0])
+
+	//The order is placed at this point, storage errors are logged rather than returned
+	err = s.store.SaveOrder(ctx, storage.OrderRecord{
+		FirstName:  addressInput.FirstName,
+		LastName:   addressInput.LastName,
+		Email:      addressInput.Email,
+		Street:     addressInput.Street,
+		City:       addressInput.City,
+		State:      addressInput.State,
+		Zip:        addressInput.Zip,
+		ReportID:   reportId,
+		ReportType: addressInput.TypeRep,
+		UserID:     buyer.ID,
+		OrgID:      org.ID,
+		Price:      org.Price(addressInput.TypeRep),
+		PaymentID:  charge.PaymentID,
+		CardBrand:  string(charge.Card.Brand),
+		CardLast4:  charge.Card.Last4,
+	})
 	if err != nil {
-		log.Print(err.Error())
+		log.Printf("saving order %s: %v", reportId, err)
+	}
+	if err := s.store.CreateOrder(ctx, reportId); err != nil {
+		log.Printf("saving status of order %s: %v", reportId, err)
 	}
 
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err := s.saveNREL(ctx, reportId, addressInput); err != nil {
+		//The report fetches it again on its first view
+		log.Printf("NREL data of order %s: %v", reportId, err)
+	}
+	return order, nil
+}
 
+//This function fetches and stores the PVWatts estimate of the address of a report, nothing is stored when NREL fails
+func (s *server) saveNREL(ctx context.Context, reportId string, address Address) error {
+	responseObject, err := s.NRELData(ctx, address)
 	if err != nil {
-		log.Print(err.Error())
+		return err
 	}
+	return s.store.SaveNREL(ctx, storage.NRELResult{
+		ReportID:       reportId,
+		Street:         address.Street,
+		City:           address.City,
+		State:          address.State,
+		Zip:            address.Zip,
+		Lat:            responseObject.StationInfo.Lat,
+		Lon:            responseObject.StationInfo.Lon,
+		Azimuth:        responseObject.Inputs.Azimuth,
+		Tilt:           responseObject.Inputs.Tilt,
+		SolradAnnual:   responseObject.Outputs.SolradAnnual,
+		AcAnnual:       responseObject.Outputs.AcAnnual,
+		CapacityFactor: responseObject.Outputs.CapacityFactor,
+		Monthly:        monthlyNREL(responseObject),
+	})
+}
 
-	var responseObject Response
-	json.Unmarshal((bodyBytes), &responseObject)
-	return responseObject, resp.Status
+//This function returns the stored data of a report. Orders placed while NREL failed have no NREL data stored,
+//it is fetched again here so the report shows once NREL answers
+func (s *server) reportData(ctx context.Context, reportId string) (storage.ReportData, error) {
+	data, err := s.store.ReportData(ctx, reportId)
+	if !errors.Is(err, storage.ErrNotFound) {
+		return data, err
+	}
+	record, err := s.store.OrderDetails(ctx, reportId)
+	if err != nil {
+		return data, err
+	}
+	address := Address{Street: record.Street, City: record.City, State: record.State, Zip: record.Zip}
+	if err := s.saveNREL(ctx, reportId, address); err != nil {
+		return data, fmt.Errorf("NREL data of report %s: %w", reportId, err)
+	}
+	return s.store.ReportData(ctx, reportId)
 }
 
-//This function insert data into the NREL table
-func nrelToDb(responseObject Response, db *sql.DB, address Address) {
-	insert, err := db.Query("INSERT INTO NREL VALUES (?,?,?,?,?,?,?,?,?,?,?)", address.Street, address.City, address.State, address.Zip, responseObject.StationInfo.Lat, responseObject.StationInfo.Lon, responseObject.Inputs.Azimuth, responseObject.Inputs.Tilt, responseObject.Outputs.SolradAnnual, responseObject.Outputs.AcAnnual)
+//This function pairs up the monthly PVWatts series, PVWatts always returns twelve of each
+func monthlyNREL(response nrel.Response) []storage.MonthlyNREL {
+	outputs := response.Outputs
+	var months []storage.MonthlyNREL
+	for i := 0; i < 12 && i < len(outputs.AcMonthly); i++ {
+		month := storage.MonthlyNREL{Month: i + 1, AC: outputs.AcMonthly[i]}
+		if i < len(outputs.PoaMonthly) {
+			month.POA = outputs.PoaMonthly[i]
+		}
+		if i < len(outputs.SolradMonthly) {
+			month.Solrad = outputs.SolradMonthly[i]
+		}
+		if i < len(outputs.DcMonthly) {
+			month.DC = outputs.DcMonthly[i]
+		}
+		months = append(months, month)
+	}
+	return months
+}
 
+//This function puts the address of an order in its canonical form, the form orders are stored and compared in
+//Addresses are stored lower cased like before, so the pages show them the same way
+func canonicalAddress(address Address) (Address, postal.Address, error) {
+	canonical, err := postal.Parse(address.Street, address.City, address.State, address.Zip)
 	if err != nil {
-		panic(err.Error())
+		return address, canonical, err
 	}
-
-	defer insert.Close()
+	address.Street = strings.ToLower(canonical.Line1())
+	address.City = strings.ToLower(canonical.City)
+	address.State = strings.ToLower(canonical.State)
+	address.Zip = canonical.ZipCode()
+	return address, canonical, nil
 }
 
-//This Function is used generate authentication token based on eagleview credentials
-func eagleViewToken() (Token, string) {
-	const clientSecret = "eZ6FNfStCJs3epr8tCCgXuMD8cgTeDb3WJxcWFt9jAbTzWLsmw6rY7pT8rsDRnHM"
-	const SourceID = "36c184a9-1686-4761-976b-8e4ba4ed4f1b "
-	const Endpoint = "https://webservices.eagleview.com/Token"
-	sEnc := b64.StdEncoding.EncodeToString([]byte(SourceID + ":" + clientSecret))
-	data := url.Values{}
-	data.Add("grant_type", "password&username=ryonfaroughi@gmail.com&password=EagleView@1")
+//This function retrieves data from NREL API for the address of the building, assuming a south facing 40 degree roof
+func (s *server) NRELData(ctx context.Context, address Address) (nrel.Response, error) {
+	location := fmt.Sprintf("%s, %s, %s %s", address.Street, address.City, address.State, address.Zip)
+	if canonical, err := postal.Parse(address.Street, address.City, address.State, address.Zip); err == nil {
+		location = canonical.Location()
+	}
+	return s.nrel.PVWatts(ctx, nrel.Request{
+		Address:        location,
+		SystemCapacity: 0.08,
+		Azimuth:        180,
+		Tilt:           40,
+		ArrayType:      1,
+		ModuleType:     1,
+		Losses:         10,
+	})
+}
 
-	req, err := http.NewRequest("POST", Endpoint, bytes.NewBuffer([]byte("grant_type=password&username=ryonfaroughi@gmail.com&password=EagleView@1")))
+//This function returns the production of every roof facet, estimating and storing it on the first view
+//Facets PVWatts failed for are stored as failed and estimated again on the next view, the others are kept
+func (s *server) roofProduction(ctx context.Context, reportId string, tablres radiance.Model) []storage.RoofProduction {
+	stored, err := s.store.RoofProduction(ctx, reportId)
 	if err != nil {
 		log.Print(err.Error())
 	}
-	req.Header.Add("Host", "webservices.eagleview.com")
-	req.Header.Add("Authorization", "Basic "+sEnc)
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	retry := make(map[string]bool)
+	for _, roof := range stored {
+		if roof.Failed {
+			retry[roof.Designator] = true
+		}
+	}
+	if (len(stored) > 0 && len(retry) == 0) || len(tablres.Roofs) == 0 {
+		return stored
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		panic(err.Error())
+	var planned []sizing.Facet
+	var facets []production.Facet
+	for _, roof := range tablres.Roofs {
+		tilt, ok := production.ParseTilt(roof.PitchDeg, roof.Pitch)
+		if !ok {
+			log.Printf("report %s: roof %s has no usable pitch", reportId, roof.Designator)
+			continue
+		}
+		area, _ := strconv.ParseFloat(strings.TrimSpace(roof.Unroundedsize), 64)
+		irradiance := facetIrradiance(roof)
+		if !irradiance.HasAnnual {
+			log.Printf("report %s: roof %s has no irradiance data", reportId, roof.Designator)
+		}
+		facet := production.Facet{
+			Designator: roof.Designator,
+			Azimuth:    production.NormalizeAzimuth(roof.Orientation),
+			Tilt:       tilt,
+			AreaSqFt:   area,
+		}
+		//A facet without irradiance data is estimated unshaded, a solar access of 0 is fully shaded
+		if irradiance.HasAnnual {
+			facet.SolarAccess = &irradiance.Annual.Sa
+		}
+		if irradiance.hasMonthly() {
+			//Months without a value are left nil and fall back to the annual solar access
+			facet.SolarAccessMonthly = make([]*float64, 12)
+			for month, value := range irradiance.Monthly {
+				if value != nil {
+					facet.SolarAccessMonthly[month] = &value.Sa
+				}
+			}
+		}
+		planned = append(planned, sizing.Facet{Designator: roof.Designator, AreaSqFt: area, TSRF: irradiance.Annual.Tsrf})
+		facets = append(facets, facet)
 	}
 
-	//fmt.Println(resp.Status)
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	//Only the facets the layout put panels on are estimated, the skipped ones are stored without production
+	layout, err := sizing.Plan(s.sizing, planned)
+	if err != nil {
+		log.Printf("laying out report %s: %v", reportId, err)
+		return stored
+	}
+	var roofs []storage.RoofProduction
+	var estimated []production.Facet
+	for i, facet := range facets {
+		if len(stored) > 0 && !retry[facet.Designator] {
+			continue
+		}
+		facetLayout := layout.Facets[i]
+		if facetLayout.Panels == 0 {
+			log.Printf("report %s: roof %s skipped, %s", reportId, facet.Designator, facetLayout.Skipped)
+			roofs = append(roofs, storage.RoofProduction{
+				Designator: facet.Designator,
+				Azimuth:    facet.Azimuth,
+				Tilt:       facet.Tilt,
+				AreaSqFt:   facet.AreaSqFt,
+				Module:     layout.Module.Name,
+			})
+			continue
+		}
+		facet.SystemCapacity = facetLayout.SystemKw
+		estimated = append(estimated, facet)
+	}
 
+	estimates, err := s.production.Estimate(ctx, tablres.Location.Latitude, tablres.Location.Longitude, estimated)
 	if err != nil {
+		log.Printf("estimating production of report %s: %v", reportId, err)
+	}
+	panels := make(map[string]int, len(layout.Facets))
+	for _, facetLayout := range layout.Facets {
+		panels[facetLayout.Designator] = facetLayout.Panels
+	}
+	for _, estimate := range estimates {
+		roofs = append(roofs, storage.RoofProduction{
+			Designator:     estimate.Designator,
+			Azimuth:        estimate.Azimuth,
+			Tilt:           estimate.Tilt,
+			AreaSqFt:       estimate.AreaSqFt,
+			SystemCapacity: estimate.SystemCapacity,
+			AcAnnual:       estimate.AcAnnual,
+			SolradAnnual:   estimate.SolradAnnual,
+			AcMonthly:      estimate.AcMonthly,
+			Panels:         panels[estimate.Designator],
+			Module:         layout.Module.Name,
+			Failed:         estimate.Err != nil,
+		})
+	}
+	//Facets estimated on an earlier view keep their stored production
+	for _, roof := range stored {
+		if !retry[roof.Designator] {
+			roofs = append(roofs, roof)
+		}
+	}
+	if err := s.store.SaveRoofProduction(ctx, reportId, roofs); err != nil {
 		log.Print(err.Error())
 	}
-
-	var TokenObject Token
-	json.Unmarshal((bodyBytes), &TokenObject)
-	return TokenObject, resp.Status
+	return roofs
 }
 
-//This function places order for user for select report
-func placeOrder(TokenObject Token, address Address, payment PaymentInfo) (OrderStats, string) {
+//This function places order for user for select report, billed to the EagleView account since the customer pays through the payment provider
+//The references of ref are passed on to EagleView, they are empty for single orders
+func (s *server) placeOrder(ctx context.Context, token eagleview.Token, address Address, ref OrderReference) (eagleview.OrderStats, error) {
 	var reportType int
 
-	if address.TypeRep == "Basic" {
-		reportType = 11
-	} else if address.TypeRep == "Advanced" {
-		reportType = 62
+	if strings.EqualFold(address.TypeRep, "Basic") {
+		reportType = eagleview.ProductBasic
+	} else if strings.EqualFold(address.TypeRep, "Advanced") {
+		reportType = eagleview.ProductAdvanced
 	}
 
-	fmt.Printf("REPORT TYPE: %d\n\n", reportType)
-	orderData := OrderInfo{
-		OrderReports: []OrderReports{{
-			ReportAddresses: []ReportAddresses{{
+	orderData := eagleview.OrderInfo{
+		OrderReports: []eagleview.OrderReports{{
+			ReportAddresses: []eagleview.ReportAddresses{{
 				Address:                  address.Street,
 				City:                     address.City,
 				State:                    address.State,
@@ -437,257 +586,130 @@ func placeOrder(TokenObject Token, address Address, payment PaymentInfo) (OrderS
 			PrimaryProductID:           reportType,
 			DeliveryProductID:          8,
 			MeasurementInstructionType: 1,
-			ClaimNumber:                "",
+			ClaimNumber:                ref.ClaimNumber,
 			ClaimInfo:                  "",
-			BatchID:                    "",
+			BatchID:                    ref.BatchID,
 			ChangesInLast4Years:        true,
-			PONumber:                   "",
+			PONumber:                   ref.PONumber,
 			Comments:                   "Roof Report",
-			ReferenceID:                "",
-			InsuredName:                ""}},
-		CreditCardData: CreditCardData{
-			CardFirstName:    address.FirstName,
-			CardLastName:     address.LastName,
-			ExpirationMonth:  payment.ExpireMonth,
-			ExpirationYear:   payment.ExpireYear,
-			CreditCardNumber: payment.CardNum,
-			CreditCardType:   payment.CardType,
-		}}
-
-	jsonData, err := json.Marshal(orderData)
-	if err != nil {
-		log.Println(err.Error())
-	}
-	jsonByte := []byte(jsonData)
-	const Endpoint = "https://webservices-integrations.eagleview.com/v2/Order/PlaceOrder"
-	req, err := http.NewRequest("POST", Endpoint, bytes.NewBuffer([]byte(jsonByte)))
-	if err != nil {
-		log.Print(err.Error())
-	}
-	req.Header.Add("Host", "webservices-integrations.eagleview.com")
-	req.Header.Add("Authorization", TokenObject.TokenType+" "+TokenObject.AccessToken)
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Print(err.Error())
-	}
-
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		log.Print(err.Error())
-	}
-
-	var order OrderStats
-	json.Unmarshal((bodyBytes), &order)
-
-	return order, resp.Status
+			ReferenceID:                ref.ReferenceID,
+			InsuredName:                ""}}}
+
+	//A 401 is retried once with a fresh token, the card is already authorized by now
+	var order eagleview.OrderStats
+	err := eagleview.Retry(ctx, s.tokens, token, func(token eagleview.Token) (err error) {
+		order, err = s.ev.PlaceOrder(ctx, token, orderData)
+		return err
+	})
+	return order, err
 }
 
-/**
-Use EagleView API to get Image data and return it as 64encoded bytes
-**/
-func getReportImage(TokenObject Token, reportId string, imageType int) ([]byte, string) {
-	var Endpoint = "https://webservices.eagleview.com/v1/File/GetReportFile?reportId=" + reportId + "&fileType=" + strconv.Itoa(imageType) + "&fileFormat=1" //+ strconv.Itoa(imageType)
-	req, err := http.NewRequest("GET", Endpoint, nil)
-	if err != nil {
-		//Handle Error
-		log.Print(err.Error())
-	}
-	req.Header.Add("Host", "webservices.eagleview.com")
-	req.Header.Add("Authorization", TokenObject.TokenType+" "+TokenObject.AccessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Print(err.Error())
-	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Print("Error reading http response body\n")
-	}
-
-	//Convert bytes to images
-	byteArray := []byte(bodyBytes)
-	return byteArray, resp.Status
+//This function downloads the pdf for users
+func downloadPDF(w http.ResponseWriter, r *http.Request, reportData []byte) {
+	w.Header().Set("Content-Disposition", "attachment; filename=SampleReportTest.pdf")
+	w.Header().Set("Content-Type", r.Header.Get("application/pdf"))
+	w.Write(reportData)
 }
 
-//This function Retrieves various reports files from eagleview
-func getReportFile(TokenObject Token, reportId string, reportType int, fileType int) ([]byte, int64, string) {
-	var Endpoint = "https://webservices.eagleview.com/v1/File/GetReportFile?reportId=" + reportId + "&fileType=" + strconv.Itoa(reportType) + "&fileFormat=" + strconv.Itoa(fileType)
-	req, err := http.NewRequest("GET", Endpoint, nil)
-	if err != nil {
-		log.Print(err.Error())
+//Display HTML page with data retrieved from DB for the advanced report
+func (s *server) DisplayPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
 	}
-	req.Header.Add("Host", "webservices.eagleview.com")
-	req.Header.Add("Authorization", TokenObject.TokenType+" "+TokenObject.AccessToken)
+	session, _ := cookieStore.Get(r, sessionName)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	var reportId string = fmt.Sprint(session.Values["reportId"])
+	var tablres radiance.Model
+	var reportData []byte
+	var modelErr error
+	var err error
+	ctx := r.Context()
+	token, err := s.tokens.Token(ctx)
 	if err != nil {
 		log.Print(err.Error())
 	}
-
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	owned, err := s.ownsReport(ctx, user, reportId)
 	if err != nil {
 		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
 	}
-	byteArray := []byte(bodyBytes)
-	//fmt.Println(string(byteArray))
-	return byteArray, resp.ContentLength, resp.Status
-}
-
-//Retrieve Data from SQL database
-//Return struct containing db data
-func retrieveData(db *sql.DB, reportId string) queryResult {
-	var data queryResult
-	res, err := db.Query("SELECT street,city,state,zipcode,azimuth,tilt,solrad_annual,ac_annual,reportId FROM OrderHistory Natural join NREL WHERE reportId=(?)", reportId)
-	if err != nil {
-		panic(err.Error())
+	if !owned {
+		http.NotFound(w, r)
+		return
 	}
-	for res.Next() {
-
-		err = res.Scan(&data.street, &data.city, &data.state, &data.zipcode, &data.azimuth, &data.tilt, &data.solar_annual, &data.ac_annual, &data.reportId)
-
-		if err != nil {
-			panic(err.Error())
-		}
+	data, err := s.reportData(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
 	}
-	return data
-}
-
-//Convert byte array into PNG format
-//Return Base64Encoded PNG image in String format
-func DisplayImage(imageData []byte) (string, error) {
-	img, err := jpeg.Decode(bytes.NewReader(imageData))
-	var buf bytes.Buffer
 	if err != nil {
-		log.Print("Unable to decode JPEG")
-	}
-
-	if err := png.Encode(&buf, img); err != nil {
-		log.Print("ERROR")
+		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
 	}
-
-	imageData = buf.Bytes()
-	return b64.StdEncoding.EncodeToString(imageData), err
-}
-
-//This function downloads the pdf for users
-func downloadPDF(w http.ResponseWriter, r *http.Request, reportData []byte) {
-	w.Header().Set("Content-Disposition", "attachment; filename=SampleReportTest.pdf")
-	w.Header().Set("Content-Type", r.Header.Get("application/pdf"))
-	w.Write(reportData)
-}
-
-//Display HTML page with data retrieved from DB for the advanced report
-func DisplayPage(w http.ResponseWriter, r *http.Request) {
-	//read data temporarly for testing
-	session, _ := store.Get(r, "cookie-name")
-
-	var reportId string = fmt.Sprint(session.Values["reportId"])
-	var imageBaseStr [5]string
-	var b2 []byte
-	var b3 []byte
-	var b4 []byte
-	var b5 []byte
-	var b6 []byte
-	var data queryResult
-	var tablres ExportEV
-	var reportData []byte
-	var jsonData []byte
-	var err error
-	token, _ := eagleViewToken()
-	db, _ := connectDb()
-	fmt.Printf("Report: %s\n", reportId)
 	var waitgroup sync.WaitGroup
-	waitgroup.Add(7)
-
-	var url string
-	data = retrieveData(db, reportId)
+	waitgroup.Add(2)
 
 	go func() {
 		defer waitgroup.Done()
-		url, _ = downloadReport(token, reportId)
-		getJsonFile(url, reportId)
+		tablres, modelErr = s.radianceModel(ctx, token, reportId)
 	}()
 
 	go func() {
 		defer waitgroup.Done()
-		b2, _ = getReportImage(token, reportId, 6)
-		imageBaseStr[0], _ = DisplayImage(b2)
-	}()
-
-	go func() {
-		defer waitgroup.Done()
-		b3, _ = getReportImage(token, reportId, 22)
-		imageBaseStr[1], _ = DisplayImage(b3)
-	}()
-
-	go func() {
-		defer waitgroup.Done()
-		b4, _ = getReportImage(token, reportId, 23)
-		imageBaseStr[2], _ = DisplayImage(b4)
-	}()
-
-	go func() {
-		defer waitgroup.Done()
-		b5, _ = getReportImage(token, reportId, 24)
-		imageBaseStr[3], _ = DisplayImage(b5)
-	}()
-
-	go func() {
-		defer waitgroup.Done()
-		b6, _ = getReportImage(token, reportId, 25)
-		imageBaseStr[4], _ = DisplayImage(b6)
-	}()
-
-	go func() {
-		defer waitgroup.Done()
-		reportData, _, _ = getReportFile(token, reportId, 75, 2)
+		reportData, _ = s.reportFile(ctx, token, reportId, eagleview.FileAdvancedReport, eagleview.FormatPDF)
 	}()
 
 	waitgroup.Wait()
-	waitgroup.Add(1)
-	go func() {
-		defer waitgroup.Done()
-		if len(jsonData) == 0 {
-			jsonData, _ = os.ReadFile("RadianceModel" + reportId + ".json")
-			if err != nil {
-				log.Println(err.Error())
-			}
-		}
-	}()
-
-	waitgroup.Wait()
-	var tab ExportEV
-	tablres = unmarshalJSON(jsonData, tab)
 	if r.Method == http.MethodPost {
 		downloadPDF(w, r, reportData)
+		return
+	}
+
+	roofs := s.roofProduction(ctx, reportId, tablres)
+	system := summarizeRoofs(roofs)
+
+	monthly, err := s.store.NRELMonthly(ctx, reportId)
+	if err != nil {
+		log.Print(err.Error())
+	}
+	productionChart, irradianceChart := reportCharts(monthly, roofs)
+
+	//The savings analysis needs the facet estimates, reports without them show none
+	var analysis *finance.Analysis
+	if result, err := finance.Analyze(system.SystemKw, system.AcAnnual, s.finance); err == nil {
+		analysis = &result
 	}
 
 	var SIZE int = len(tablres.Roofs)
 	var JsonRes = make([]ReportResult, SIZE)
-	JsonRes = convertJsonToStruct(JsonRes, tablres, data)
+	JsonRes = convertJsonToStruct(JsonRes, tablres, data, roofs)
 
 	HomePageVars := PageVariables{ //store the date and time in a struct
-		Address:    fmt.Sprintf("%s, %s, %s %s", data.street, data.city, data.state, data.zipcode),
-		Ac_annual:  data.solar_annual * 365,
-		ReportId:   data.reportId,
-		TopImage:   imageBaseStr[0],
-		NorthImage: imageBaseStr[1],
-		SouthImage: imageBaseStr[2],
-		EastImage:  imageBaseStr[3],
-		WestImage:  imageBaseStr[4],
-		JsonMes:    JsonRes,
-	}
-
-	t, err := template.ParseFiles("html/advanceReport.html")
+		Brand:     brandOf(s.organization(ctx, data.OrgID)),
+		Address:   fmt.Sprintf("%s, %s, %s %s", data.Street, data.City, data.State, data.Zip),
+		Ac_annual: data.SolradAnnual * 365,
+		SystemKw:  system.SystemKw,
+		AcAnnual:  system.AcAnnual,
+		Panels:    system.Panels,
+		Module:    system.Module,
+		ReportId:  data.ReportID,
+		JsonMes:   JsonRes,
+
+		SolarAccess:     solarAccessMatrix(JsonRes),
+		CapacityFactor:  data.CapacityFactor,
+		ProductionChart: productionChart,
+		IrradianceChart: irradianceChart,
+		Finance:         analysis,
+	}
+	//The rest of the report is still shown when the roof model cannot be read
+	if modelErr != nil {
+		HomePageVars.ModelError, HomePageVars.ModelProblems = modelProblem(modelErr)
+	}
+
+	t, err := template.ParseFiles("html/advanceReport.html", "html/brand.html")
 	if err != nil { // if there is an error
 		log.Print("template executing error: ", err) //log it
 	}
@@ -701,111 +723,250 @@ func DisplayPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+//This function draws the monthly charts of the advanced report. Production is the sum of the roof facets,
+//falling back to the reference system of the address when the facets have no monthly estimate
+func reportCharts(monthly []storage.MonthlyNREL, roofs []storage.RoofProduction) (template.HTML, template.HTML) {
+	var productionChart, irradianceChart template.HTML
+
+	facetTotal := make([]float64, 12)
+	var haveFacets bool
+	for _, roof := range roofs {
+		for i := 0; i < 12 && i < len(roof.AcMonthly); i++ {
+			facetTotal[i] += roof.AcMonthly[i]
+			haveFacets = true
+		}
+	}
+	if haveFacets {
+		productionChart = charts.Bar(charts.Chart{
+			Title:  "Estimated Monthly Production",
+			Unit:   "kWh",
+			Labels: charts.Months,
+			Values: facetTotal,
+		})
+	} else if len(monthly) == 12 {
+		reference := make([]float64, 12)
+		for i, month := range monthly {
+			reference[i] = month.AC
+		}
+		productionChart = charts.Bar(charts.Chart{
+			Title:  "Monthly Production of a Reference System",
+			Unit:   "kWh",
+			Labels: charts.Months,
+			Values: reference,
+		})
+	}
+
+	if len(monthly) == 12 {
+		solrad := make([]float64, 12)
+		for i, month := range monthly {
+			solrad[i] = month.Solrad
+		}
+		irradianceChart = charts.Line(charts.Chart{
+			Title:  "Average Daily Solar Irradiance",
+			Unit:   "kWh/m2/day",
+			Labels: charts.Months,
+			Values: solrad,
+			Color:  "#f0a30a",
+		})
+	}
+	return productionChart, irradianceChart
+}
+
 //This function is where it asks user for payment for the report they are trying to place
-func payment(w http.ResponseWriter, r *http.Request) {
+func (s *server) payment(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
+	}
+	org := s.organization(r.Context(), user.OrgID)
+	page := PaymentPageVariables{Brand: brandOf(org), BasicPrice: org.BasicPrice, AdvancedPrice: org.AdvancedPrice, CardForm: s.cardForm()}
 	if r.Method == "GET" {
-		t, _ := template.ParseFiles("html/payment.html")
-		t.Execute(w, nil)
+		paymentForm(w, http.StatusOK, page)
 	} else {
-		r.ParseForm()
-		token, _ := eagleViewToken()
-		var paymentInput PaymentInfo
-		paymentInput.CardNum = r.FormValue("cardnumber")
-		paymentInput.ExpireMonth, _ = strconv.Atoi(r.FormValue("expmonth"))
-		paymentInput.ExpireYear, _ = strconv.Atoi(r.FormValue("expyear"))
-		/*Billing Input and Report Address*/
-		var billInput Address
-		billInput.FirstName = strings.ToLower(r.FormValue("firstname"))
-		billInput.LastName = strings.ToLower(r.FormValue("lastname"))
-		billInput.Street = strings.ToLower(r.FormValue("address"))
-		billInput.City = strings.ToLower(r.FormValue("city"))
-		billInput.State = strings.ToLower(r.FormValue("state"))
-		billInput.Zip = strings.ToLower(r.FormValue("zip"))
-		billInput.TypeRep = strings.ToLower(r.FormValue("Report Type"))
-		billInput.Email = strings.ToLower(r.FormValue("email"))
-
-		db, _ := connectDb()
-		report := checkExistingOrder(db, billInput)
-
-		//Credit card Validation Test
-		card := creditcard.Card{Number: paymentInput.CardNum, Cvv: " ", Month: strconv.Itoa(paymentInput.ExpireMonth), Year: strconv.Itoa(paymentInput.ExpireYear)}
-		err := card.Method()
-		if err != nil {
-			fmt.Print(err.Error())
+		input := paymentFormInput(r)
+		page.Form, page.AddressErrors = input.Form, input.AddressErrors
+		if input.PaymentMethod == "" {
+			page.Error = "Enter your card."
 		}
-		var cid int = 0
-		var ctype string = card.Company.Long
-		switch ctype {
-		case "MasterCard":
-			cid = 3
-		case "Visa":
-			cid = 2
-		case "Discover":
-			cid = 4
-		case "AmericanExpress":
-			cid = 1
+		if page.Error != "" || page.AddressErrors != nil {
+			paymentForm(w, http.StatusUnprocessableEntity, page)
+			return
 		}
 
-		if cid == 0 {
-			log.Println("This Credit Card Type is not Supported by Eagleview")
-
-		} else {
-			paymentInput.CardType = cid
+		report, err := s.store.FindReportByAddress(r.Context(), input.Canonical)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Print(err.Error())
+			http.Error(w, "Orders are unavailable right now, please try again later", http.StatusInternalServerError)
+			return
+		}
+		if report != "" {
+			http.Redirect(w, r, s.cfg.Server.ReportURL+"/formpage", http.StatusFound)
+			return
 		}
 
-		if report.Valid {
-			http.Redirect(w, r, "http://localhost:9090/formpage", http.StatusFound)
-		} else {
-			/*Placing Order Once Credit Card has been determined*/
-			order := order(token, billInput, paymentInput, db)
-			invoice := invoice(billInput, order)
+		cardToken, err := s.payments.PaymentMethod(r.Context(), input.PaymentMethod)
+		if errors.Is(err, payment.ErrUnknownMethod) {
+			page.Error = "Your card could not be read, please enter it again."
+			paymentForm(w, http.StatusUnprocessableEntity, page)
+			return
+		}
+		//The script of the page checks the expiry, a card sent without it is checked before anything is authorized
+		var invalid *payment.ValidationError
+		if err == nil && errors.As(cardToken.Validate(time.Now()), &invalid) {
+			page.CardForm.Errors = invalid.Fields
+			paymentForm(w, http.StatusUnprocessableEntity, page)
+			return
+		}
+		var invoice []byte
+		if err == nil {
+			//Without an EagleView token the order cannot be placed, so the card is not authorized
+			var token eagleview.Token
+			token, err = s.tokens.Token(r.Context())
+			if err == nil {
+				_, invoice, err = s.checkout(r.Context(), token, user, input.Billing, cardToken)
+			}
+		}
+		var declined *payment.DeclinedError
+		switch {
+		case err == nil:
 			downloadPDF(w, r, invoice)
+		case errors.Is(err, errInvoice):
+			log.Print(err.Error())
+			http.Error(w, "Your order was placed but its invoice could not be created, you can follow the order at "+s.cfg.Server.ReportURL+"/formpage", http.StatusInternalServerError)
+		case errors.As(err, &declined):
+			page.Error = declined.Reason + " Please use another card."
+			paymentForm(w, http.StatusPaymentRequired, page)
+		default:
+			log.Print(err.Error())
+			http.Error(w, "The order could not be placed, please try again later", http.StatusBadGateway)
 		}
-
-		billInput.FirstName = ""
-		billInput.LastName = ""
-		paymentInput.CardNum = ""
-		paymentInput.ExpireMonth = 0
-		paymentInput.ExpireYear = 0
 	}
 }
 
-//This function unmarshals JSON in to struct using threads
-func unmarshalJSON(jsonData []byte, src ExportEV) ExportEV {
-	var wg sync.WaitGroup
+//Values sent with the payment forms of the payment and batch pages
+type PaymentInput struct {
+	//PaymentMethod is the id of the card tokenized by the script of the page, empty when none was sent
+	PaymentMethod string
+	//Billing is the billing address and report type, in the canonical form when the address is valid
+	Billing   Address
+	Canonical postal.Address
+	//Form holds the values to show the form again with, the card is entered again
+	Form url.Values
+	//AddressErrors are the problems of the billing address by field: street, city, state and zip
+	AddressErrors map[string]string
+}
 
-	out := make(chan []byte)
-	wg.Add(5)
-	for i := 0; i < 5; i++ {
-		go func(w *sync.WaitGroup, in <-chan []byte) {
-			defer w.Done()
-			for input := range in {
-				json.Unmarshal(input, &src)
-			}
-		}(&wg, out)
+//This function reads the billing address and the payment method sent with a payment form
+//Names and emails are stored lower cased and the address in its canonical form
+func paymentFormInput(r *http.Request) PaymentInput {
+	r.ParseForm()
+	input := PaymentInput{
+		PaymentMethod: strings.TrimSpace(r.FormValue("paymentMethod")),
+		Billing: Address{
+			FirstName: strings.ToLower(r.FormValue("firstname")),
+			LastName:  strings.ToLower(r.FormValue("lastname")),
+			Email:     strings.ToLower(r.FormValue("email")),
+			Street:    strings.ToLower(r.FormValue("address")),
+			City:      strings.ToLower(r.FormValue("city")),
+			State:     strings.ToLower(r.FormValue("state")),
+			Zip:       strings.ToLower(r.FormValue("zip")),
+			TypeRep:   strings.ToLower(r.FormValue("Report Type")),
+		},
+		Form: url.Values{},
+	}
+	for _, field := range []string{"firstname", "lastname", "email", "address", "city", "state", "zip", "Report Type"} {
+		input.Form.Set(field, r.FormValue(field))
+	}
+	var err error
+	input.Billing, input.Canonical, err = canonicalAddress(input.Billing)
+	var invalidAddress *postal.ValidationError
+	if errors.As(err, &invalidAddress) {
+		input.AddressErrors = invalidAddress.Fields
+	}
+	return input
+}
 
-		out <- jsonData
+//This function shows the payment page with the HTTP status given
+func paymentForm(w http.ResponseWriter, status int, page PaymentPageVariables) {
+	t, err := template.ParseFiles("html/payment.html", "html/brand.html")
+	if err != nil {
+		log.Print("template parsing error: ", err)
+		http.Error(w, "The page is unavailable right now", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if err := t.Execute(w, page); err != nil {
+		log.Print("template executing error: ", err)
 	}
-	close(out)
-	wg.Wait()
-	return src
 }
 
-//This function downloads the invoice for order on successful transaction
-func invoice(billing Address, order OrderStats) []byte {
-	var price int
-	if billing.TypeRep == "Basic" {
-		price = 75
-	} else {
-		price = 100
+//This function charges the card of buyer and places their order, stores its invoice and emails the confirmation, returning the invoice PDF issued by their organization
+//The card is only authorized until EagleView accepts the order, a failed order releases the authorization instead of charging it
+func (s *server) checkout(ctx context.Context, token eagleview.Token, buyer accounts.User, billing Address, card payment.Token) (eagleview.OrderStats, []byte, error) {
+	org := s.organization(ctx, buyer.OrgID)
+	charge := PaymentInfo{Card: card}
+	var err error
+	amount := org.Price(billing.TypeRep) * 100
+	description := fmt.Sprintf("%s %s report, %s", org.Name, billing.TypeRep, billing.Street)
+	charge.PaymentID, err = s.payments.Authorize(ctx, card, amount, description)
+	if err != nil {
+		return eagleview.OrderStats{}, nil, err
+	}
+	order, err := s.order(ctx, token, buyer, org, billing, charge, OrderReference{})
+	if err != nil {
+		if refundErr := s.payments.Refund(ctx, charge.PaymentID); refundErr != nil {
+			log.Printf("releasing payment %s: %v", charge.PaymentID, refundErr)
+		}
+		return order, nil, err
+	}
+	//EagleView accepted the order, a failed capture is logged and the payment id shown on the admin page to settle it with the provider
+	if err := s.payments.Capture(ctx, charge.PaymentID, amount); err != nil {
+		log.Printf("capturing payment %s: %v", charge.PaymentID, err)
+	}
+	reportId := strconv.Itoa(order.ReportIds[0])
+	invoice, err := invoice(org, billing, order)
+	if err != nil {
+		//The order stands, the confirmation is sent without the invoice
+		s.notifyOrderPlaced(org, billing, reportId, nil)
+		return order, nil, fmt.Errorf("%w for report %s: %v", errInvoice, reportId, err)
+	}
+	err = s.store.SaveArtifact(ctx, storage.Artifact{
+		ReportID:    reportId,
+		Kind:        storage.ArtifactInvoice,
+		ContentType: "application/pdf",
+		Data:        invoice,
+	})
+	if err != nil {
+		log.Print(err.Error())
 	}
+	s.notifyOrderPlaced(org, billing, reportId, invoice)
+	return order, invoice, nil
+}
 
-	curentTime := time.Now()
-	doc, _ := generator.New(generator.Invoice, &generator.Options{
+//Returned by checkout when the order was placed and paid for but its invoice could not be built
+var errInvoice = errors.New("the invoice could not be built")
+
+//This function downloads the invoice for order on successful transaction, priced and branded by the organization
+func invoice(company orgs.Organization, billing Address, order eagleview.OrderStats) ([]byte, error) {
+	price := company.Price(billing.TypeRep)
+	notes := fmt.Sprintf("Report ID: %s\nThis Report # can be used to keep track of report", strconv.Itoa(order.ReportIds[0]))
+	return invoiceDocument(company, billing, time.Now(), notes, []*generator.Item{{
+		Name:     billing.TypeRep,
+		UnitCost: strconv.Itoa(price),
+		Quantity: "1",
+	}})
+}
+
+//This function builds the invoice PDF of items sold by the organization to billing
+func invoiceDocument(company orgs.Organization, billing Address, curentTime time.Time, notes string, items []*generator.Item) ([]byte, error) {
+	doc, err := generator.New(generator.Invoice, &generator.Options{
 		CurrencySymbol:  "$",
 		TextTypeInvoice: "INVOICE",
 		AutoPrint:       true,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	doc.SetHeader(&generator.HeaderFooter{
 		Pagination: true,
@@ -818,23 +979,28 @@ func invoice(billing Address, order OrderStats) []byte {
 
 	doc.SetRef("Ref: Report Invoice")
 
-	doc.SetDescription("RenuLogix EagleView Report")
-	doc.SetNotes(fmt.Sprintf("Report ID: %s\nThis Report # can be used to keep track of report", strconv.Itoa(order.ReportIds[0])))
+	doc.SetDescription(company.Name + " EagleView Report")
+	doc.SetNotes(notes)
 
 	doc.SetDate(curentTime.Format("01-02-2006 Monday"))
 	doc.SetPaymentTerm(curentTime.Format("01-02-2006 Monday"))
 
-	logoBytes, _ := ioutil.ReadFile("./pics/RenuLogix-Logo.png")
-
-	doc.SetCompany(&generator.Contact{
-		Name: "Renulogix",
-		Logo: &logoBytes,
+	seller := &generator.Contact{
+		Name: company.Name,
 		Address: &generator.Address{
-			Address:    "85 N Raymond Ave",
-			Address2:   "Pasadena, CA",
-			PostalCode: "91103",
+			Address:    company.Address,
+			Address2:   company.Address2,
+			PostalCode: company.PostalCode,
 		},
-	})
+	}
+	//The invoice cannot be built with an empty logo, so it is left out when the file is missing
+	logoBytes, err := ioutil.ReadFile(company.Logo)
+	if err != nil {
+		log.Print(err.Error())
+	} else {
+		seller.Logo = &logoBytes
+	}
+	doc.SetCompany(seller)
 
 	doc.SetCustomer(&generator.Contact{
 		Name: fmt.Sprintf("%s %s", billing.FirstName, billing.LastName),
@@ -844,118 +1010,775 @@ func invoice(billing Address, order OrderStats) []byte {
 			PostalCode: billing.Zip,
 		},
 	})
-	doc.AppendItem(&generator.Item{
-		Name:     billing.TypeRep,
-		UnitCost: strconv.Itoa(price),
-		Quantity: "1",
-	})
+	for _, item := range items {
+		doc.AppendItem(item)
+	}
 	pdf, err := doc.Build()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	var buf bytes.Buffer
-	err = pdf.Output(&buf)
-	if err != nil {
-		fmt.Printf(err.Error())
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
 	}
-	var data []byte = buf.Bytes()
-
-	return data
+	return buf.Bytes(), nil
 }
 
 //This function Converts the json values from the report into struct
-func convertJsonToStruct(test []ReportResult, tablres ExportEV, data queryResult) []ReportResult {
+//Sun hours use the facet's own irradiance when its production is known, otherwise the south facing estimate
+func convertJsonToStruct(test []ReportResult, tablres radiance.Model, data storage.ReportData, roofs []storage.RoofProduction) []ReportResult {
+	byDesignator := make(map[string]storage.RoofProduction, len(roofs))
+	for _, roof := range roofs {
+		byDesignator[roof.Designator] = roof
+	}
 	for i, roof := range tablres.Roofs {
 		test[i].Designator = roof.Designator
 		test[i].Unroundedsize = roof.Unroundedsize
 		test[i].Pitch = roof.Pitch
 		test[i].PitchDeg = roof.PitchDeg
 		test[i].Orientation = fmt.Sprintf("%.2f", roof.Orientation)
-		test[i].Tsrf = fmt.Sprintf("%.2f", roof.Irradiance[0].Tsrf)
-		test[i].Sa = fmt.Sprintf("%.2f", roof.Irradiance[0].Sa)
-		test[i].SunHours = fmt.Sprintf("%.2f", roof.Irradiance[0].Tsrf*data.solar_annual*365)
+		irradiance := facetIrradiance(roof)
+		if irradiance.HasAnnual {
+			test[i].Tsrf = fmt.Sprintf("%.2f", irradiance.Annual.Tsrf)
+			test[i].Sa = fmt.Sprintf("%.2f", irradiance.Annual.Sa)
+			test[i].SunHours = fmt.Sprintf("%.2f", irradiance.Annual.Tsrf*data.SolradAnnual*365)
+		}
+		if irradiance.hasMonthly() {
+			test[i].SolarAccessMonthly = make([]string, 12)
+			for month, value := range irradiance.Monthly {
+				if value != nil {
+					test[i].SolarAccessMonthly[month] = fmt.Sprintf("%.2f", value.Sa)
+				}
+			}
+		}
+		facet, ok := byDesignator[roof.Designator]
+		if ok && facet.Module != "" {
+			test[i].Panels = strconv.Itoa(facet.Panels)
+		}
+		if ok && facet.SolradAnnual > 0 {
+			test[i].SunHours = fmt.Sprintf("%.2f", irradiance.Annual.Sa*facet.SolradAnnual*365)
+			test[i].SystemKw = fmt.Sprintf("%.2f", facet.SystemCapacity)
+			test[i].AcAnnual = fmt.Sprintf("%.0f", facet.AcAnnual)
+		}
 	}
 	return test
 }
 
-//This function checks whether the file available for select report id
-func checkReport(TokenObject Token, reportId string) ([]byte, string) {
-	var Endpoint = "https://webservices.eagleview.com/v3/Report/GetReport?reportId=" + reportId
-	req, err := http.NewRequest("GET", Endpoint, nil)
+//This function sorts the irradiance entries of a facet. Entries name their month with "month" and twelve entries
+//without a month or scenario are read as January to December. The first other unnamed entry is the annual value,
+//and without one the annual value is the average of the monthly ones.
+func facetIrradiance(roof radiance.Roof) FacetIrradiance {
+	var irradiance FacetIrradiance
+	positional := len(roof.Irradiance) == 12
+	for _, entry := range roof.Irradiance {
+		if entry.Month != 0 || entry.Scenario != "" {
+			positional = false
+		}
+	}
+	for i := range roof.Irradiance {
+		entry := roof.Irradiance[i]
+		switch {
+		case positional:
+			irradiance.Monthly[i] = &entry
+		case entry.Scenario == "" && entry.Month >= 1 && entry.Month <= 12 && irradiance.Monthly[entry.Month-1] == nil:
+			irradiance.Monthly[entry.Month-1] = &entry
+		case entry.Scenario == "" && entry.Month == 0 && !irradiance.HasAnnual:
+			irradiance.Annual = entry
+			irradiance.HasAnnual = true
+		default:
+			irradiance.Scenarios = append(irradiance.Scenarios, entry)
+		}
+	}
+	if !irradiance.HasAnnual && irradiance.hasMonthly() {
+		var months float64
+		for _, value := range irradiance.Monthly {
+			if value != nil {
+				irradiance.Annual.Tsrf += value.Tsrf
+				irradiance.Annual.Sa += value.Sa
+				months++
+			}
+		}
+		irradiance.Annual.Tsrf /= months
+		irradiance.Annual.Sa /= months
+		irradiance.HasAnnual = true
+	}
+	return irradiance
+}
+
+func (irradiance FacetIrradiance) hasMonthly() bool {
+	for _, value := range irradiance.Monthly {
+		if value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+//This function builds the facet by month solar access table, reports without monthly values have none
+func solarAccessMatrix(results []ReportResult) *SolarAccessMatrix {
+	matrix := &SolarAccessMatrix{Months: charts.Months}
+	var monthly bool
+	for _, result := range results {
+		row := SolarAccessRow{Designator: result.Designator, Values: make([]string, 12)}
+		for month := range row.Values {
+			row.Values[month] = "-"
+			if month < len(result.SolarAccessMonthly) && result.SolarAccessMonthly[month] != "" {
+				row.Values[month] = result.SolarAccessMonthly[month]
+				monthly = true
+			}
+		}
+		matrix.Rows = append(matrix.Rows, row)
+	}
+	if !monthly {
+		return nil
+	}
+	return matrix
+}
+
+//This function returns the Radiance model of a report, downloading it from eagleview into the blob store the first time
+//A deliverable that does not pass the parser is not stored, so a corrected one is downloaded on the next view
+func (s *server) radianceModel(ctx context.Context, token eagleview.Token, reportId string) (radiance.Model, error) {
+	key := blob.ReportKey(reportId, "radiance.json")
+	jsonData, _, err := s.blobs.Get(ctx, key)
+	if err == nil {
+		return radiance.Parse(bytes.NewReader(jsonData))
+	}
+	if !errors.Is(err, blob.ErrNotFound) {
+		log.Println(err.Error())
+		return radiance.Model{}, err
+	}
+
+	url, err := s.downloadReport(ctx, token, reportId)
+	if err != nil {
+		return radiance.Model{}, err
+	}
+	if jsonData, err = s.getJsonFile(ctx, url); err != nil {
+		return radiance.Model{}, err
+	}
+	model, err := radiance.Parse(bytes.NewReader(jsonData))
 	if err != nil {
-		fmt.Printf("Error with request\n")
+		log.Printf("report %s: %v", reportId, err)
+		return model, err
+	}
+	if err := s.blobs.Put(ctx, key, jsonData, blob.Info{
+		ContentType: "application/json",
+		Metadata:    map[string]string{"report-id": reportId, "file-type": eagleview.RadianceDeliverableJSON, "format-version": model.Version},
+	}); err != nil {
+		log.Print(err.Error())
 	}
-	req.Header.Add("Host", "webservices.eagleview.com")
-	req.Header.Add("Authorization", TokenObject.TokenType+" "+TokenObject.AccessToken)
+	return model, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+//This function turns a failure to load the Radiance model into a message for the user and the problems found in the file
+func modelProblem(err error) (string, []string) {
+	var syntaxError *radiance.SyntaxError
+	var versionError *radiance.VersionError
+	var validationError *radiance.ValidationError
+	switch {
+	case errors.As(err, &syntaxError):
+		return "The roof model from EagleView is not valid JSON", []string{fmt.Sprintf("%s (byte %d)", syntaxError.Msg, syntaxError.Offset)}
+	case errors.As(err, &versionError):
+		return fmt.Sprintf("The roof model from EagleView uses format version %s, which is not supported", versionError.Version), nil
+	case errors.As(err, &validationError):
+		var problems []string
+		for _, problem := range validationError.Problems {
+			problems = append(problems, problem.Field+" "+problem.Problem)
+		}
+		return "The roof model from EagleView is incomplete", problems
+	}
+	return "The roof model is not available from EagleView", nil
+}
+
+//This function builds the roof facet rows of a report, as shown on the advanced report
+func (s *server) reportResults(ctx context.Context, data storage.ReportData) (radiance.Model, []ReportResult, []storage.RoofProduction, error) {
+	token, err := s.tokens.Token(ctx)
 	if err != nil {
 		log.Print(err.Error())
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	model, err := s.radianceModel(ctx, token, data.ReportID)
+	if err != nil {
+		return model, nil, nil, err
+	}
+	roofs := s.roofProduction(ctx, data.ReportID, model)
+	results := convertJsonToStruct(make([]ReportResult, len(model.Roofs)), model, data, roofs)
+	return model, results, roofs, nil
+}
 
+//This function routes the downloads of a report: /reports/{id}/facets.csv, /reports/{id}/facets.xlsx, /reports/{id}/proposal.pdf
+//and the report images at /reports/{id}/images/{view}
+func (s *server) reportDownloads(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/reports/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || (len(parts) == 3) != (parts[1] == "images") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := s.requireUser(w, r)
+	if !ok {
+		return
+	}
+	//Reports of other users answer like unknown reports
+	if owned, err := s.ownsReport(r.Context(), user, parts[0]); err != nil {
+		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
+	} else if !owned {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[1] {
+	case "images":
+		s.reportImage(w, r, parts[0], parts[2])
+	case "facets.csv", "facets.xlsx":
+		s.facetExport(w, r, parts[0], strings.TrimPrefix(parts[1], "facets."))
+	case "proposal.pdf":
+		s.proposal(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//This function builds the homeowner proposal from the report images, roof facets, production and pricing
+func (s *server) proposal(w http.ResponseWriter, r *http.Request, reportId string) {
+	ctx := r.Context()
+	data, err := s.reportData(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
+	}
+	_, results, roofs, err := s.reportResults(ctx, data)
+	if err != nil {
+		message, problems := modelProblem(err)
+		http.Error(w, strings.Join(append([]string{message}, problems...), "\n"), http.StatusBadGateway)
+		return
+	}
+	token, err := s.tokens.Token(ctx)
+	if err != nil {
+		log.Print(err.Error())
+	}
+	images := s.reportImages(ctx, token, reportId)
+
+	system := summarizeRoofs(roofs)
+	table := facetTable(results)
+	doc := proposal.Proposal{
+		Branding:    proposalBranding(s.organization(ctx, data.OrgID)),
+		Address:     fmt.Sprintf("%s, %s, %s %s", data.Street, data.City, data.State, data.Zip),
+		ReportID:    reportId,
+		FacetHeader: table.Header,
+		Facets:      table.Rows,
+		System: proposal.System{
+			Module:    system.Module,
+			Panels:    system.Panels,
+			SystemKw:  system.SystemKw,
+			AcAnnual:  system.AcAnnual,
+			AcMonthly: system.AcMonthly,
+		},
 	}
-	return bodyBytes, resp.Status
+	for i, title := range reportImageTitles {
+		doc.Images = append(doc.Images, proposal.Image{Title: title, Data: images[i]})
+	}
+	if analysis, err := finance.Analyze(system.SystemKw, system.AcAnnual, s.finance); err == nil {
+		doc.Finance = &analysis
+	}
+
+	pdf, err := proposal.Build(doc)
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, "The proposal could not be created", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename=Proposal"+reportId+".pdf")
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.Write(pdf)
 }
 
-//This function retrieves the url for json file from eagleview
-func downloadReport(token Token, reportId string) (string, string) {
-	var Endpoint = "https://webservices.eagleview.com/v1/reports/" + reportId + "/file-links"
-	req, err := http.NewRequest("GET", Endpoint, nil)
+//This function turns the roof facet rows into the table used by the exports and the proposal
+func facetTable(results []ReportResult) export.Table {
+	table := export.Table{Header: []string{"Roof ID", "Total Area", "Azimuth", "Pitch - in/ft", "Pitch - Degree", "SAV", "TSRF", "Annual Sun Hours", "Panels", "System kW", "Annual kWh"}}
+	for _, result := range results {
+		table.Rows = append(table.Rows, []string{result.Designator, result.Unroundedsize, result.Orientation, result.Pitch, result.PitchDeg,
+			result.Sa, result.Tsrf, result.SunHours, result.Panels, result.SystemKw, result.AcAnnual})
+	}
+	return table
+}
+
+//Totals of the recommended system over every roof facet
+type systemSummary struct {
+	SystemKw  float64
+	AcAnnual  float64
+	Panels    int
+	Module    string
+	AcMonthly []float64
+}
+
+func summarizeRoofs(roofs []storage.RoofProduction) systemSummary {
+	var summary systemSummary
+	for _, roof := range roofs {
+		summary.SystemKw += roof.SystemCapacity
+		summary.AcAnnual += roof.AcAnnual
+		summary.Panels += roof.Panels
+		if roof.Module != "" {
+			summary.Module = roof.Module
+		}
+		if len(roof.AcMonthly) == 12 {
+			if summary.AcMonthly == nil {
+				summary.AcMonthly = make([]float64, 12)
+			}
+			for i, ac := range roof.AcMonthly {
+				summary.AcMonthly[i] += ac
+			}
+		}
+	}
+	return summary
+}
+
+//This function returns a report file. Files of completed orders are kept in the blob store shared by every instance,
+//and in the local artifact cache in front of it
+func (s *server) reportFile(ctx context.Context, token eagleview.Token, reportId string, fileType int, fileFormat int) ([]byte, error) {
+	key := cache.Key(reportId, fmt.Sprintf("file-%d.%d", fileType, fileFormat))
+	if data, ok := s.cache.Get(key); ok {
+		return data, nil
+	}
+	blobKey, contentType := reportBlobKey(reportId, fileType, fileFormat)
+	data, _, err := s.blobs.Get(ctx, blobKey)
+	if err == nil {
+		if err := s.cache.Put(key, data); err != nil {
+			log.Print(err.Error())
+		}
+		return data, nil
+	}
+	if !errors.Is(err, blob.ErrNotFound) {
+		log.Print(err.Error())
+	}
+
+	err = eagleview.Retry(ctx, s.tokens, token, func(token eagleview.Token) (err error) {
+		data, err = s.ev.GetReportFile(ctx, token, reportId, fileType, fileFormat)
+		return err
+	})
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+	//Files of unfinished orders may still change, so only completed ones are kept
+	if order, err := s.store.Order(ctx, reportId); err == nil && order.Status == orders.StatusCompleted {
+		if err := s.blobs.Put(ctx, blobKey, data, blob.Info{
+			ContentType: contentType,
+			Metadata: map[string]string{
+				"report-id":   reportId,
+				"file-type":   strconv.Itoa(fileType),
+				"file-format": strconv.Itoa(fileFormat),
+			},
+		}); err != nil {
+			log.Print(err.Error())
+		}
+		if err := s.cache.Put(key, data); err != nil {
+			log.Print(err.Error())
+		}
+	}
+	return data, nil
+}
+
+//This function names the blob of a report file: images/{view}.jpg for the report images, pdf/{report}.pdf for the reports
+func reportBlobKey(reportId string, fileType int, fileFormat int) (string, string) {
+	if fileFormat == eagleview.FormatImage {
+		for i, imageType := range reportImageTypes {
+			if imageType == fileType {
+				return blob.ReportKey(reportId, "images/"+reportImageViews[i]+".jpg"), "image/jpeg"
+			}
+		}
+		return blob.ReportKey(reportId, fmt.Sprintf("images/%d.jpg", fileType)), "image/jpeg"
+	}
+	switch fileType {
+	case eagleview.FileBasicReport:
+		return blob.ReportKey(reportId, "pdf/basic-report.pdf"), "application/pdf"
+	case eagleview.FileAdvancedReport:
+		return blob.ReportKey(reportId, "pdf/advanced-report.pdf"), "application/pdf"
+	}
+	return blob.ReportKey(reportId, fmt.Sprintf("files/%d.%d", fileType, fileFormat)), "application/octet-stream"
+}
+
+//Titles, URL names and eagleview file types of the images returned by reportImages, in the same order
+var reportImageTitles = [5]string{"Overhead", "North", "South", "East", "West"}
+var reportImageViews = [5]string{"overhead", "north", "south", "east", "west"}
+var reportImageTypes = [5]int{eagleview.FileTopImage, eagleview.FileNorthImage, eagleview.FileSouthImage, eagleview.FileEastImage, eagleview.FileWestImage}
+
+//Widths in pixels of the thumbnails served with ?size=
+var thumbnailWidths = map[string]int{"small": 200, "medium": 480, "large": 960}
+
+//This function downloads the overhead and the four side images of a report at once, a failed image is left empty
+func (s *server) reportImages(ctx context.Context, token eagleview.Token, reportId string) [5][]byte {
+	var images [5][]byte
+	var waitgroup sync.WaitGroup
+	for i, fileType := range reportImageTypes {
+		waitgroup.Add(1)
+		go func(i int, fileType int) {
+			defer waitgroup.Done()
+			image, err := s.reportFile(ctx, token, reportId, fileType, eagleview.FormatImage)
+			if err != nil {
+				log.Printf("report %s image %d: %v", reportId, fileType, err)
+				return
+			}
+			images[i] = image
+		}(i, fileType)
+	}
+	waitgroup.Wait()
+	return images
+}
+
+//This function serves one report image as the original JPEG, or as a thumbnail when ?size= is small, medium or large.
+//Images of completed orders do not change, so browsers may keep them and revalidate with the ETag.
+func (s *server) reportImage(w http.ResponseWriter, r *http.Request, reportId string, view string) {
+	fileType := -1
+	for i, name := range reportImageViews {
+		if name == view {
+			fileType = reportImageTypes[i]
+		}
+	}
+	width, thumb := thumbnailWidths[r.URL.Query().Get("size")]
+	if fileType < 0 || (r.URL.Query().Get("size") != "" && !thumb) {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := r.Context()
+	_, err := s.store.ReportType(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
 	}
-	req.Header.Add("Host", "webservices.eagleview.com")
-	req.Header.Add("Authorization", token.TokenType+" "+token.AccessToken)
+	order, err := s.store.Order(ctx, reportId)
+	completed := err == nil && order.Status == orders.StatusCompleted
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	var image []byte
+	key := cache.Key(reportId, fmt.Sprintf("thumb-%d-%d", fileType, width))
+	if thumb {
+		image, _ = s.cache.Get(key)
+	}
+	if image == nil {
+		token, err := s.tokens.Token(ctx)
+		if err != nil {
+			log.Print(err.Error())
+		}
+		image, err = s.reportFile(ctx, token, reportId, fileType, eagleview.FormatImage)
+		if err != nil || len(image) == 0 {
+			log.Printf("report %s image %s: %v", reportId, view, err)
+			http.Error(w, "The image is not available from EagleView", http.StatusBadGateway)
+			return
+		}
+		if thumb {
+			if image, err = thumbnail.JPEG(image, width); err != nil {
+				log.Print(err.Error())
+				http.Error(w, "The image could not be resized", http.StatusInternalServerError)
+				return
+			}
+			if completed {
+				if err := s.cache.Put(key, image); err != nil {
+					log.Print(err.Error())
+				}
+			}
+		}
+	}
+
+	sum := sha256.Sum256(image)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if completed {
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	//ServeContent answers If-None-Match with 304 Not Modified
+	http.ServeContent(w, r, view+".jpg", time.Time{}, bytes.NewReader(image))
+}
+
+//This function downloads the roof facet table as CSV, or as a workbook with a summary sheet
+func (s *server) facetExport(w http.ResponseWriter, r *http.Request, reportId string, format string) {
+	ctx := r.Context()
+	data, err := s.reportData(ctx, reportId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		log.Print(err.Error())
+		http.Error(w, "The report is unavailable right now", http.StatusInternalServerError)
+		return
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	_, results, roofs, err := s.reportResults(ctx, data)
+	if err != nil {
+		message, problems := modelProblem(err)
+		http.Error(w, strings.Join(append([]string{message}, problems...), "\n"), http.StatusBadGateway)
+		return
+	}
+
+	table := facetTable(results)
 
-	var links Link
-	json.Unmarshal(bodyBytes, &links)
-	for _, i := range links.Links {
-		if i.FileType == "RadianceDeliverableJSON" {
-			return i.Link, resp.Status
+	fileName := "RoofFacets" + reportId
+	var buf bytes.Buffer
+	if format == "csv" {
+		err = export.WriteCSV(&buf, table)
+		w.Header().Set("Content-Type", "text/csv")
+		fileName += ".csv"
+	} else {
+		system := summarizeRoofs(roofs)
+		summary := []export.Field{
+			{Label: "Address", Value: fmt.Sprintf("%s, %s, %s %s", data.Street, data.City, data.State, data.Zip)},
+			{Label: "Report ID", Value: data.ReportID},
+			{Label: "NREL Annual Solar Radiation (kWh/m2/day)", Value: data.SolradAnnual},
+			{Label: "NREL Annual AC Output (kWh)", Value: data.AcAnnual},
+			{Label: "NREL Capacity Factor (%)", Value: data.CapacityFactor},
+			{Label: "Average Annual Sunhours", Value: data.SolradAnnual * 365},
+			{Label: "Recommended System (kW DC)", Value: system.SystemKw},
+			{Label: "Estimated Annual Production (kWh)", Value: system.AcAnnual},
 		}
+		err = export.WriteXLSX(&buf, summary, table)
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		fileName += ".xlsx"
 	}
 	if err != nil {
 		log.Print(err.Error())
+		http.Error(w, "The export could not be created", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
 
-	return "", resp.Status
+//This function retrieves the url for json file from eagleview
+func (s *server) downloadReport(ctx context.Context, token eagleview.Token, reportId string) (string, error) {
+	var links []eagleview.Links
+	err := eagleview.Retry(ctx, s.tokens, token, func(token eagleview.Token) (err error) {
+		links, err = s.ev.FileLinks(ctx, token, reportId)
+		return err
+	})
+	if err != nil {
+		log.Print(err.Error())
+		return "", err
+	}
+	for _, i := range links {
+		if i.FileType == eagleview.RadianceDeliverableJSON {
+			return i.Link, nil
+		}
+	}
+	return "", fmt.Errorf("report %s has no Radiance deliverable yet", reportId)
 }
 
-//This function downloads the json file on to local machine
-func getJsonFile(url string, reportId string) error {
-	g := got.New()
-	if _, err2 := os.Stat("RadianceModel" + reportId + ".json"); err2 == nil {
-		return err2
+//This function downloads the json file of the Radiance deliverable
+func (s *server) getJsonFile(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
-	err := g.Download(url, "RadianceModel"+reportId+".json")
+	client := &http.Client{Timeout: s.cfg.EagleView.Timeout.Duration}
+	resp, err := client.Do(req)
 	if err != nil {
 		log.Print(err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the Radiance deliverable: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+//This function opens the blob store selected in the configuration
+func openBlobStore(cfg config.Blob) (blob.Store, error) {
+	if cfg.Driver == "s3" {
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+	}
+	return blob.NewLocal(cfg.Dir)
+}
+
+//This function returns the sender of the application emails selected in the configuration
+func mailSender(cfg config.Mail) mail.Sender {
+	switch cfg.Transport {
+	case "smtp":
+		return &mail.SMTP{Addr: cfg.SMTP.Addr, From: cfg.From, Username: cfg.SMTP.Username, Password: cfg.SMTP.Password}
+	case "file":
+		return &mail.File{Dir: cfg.Dir, From: cfg.From}
+	}
+	return &mail.Log{From: cfg.From}
+}
+
+//This function returns the card fields of the payment pages for the configured provider
+func (s *server) cardForm() CardForm {
+	return CardForm{Provider: s.cfg.Payment.Provider, PublishableKey: s.cfg.Payment.Stripe.PublishableKey}
+}
+
+//This function returns the payment provider chosen in the configuration
+func paymentProvider(cfg config.Payment) payment.Provider {
+	if cfg.Provider == "stripe" {
+		return payment.NewStripe(cfg.Stripe.BaseURL, cfg.Stripe.SecretKey, &http.Client{Timeout: cfg.Stripe.Timeout.Duration})
+	}
+	log.Print("payments go to the fake provider, cards are not charged")
+	return payment.NewFake()
+}
+
+//This function builds the EagleView client configuration from the loaded settings
+func eagleViewConfig(cfg config.EagleView) eagleview.Config {
+	return eagleview.Config{
+		BaseURL:         cfg.BaseURL,
+		IntegrationsURL: cfg.IntegrationsURL,
+		SourceID:        cfg.SourceID,
+		ClientSecret:    cfg.ClientSecret,
+		Username:        cfg.Username,
+		Password:        cfg.Password,
+		HTTPClient:      &http.Client{Timeout: cfg.Timeout.Duration},
 	}
-	return err
 }
 
 //Main Function where SSL certificate to be implemented to run a secure connection
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to the JSON config file")
+	grantAdmin := flag.String("grant-admin", "", "give the account of this email the admin pages, then exit")
+	revokeAdmin := flag.String("revoke-admin", "", "take the admin pages from the account of this email, then exit")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cookieStore = sessions.NewCookieStore([]byte(cfg.Session.Key))
+	cookieStore.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   7 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.Server.ReportURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	st, err := storage.Open(context.Background(), cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer st.Close()
+	//Admins are only made here, by someone with access to the server, never from a page
+	if *grantAdmin != "" || *revokeAdmin != "" {
+		email, admin := *grantAdmin, true
+		if *revokeAdmin != "" {
+			email, admin = *revokeAdmin, false
+		}
+		user, err := accounts.New(st, nil, 0).SetAdmin(context.Background(), email, admin)
+		if errors.Is(err, accounts.ErrNotFound) {
+			log.Fatalf("no account is registered for %s", email)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s admin: %t", user.Email, user.Admin)
+		return
+	}
+	defaultOrg, err := syncOrganizations(context.Background(), st, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	artifacts, err := cache.Open(cfg.Cache.Dir, cache.Options{
+		TTL:      cfg.Cache.TTL.Duration,
+		MaxBytes: int64(cfg.Cache.MaxSizeMB) << 20,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	blobs, err := openBlobStore(cfg.Blob)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ev := eagleview.New(eagleViewConfig(cfg.EagleView))
+	tokens := eagleview.NewTokenManager(ev, eagleview.DefaultRefreshLeeway)
+	nrelClient := nrel.New(nrel.Config{
+		BaseURL:    cfg.NREL.BaseURL,
+		APIKey:     cfg.NREL.APIKey,
+		HTTPClient: &http.Client{Timeout: cfg.NREL.Timeout.Duration},
+	})
+	//Validate has already checked the selected module is in the catalog
+	module, _ := cfg.Sizing.SelectedModule()
+	mailer := mailSender(cfg.Mail)
+	s := &server{
+		cfg:    cfg,
+		store:  st,
+		ev:     ev,
+		tokens: tokens,
+		poller: orders.NewPoller(st, ev, tokens, cfg.Orders.PollInterval.Duration),
+		nrel:   nrelClient,
+		production: production.NewEstimator(nrelClient, production.Config{
+			UsableAreaFraction: cfg.Production.UsableAreaFraction,
+			ModuleKwPerSqM:     cfg.Production.ModuleKwPerSqM,
+			Losses:             cfg.Production.Losses,
+			ArrayType:          cfg.Production.ArrayType,
+			ModuleType:         cfg.Production.ModuleType,
+		}),
+		sizing: sizing.Config{
+			Module:         sizing.Module{Name: module.Name, Length: module.Length, Width: module.Width, Watts: module.Watts},
+			EdgeSetbackFt:  cfg.Sizing.EdgeSetbackFt,
+			RidgeSetbackFt: cfg.Sizing.RidgeSetbackFt,
+			MinTSRF:        cfg.Sizing.MinTSRF,
+		},
+		cache:      artifacts,
+		blobs:      blobs,
+		users:      accounts.New(st, mailer, cfg.Accounts.ResetTTL.Duration),
+		notifier:   notify.New(mailer, "emails"),
+		payments:   paymentProvider(cfg.Payment),
+		defaultOrg: defaultOrg,
+		finance: finance.Assumptions{
+			UtilityRate:      cfg.Finance.UtilityRate,
+			RateEscalation:   cfg.Finance.RateEscalation,
+			CostPerWatt:      cfg.Finance.CostPerWatt,
+			Degradation:      cfg.Finance.Degradation,
+			IncentivePercent: cfg.Finance.IncentivePercent,
+			IncentiveAmount:  cfg.Finance.IncentiveAmount,
+			DiscountRate:     cfg.Finance.DiscountRate,
+			Years:            cfg.Finance.Years,
+		},
+	}
+	s.users.Brand = func(ctx context.Context, user accounts.User) string {
+		return s.organization(ctx, user.OrgID).Name
+	}
+	s.poller.Changed = s.orderChanged
+	go s.poller.Run(context.Background())
+	go s.resumeBatches(context.Background())
+
 	serverMuxA := http.NewServeMux()
-	serverMuxA.HandleFunc("/formpage", lookUpPage)
-	serverMuxA.HandleFunc("/reportDisplay", DisplayPage)
+	serverMuxA.HandleFunc("/formpage", s.lookUpPage)
+	serverMuxA.HandleFunc("/reportDisplay", s.DisplayPage)
+	serverMuxA.HandleFunc(apiPrefix, s.api)
+	serverMuxA.HandleFunc("/reports/", s.reportDownloads)
+	s.handleAccounts(serverMuxA, "/formpage")
+	serverMuxA.HandleFunc("/branding/", s.brandLogo)
+	s.handleAdmin(serverMuxA)
 	/*Server the http for payment and placing order*/
 
 	serverMuxB := http.NewServeMux()
-	serverMuxB.HandleFunc("/payment", payment)
+	serverMuxB.HandleFunc("/payment", s.payment)
+	//The fake provider stands in for the tokenization Stripe.js does in the browser
+	if fake, ok := s.payments.(*payment.Fake); ok {
+		serverMuxB.Handle("/payment/cards", fake)
+	}
+	s.handleBulk(serverMuxB)
+	//The session cookie is shared by both servers, the order server signs users in on its own pages
+	s.handleAccounts(serverMuxB, "/payment")
+	serverMuxB.HandleFunc("/branding/", s.brandLogo)
 
 	go func() {
 		serverMuxA.Handle("/pics/", http.StripPrefix("/pics/", http.FileServer(http.Dir("pics"))))
@@ -975,6 +1798,8 @@ func main() {
 		serverMuxB.Handle("/js", http.FileServer(http.Dir("js/")))
 	}()
 
-	go http.ListenAndServe(":9090", serverMuxA)
-	http.ListenAndServe(":8888", serverMuxB)
+	go func() {
+		log.Fatal(http.ListenAndServe(cfg.Server.ReportAddr, serverMuxA))
+	}()
+	log.Fatal(http.ListenAndServe(cfg.Server.OrderAddr, serverMuxB))
 }
@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// MySQL and SQLite hold the migrations for each database. Both directories
+// must use the same version numbers for the same change.
+var (
+	MySQL, _  = fs.Sub(files, "mysql")
+	SQLite, _ = fs.Sub(files, "sqlite")
+)
+
+// Migration is one versioned schema change, loaded from a file named
+// <version>_<name>.sql
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Load reads and orders every migration in fsys
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrations: %s must be named <version>_<name>.sql", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations: %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: label, Statements: splitStatements(string(data))})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements breaks a file on semicolons ending a line, dropping
+// comment lines. Statements must not contain such semicolons themselves.
+func splitStatements(sqlText string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(sqlText, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Run applies every migration in fsys newer than the recorded schema version
+func Run(ctx context.Context, db *sql.DB, fsys fs.FS) error {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		appliedAt DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrations: creating schema_migrations: %w", err)
+	}
+
+	current, err := Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m); err != nil {
+			return err
+		}
+		log.Printf("migrations: applied %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Version returns the highest applied migration, 0 for an empty database
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("migrations: reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// apply runs one migration in a transaction. MySQL commits DDL implicitly,
+// so a failed migration may still need manual cleanup there.
+func apply(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, statement := range m.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migrations: %04d_%s statement %d: %w", m.Version, m.Name, i+1, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("migrations: recording %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,26 @@
+-- Tables the application used before migrations were tracked. IF NOT EXISTS
+-- keeps this a no-op on databases created by hand.
+CREATE TABLE IF NOT EXISTS OrderHistory (
+    firstName VARCHAR(100) NOT NULL,
+    lastName VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(50) NOT NULL,
+    zipcode VARCHAR(10) NOT NULL,
+    reportId VARCHAR(32) NOT NULL,
+    reportType VARCHAR(16) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS NREL (
+    street VARCHAR(255) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    state VARCHAR(50) NOT NULL,
+    zipcode VARCHAR(10) NOT NULL,
+    lat DOUBLE NOT NULL,
+    lon DOUBLE NOT NULL,
+    azimuth VARCHAR(16) NOT NULL,
+    tilt VARCHAR(16) NOT NULL,
+    solrad_annual DOUBLE NOT NULL,
+    ac_annual DOUBLE NOT NULL
+);
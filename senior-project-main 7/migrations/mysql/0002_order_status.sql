@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS OrderStatus (
+    reportId VARCHAR(32) NOT NULL PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    updatedAt DATETIME NOT NULL
+);
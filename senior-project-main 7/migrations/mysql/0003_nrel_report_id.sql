@@ -0,0 +1,11 @@
+-- Link NREL results to the report they were fetched for instead of joining
+-- on the address columns.
+ALTER TABLE NREL ADD COLUMN reportId VARCHAR(32) NULL;
+
+UPDATE NREL n JOIN OrderHistory o
+    ON n.street = o.street AND n.city = o.city AND n.state = o.state AND n.zipcode = o.zipcode
+    SET n.reportId = o.reportId;
+
+CREATE INDEX idx_nrel_report ON NREL (reportId);
+
+CREATE INDEX idx_order_history_report ON OrderHistory (reportId);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ReportArtifact (
+    reportId VARCHAR(32) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    contentType VARCHAR(100) NOT NULL,
+    data LONGBLOB NOT NULL,
+    createdAt DATETIME NOT NULL,
+    PRIMARY KEY (reportId, kind)
+);
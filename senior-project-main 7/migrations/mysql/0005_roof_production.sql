@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS RoofProduction (
+    reportId VARCHAR(32) NOT NULL,
+    designator VARCHAR(32) NOT NULL,
+    azimuth DOUBLE NOT NULL,
+    tilt DOUBLE NOT NULL,
+    areaSqFt DOUBLE NOT NULL,
+    systemCapacity DOUBLE NOT NULL,
+    ac_annual DOUBLE NOT NULL,
+    solrad_annual DOUBLE NOT NULL,
+    PRIMARY KEY (reportId, designator)
+);
@@ -0,0 +1,21 @@
+-- Keep the monthly PVWatts series next to the annual totals so the advanced
+-- report can chart them.
+ALTER TABLE NREL ADD COLUMN capacity_factor DOUBLE NULL;
+
+CREATE TABLE IF NOT EXISTS NRELMonthly (
+    reportId VARCHAR(32) NOT NULL,
+    month INT NOT NULL,
+    ac_monthly DOUBLE NOT NULL,
+    poa_monthly DOUBLE NOT NULL,
+    solrad_monthly DOUBLE NOT NULL,
+    dc_monthly DOUBLE NOT NULL,
+    PRIMARY KEY (reportId, month)
+);
+
+CREATE TABLE IF NOT EXISTS RoofProductionMonthly (
+    reportId VARCHAR(32) NOT NULL,
+    designator VARCHAR(32) NOT NULL,
+    month INT NOT NULL,
+    ac_monthly DOUBLE NOT NULL,
+    PRIMARY KEY (reportId, designator, month)
+);
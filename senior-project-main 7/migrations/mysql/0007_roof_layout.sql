@@ -0,0 +1,4 @@
+-- Record the module layout the production of each facet was estimated for.
+ALTER TABLE RoofProduction ADD COLUMN panels INT NOT NULL DEFAULT 0;
+
+ALTER TABLE RoofProduction ADD COLUMN module VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+-- Customer accounts, their pending password resets and the owner of each order.
+-- Orders placed before accounts existed keep a NULL userId.
+CREATE TABLE IF NOT EXISTS Users (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    passwordHash VARCHAR(100) NOT NULL,
+    createdAt DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS PasswordReset (
+    tokenHash CHAR(64) NOT NULL PRIMARY KEY,
+    userId BIGINT NOT NULL,
+    expiresAt DATETIME NOT NULL,
+    FOREIGN KEY (userId) REFERENCES Users (id) ON DELETE CASCADE
+);
+
+ALTER TABLE OrderHistory ADD COLUMN userId BIGINT NULL;
+
+CREATE INDEX OrderHistoryUser ON OrderHistory (userId);
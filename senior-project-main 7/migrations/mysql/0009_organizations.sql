@@ -0,0 +1,23 @@
+-- Installer organizations, the organization of each user and order, and the
+-- price charged for each order. Rows from before keep a NULL orgId and belong
+-- to the default organization.
+CREATE TABLE IF NOT EXISTS Organizations (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    address2 VARCHAR(255) NOT NULL DEFAULT '',
+    postalCode VARCHAR(16) NOT NULL DEFAULT '',
+    logo VARCHAR(255) NOT NULL DEFAULT '',
+    primaryColor CHAR(7) NOT NULL,
+    accentColor CHAR(7) NOT NULL,
+    basicPrice INT NOT NULL,
+    advancedPrice INT NOT NULL,
+    signupCode VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+ALTER TABLE Users ADD COLUMN orgId BIGINT NULL;
+
+ALTER TABLE OrderHistory ADD COLUMN orgId BIGINT NULL;
+
+ALTER TABLE OrderHistory ADD COLUMN price INT NULL;
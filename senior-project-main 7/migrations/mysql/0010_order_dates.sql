@@ -0,0 +1,10 @@
+-- The date of each order, for the admin filters and the monthly totals.
+-- Older orders take the date their invoice was stored, or stay NULL without one.
+ALTER TABLE OrderHistory ADD COLUMN createdAt DATETIME NULL;
+
+UPDATE OrderHistory SET createdAt = (
+    SELECT a.createdAt FROM ReportArtifact a
+    WHERE a.reportId = OrderHistory.reportId AND a.kind = 'invoice'
+);
+
+CREATE INDEX OrderHistoryCreated ON OrderHistory (createdAt);
@@ -0,0 +1,6 @@
+-- The payment of each order at the payment provider, and the card it was
+-- charged to by brand and last four digits. Older orders were paid through
+-- EagleView and keep NULL.
+ALTER TABLE OrderHistory ADD COLUMN paymentId VARCHAR(255) NULL;
+ALTER TABLE OrderHistory ADD COLUMN cardBrand VARCHAR(20) NULL;
+ALTER TABLE OrderHistory ADD COLUMN cardLast4 VARCHAR(4) NULL;
@@ -0,0 +1,41 @@
+-- Files of addresses ordered together, and what became of each of their rows.
+-- The billing contact and payment are filled in when the batch is paid for.
+CREATE TABLE IF NOT EXISTS OrderBatches (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    userId BIGINT NOT NULL,
+    orgId BIGINT NULL,
+    name VARCHAR(255) NOT NULL,
+    poNumber VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    firstName VARCHAR(100) NOT NULL DEFAULT '',
+    lastName VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    street VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    state VARCHAR(50) NOT NULL DEFAULT '',
+    zipcode VARCHAR(10) NOT NULL DEFAULT '',
+    paymentId VARCHAR(255) NULL,
+    cardBrand VARCHAR(20) NULL,
+    cardLast4 VARCHAR(4) NULL,
+    createdAt DATETIME NOT NULL
+);
+
+CREATE INDEX OrderBatchesUser ON OrderBatches (userId);
+
+CREATE TABLE IF NOT EXISTS OrderBatchRows (
+    batchId BIGINT NOT NULL,
+    line INT NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    state VARCHAR(255) NOT NULL,
+    zipcode VARCHAR(255) NOT NULL,
+    reportType VARCHAR(255) NOT NULL,
+    referenceId VARCHAR(255) NOT NULL DEFAULT '',
+    claimNumber VARCHAR(255) NOT NULL DEFAULT '',
+    price INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    detail VARCHAR(1000) NOT NULL DEFAULT '',
+    reportId VARCHAR(32) NULL,
+    PRIMARY KEY (batchId, line),
+    FOREIGN KEY (batchId) REFERENCES OrderBatches (id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- One PVWatts result per report, so fetching it again replaces the old one.
+-- Reports saved more than once keep one of their rows: the table has no key
+-- to tell them apart, so it is copied into one with the unique index.
+CREATE TABLE NRELUnique LIKE NREL;
+
+DROP INDEX idx_nrel_report ON NRELUnique;
+
+CREATE UNIQUE INDEX NRELReport ON NRELUnique (reportId);
+
+INSERT IGNORE INTO NRELUnique SELECT * FROM NREL;
+
+DROP TABLE NREL;
+
+RENAME TABLE NRELUnique TO NREL;
@@ -0,0 +1,3 @@
+-- Facets PVWatts failed for are stored without production and estimated
+-- again on the next view of the report.
+ALTER TABLE RoofProduction ADD COLUMN failed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Admins are flagged on their account from the command line, registering
+-- with the email of an admin gives no rights.
+ALTER TABLE Users ADD COLUMN isAdmin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS OrderHistory (
+    firstName TEXT NOT NULL,
+    lastName TEXT NOT NULL,
+    email TEXT NOT NULL,
+    street TEXT NOT NULL,
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    zipcode TEXT NOT NULL,
+    reportId TEXT NOT NULL,
+    reportType TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS NREL (
+    street TEXT NOT NULL,
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    zipcode TEXT NOT NULL,
+    lat REAL NOT NULL,
+    lon REAL NOT NULL,
+    azimuth TEXT NOT NULL,
+    tilt TEXT NOT NULL,
+    solrad_annual REAL NOT NULL,
+    ac_annual REAL NOT NULL
+);
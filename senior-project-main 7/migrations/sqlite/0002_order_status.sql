@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS OrderStatus (
+    reportId TEXT NOT NULL PRIMARY KEY,
+    status TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    updatedAt DATETIME NOT NULL
+);
@@ -0,0 +1,10 @@
+ALTER TABLE NREL ADD COLUMN reportId TEXT NULL;
+
+UPDATE NREL SET reportId = (
+    SELECT o.reportId FROM OrderHistory o
+    WHERE o.street = NREL.street AND o.city = NREL.city AND o.state = NREL.state AND o.zipcode = NREL.zipcode
+);
+
+CREATE INDEX idx_nrel_report ON NREL (reportId);
+
+CREATE INDEX idx_order_history_report ON OrderHistory (reportId);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ReportArtifact (
+    reportId TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    contentType TEXT NOT NULL,
+    data BLOB NOT NULL,
+    createdAt DATETIME NOT NULL,
+    PRIMARY KEY (reportId, kind)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS RoofProduction (
+    reportId TEXT NOT NULL,
+    designator TEXT NOT NULL,
+    azimuth REAL NOT NULL,
+    tilt REAL NOT NULL,
+    areaSqFt REAL NOT NULL,
+    systemCapacity REAL NOT NULL,
+    ac_annual REAL NOT NULL,
+    solrad_annual REAL NOT NULL,
+    PRIMARY KEY (reportId, designator)
+);
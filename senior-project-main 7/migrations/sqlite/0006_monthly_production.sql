@@ -0,0 +1,21 @@
+-- Keep the monthly PVWatts series next to the annual totals so the advanced
+-- report can chart them.
+ALTER TABLE NREL ADD COLUMN capacity_factor REAL NULL;
+
+CREATE TABLE IF NOT EXISTS NRELMonthly (
+    reportId TEXT NOT NULL,
+    month INTEGER NOT NULL,
+    ac_monthly REAL NOT NULL,
+    poa_monthly REAL NOT NULL,
+    solrad_monthly REAL NOT NULL,
+    dc_monthly REAL NOT NULL,
+    PRIMARY KEY (reportId, month)
+);
+
+CREATE TABLE IF NOT EXISTS RoofProductionMonthly (
+    reportId TEXT NOT NULL,
+    designator TEXT NOT NULL,
+    month INTEGER NOT NULL,
+    ac_monthly REAL NOT NULL,
+    PRIMARY KEY (reportId, designator, month)
+);
@@ -0,0 +1,18 @@
+-- Customer accounts, their pending password resets and the owner of each order.
+-- Orders placed before accounts existed keep a NULL userId.
+CREATE TABLE IF NOT EXISTS Users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE,
+    passwordHash TEXT NOT NULL,
+    createdAt DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS PasswordReset (
+    tokenHash TEXT NOT NULL PRIMARY KEY,
+    userId INTEGER NOT NULL REFERENCES Users (id) ON DELETE CASCADE,
+    expiresAt DATETIME NOT NULL
+);
+
+ALTER TABLE OrderHistory ADD COLUMN userId INTEGER NULL;
+
+CREATE INDEX IF NOT EXISTS OrderHistoryUser ON OrderHistory (userId);
@@ -0,0 +1,23 @@
+-- Installer organizations, the organization of each user and order, and the
+-- price charged for each order. Rows from before keep a NULL orgId and belong
+-- to the default organization.
+CREATE TABLE IF NOT EXISTS Organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    address2 TEXT NOT NULL DEFAULT '',
+    postalCode TEXT NOT NULL DEFAULT '',
+    logo TEXT NOT NULL DEFAULT '',
+    primaryColor TEXT NOT NULL,
+    accentColor TEXT NOT NULL,
+    basicPrice INTEGER NOT NULL,
+    advancedPrice INTEGER NOT NULL,
+    signupCode TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE Users ADD COLUMN orgId INTEGER NULL;
+
+ALTER TABLE OrderHistory ADD COLUMN orgId INTEGER NULL;
+
+ALTER TABLE OrderHistory ADD COLUMN price INTEGER NULL;
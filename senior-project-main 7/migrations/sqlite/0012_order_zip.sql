@@ -0,0 +1,3 @@
+-- Duplicate orders are looked for among the orders of the same ZIP code, the
+-- street is compared in its canonical form in Go.
+CREATE INDEX IF NOT EXISTS OrderHistoryZip ON OrderHistory (zipcode);
@@ -0,0 +1,40 @@
+-- Files of addresses ordered together, and what became of each of their rows.
+-- The billing contact and payment are filled in when the batch is paid for.
+CREATE TABLE IF NOT EXISTS OrderBatches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    orgId INTEGER NULL,
+    name TEXT NOT NULL,
+    poNumber TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    firstName TEXT NOT NULL DEFAULT '',
+    lastName TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    street TEXT NOT NULL DEFAULT '',
+    city TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL DEFAULT '',
+    zipcode TEXT NOT NULL DEFAULT '',
+    paymentId TEXT NULL,
+    cardBrand TEXT NULL,
+    cardLast4 TEXT NULL,
+    createdAt DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS OrderBatchesUser ON OrderBatches (userId);
+
+CREATE TABLE IF NOT EXISTS OrderBatchRows (
+    batchId INTEGER NOT NULL REFERENCES OrderBatches (id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    street TEXT NOT NULL,
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    zipcode TEXT NOT NULL,
+    reportType TEXT NOT NULL,
+    referenceId TEXT NOT NULL DEFAULT '',
+    claimNumber TEXT NOT NULL DEFAULT '',
+    price INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    reportId TEXT NULL,
+    PRIMARY KEY (batchId, line)
+);
@@ -0,0 +1,9 @@
+-- One PVWatts result per report, so fetching it again replaces the old one.
+-- Reports saved more than once keep their latest row.
+DELETE FROM NREL WHERE reportId IS NOT NULL AND rowid NOT IN (
+    SELECT MAX(rowid) FROM NREL WHERE reportId IS NOT NULL GROUP BY reportId
+);
+
+DROP INDEX IF EXISTS idx_nrel_report;
+
+CREATE UNIQUE INDEX IF NOT EXISTS NRELReport ON NREL (reportId);
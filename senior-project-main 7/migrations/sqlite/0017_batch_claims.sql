@@ -0,0 +1,5 @@
+-- A placing batch is claimed by the process placing it, so the instances
+-- resuming batches at startup do not place the same rows twice. The claim
+-- is renewed with every row and taken over once claimedUntil has passed.
+ALTER TABLE OrderBatches ADD COLUMN claim TEXT NULL;
+ALTER TABLE OrderBatches ADD COLUMN claimedUntil DATETIME NULL;
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"test/notify"
+	"test/orders"
+	"test/orgs"
+	"test/storage"
+)
+
+//How long sending one notification may take
+const notifyTimeout = time.Minute
+
+//This function emails the confirmation of a placed order with its invoice
+func (s *server) notifyOrderPlaced(org orgs.Organization, billing Address, reportId string, invoice []byte) {
+	notice := s.orderNotice(org, storage.OrderRecord{
+		FirstName:  billing.FirstName,
+		LastName:   billing.LastName,
+		Email:      billing.Email,
+		Street:     billing.Street,
+		City:       billing.City,
+		State:      billing.State,
+		Zip:        billing.Zip,
+		ReportID:   reportId,
+		ReportType: billing.TypeRep,
+	})
+	s.sendNotification(reportId, func(ctx context.Context) error {
+		return s.notifier.OrderConfirmed(ctx, notice, invoice)
+	})
+}
+
+//This function emails the customer when their report is completed or has failed
+func (s *server) orderChanged(ctx context.Context, order orders.Order) {
+	if order.Status != orders.StatusCompleted && order.Status != orders.StatusFailed {
+		return
+	}
+	record, err := s.store.OrderDetails(ctx, order.ReportID)
+	if err != nil {
+		log.Printf("notifying report %s: %v", order.ReportID, err)
+		return
+	}
+	notice := s.orderNotice(s.organization(ctx, record.OrgID), record)
+	notice.Detail = order.Detail
+	s.sendNotification(order.ReportID, func(ctx context.Context) error {
+		if order.Status == orders.StatusCompleted {
+			return s.notifier.ReportReady(ctx, notice)
+		}
+		return s.notifier.OrderFailed(ctx, notice)
+	})
+}
+
+//This function sends a notification in the background, so a slow mail server does not hold up the page that caused it
+func (s *server) sendNotification(reportId string, send func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("notifying report %s: %v", reportId, err)
+		}
+	}()
+}
+
+//This function returns what the notification templates show about an order
+func (s *server) orderNotice(org orgs.Organization, record storage.OrderRecord) notify.Order {
+	return notify.Order{
+		ReportID:   record.ReportID,
+		ReportType: record.ReportType,
+		FirstName:  record.FirstName,
+		LastName:   record.LastName,
+		Email:      record.Email,
+		Address:    fmt.Sprintf("%s, %s, %s %s", record.Street, record.City, record.State, record.Zip),
+		Brand:      org.Name,
+		Price:      orderPrice(org, record),
+		URL:        s.cfg.Server.ReportURL + "/formpage",
+	}
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"test/mail"
+)
+
+// Template names, each is the file <name>.txt of the template directory
+const (
+	OrderConfirmation = "order-confirmation"
+	ReportReady       = "report-ready"
+	OrderFailed       = "order-failed"
+)
+
+// Order is what the notification templates can show about an order
+type Order struct {
+	ReportID   string
+	ReportType string
+	FirstName  string
+	LastName   string
+	// Email is the billing email, the recipient of the notifications
+	Email   string
+	Address string
+	// Brand names the organization the order was placed with
+	Brand string
+	Price int
+	// Detail is the last status reported by EagleView
+	Detail string
+	// URL is where the customer looks up the report
+	URL string
+}
+
+// Notifier sends the emails about orders. The templates are text/template
+// files defining a "subject" and a "body", read at every send so they can be
+// edited without a restart.
+type Notifier struct {
+	mailer mail.Sender
+	dir    string
+}
+
+func New(mailer mail.Sender, templateDir string) *Notifier {
+	return &Notifier{mailer: mailer, dir: templateDir}
+}
+
+// OrderConfirmed confirms a placed order with its invoice PDF attached
+func (n *Notifier) OrderConfirmed(ctx context.Context, order Order, invoice []byte) error {
+	msg, err := n.render(OrderConfirmation, order)
+	if err != nil {
+		return err
+	}
+	if len(invoice) > 0 {
+		msg.Attachments = []mail.Attachment{{
+			Filename:    "invoice-" + order.ReportID + ".pdf",
+			ContentType: "application/pdf",
+			Data:        invoice,
+		}}
+	}
+	return n.mailer.Send(ctx, msg)
+}
+
+// ReportReady tells the customer their report is completed
+func (n *Notifier) ReportReady(ctx context.Context, order Order) error {
+	return n.send(ctx, ReportReady, order)
+}
+
+// OrderFailed tells the customer EagleView could not produce their report
+func (n *Notifier) OrderFailed(ctx context.Context, order Order) error {
+	return n.send(ctx, OrderFailed, order)
+}
+
+func (n *Notifier) send(ctx context.Context, name string, order Order) error {
+	msg, err := n.render(name, order)
+	if err != nil {
+		return err
+	}
+	return n.mailer.Send(ctx, msg)
+}
+
+// render fills the template name in for order
+func (n *Notifier) render(name string, order Order) (mail.Message, error) {
+	if strings.TrimSpace(order.Email) == "" {
+		return mail.Message{}, fmt.Errorf("notify: order %s has no email", order.ReportID)
+	}
+	t, err := template.ParseFiles(filepath.Join(n.dir, name+".txt"))
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("notify: %w", err)
+	}
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", order); err != nil {
+		return mail.Message{}, fmt.Errorf("notify: %s subject: %w", name, err)
+	}
+	if err := t.ExecuteTemplate(&body, "body", order); err != nil {
+		return mail.Message{}, fmt.Errorf("notify: %s body: %w", name, err)
+	}
+	return mail.Message{
+		To:      order.Email,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
@@ -0,0 +1,154 @@
+package nrel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is the PVWatts v6 answer
+type Response struct {
+	Inputs struct {
+		Address        string `json:"address"`
+		SystemCapacity string `json:"system_capacity"`
+		Lat            string `json:"lat"`
+		Lon            string `json:"lon"`
+		Azimuth        string `json:"azimuth"`
+		Tilt           string `json:"tilt"`
+		ArrayType      string `json:"array_type"`
+		ModuleType     string `json:"module_type"`
+		Losses         string `json:"losses"`
+	} `json:"inputs"`
+	Errors   []interface{} `json:"errors"`
+	Warnings []interface{} `json:"warnings"`
+	Version  string        `json:"version"`
+	SscInfo  struct {
+		Version int    `json:"version"`
+		Build   string `json:"build"`
+	} `json:"ssc_info"`
+	StationInfo struct {
+		Lat               float64 `json:"lat"`
+		Lon               float64 `json:"lon"`
+		Elev              float64 `json:"elev"`
+		Tz                int     `json:"tz"`
+		Location          string  `json:"location"`
+		City              string  `json:"city"`
+		State             string  `json:"state"`
+		SolarResourceFile string  `json:"solar_resource_file"`
+		Distance          int     `json:"distance"`
+	} `json:"station_info"`
+	Outputs struct {
+		AcMonthly      []float64 `json:"ac_monthly"`
+		PoaMonthly     []float64 `json:"poa_monthly"`
+		SolradMonthly  []float64 `json:"solrad_monthly"`
+		DcMonthly      []float64 `json:"dc_monthly"`
+		AcAnnual       float64   `json:"ac_annual"`
+		SolradAnnual   float64   `json:"solrad_annual"`
+		CapacityFactor float64   `json:"capacity_factor"`
+	} `json:"outputs"`
+}
+
+// Request describes one PV system. Address is used when set, otherwise Lat
+// and Lon locate the system.
+type Request struct {
+	Address        string
+	Lat            float64
+	Lon            float64
+	SystemCapacity float64
+	Azimuth        float64
+	Tilt           float64
+	ArrayType      int
+	ModuleType     int
+	Losses         float64
+}
+
+// MinSystemCapacity is the smallest system size in kW PVWatts accepts
+const MinSystemCapacity = 0.05
+
+type Config struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// Client calls the NREL PVWatts API
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+func New(cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{cfg: cfg, http: httpClient}
+}
+
+// APIError carries the errors PVWatts reports in its response body
+type APIError struct {
+	Status string
+	Errors []string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return "nrel: unexpected status " + e.Status
+	}
+	return "nrel: " + strings.Join(e.Errors, "; ")
+}
+
+// PVWatts estimates the production of the system described by req
+func (c *Client) PVWatts(ctx context.Context, req Request) (Response, error) {
+	var response Response
+	query := url.Values{}
+	query.Set("api_key", c.cfg.APIKey)
+	if req.Address != "" {
+		query.Set("address", req.Address)
+	} else {
+		query.Set("lat", formatFloat(req.Lat))
+		query.Set("lon", formatFloat(req.Lon))
+	}
+	query.Set("system_capacity", formatFloat(req.SystemCapacity))
+	query.Set("azimuth", formatFloat(req.Azimuth))
+	query.Set("tilt", formatFloat(req.Tilt))
+	query.Set("array_type", strconv.Itoa(req.ArrayType))
+	query.Set("module_type", strconv.Itoa(req.ModuleType))
+	query.Set("losses", formatFloat(req.Losses))
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.BaseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return response, err
+	}
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response, err
+	}
+	if err := json.Unmarshal(bodyBytes, &response); err != nil && resp.StatusCode == http.StatusOK {
+		return response, fmt.Errorf("nrel: decoding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(response.Errors) > 0 {
+		apiErr := &APIError{Status: resp.Status}
+		for _, e := range response.Errors {
+			apiErr.Errors = append(apiErr.Errors, fmt.Sprint(e))
+		}
+		return response, apiErr
+	}
+	return response, nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,107 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"test/eagleview"
+)
+
+// Poller asks EagleView for the state of every unfinished order and moves
+// the stored status along
+type Poller struct {
+	// Changed is called with the new state of every order whose status the
+	// poller moved, once per change
+	Changed func(ctx context.Context, order Order)
+
+	store    Store
+	client   eagleview.Client
+	tokens   eagleview.TokenSource
+	interval time.Duration
+}
+
+func NewPoller(store Store, client eagleview.Client, tokens eagleview.TokenSource, interval time.Duration) *Poller {
+	return &Poller{store: store, client: client, tokens: tokens, interval: interval}
+}
+
+// Run polls until ctx is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.PollOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollOnce checks every pending order a single time
+func (p *Poller) PollOnce(ctx context.Context) {
+	pending, err := p.store.PendingOrders(ctx)
+	if err != nil {
+		log.Printf("orders: listing pending orders: %v", err)
+		return
+	}
+	for _, order := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := p.Check(ctx, order); err != nil {
+			log.Printf("orders: checking report %s: %v", order.ReportID, err)
+		}
+	}
+}
+
+// Check refreshes a single order from EagleView and returns its new state
+func (p *Poller) Check(ctx context.Context, order Order) (Order, error) {
+	if order.Status.Terminal() {
+		return order, nil
+	}
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return order, err
+	}
+
+	var next Status
+	var detail string
+	var report eagleview.Report
+	err = eagleview.Retry(ctx, p.tokens, token, func(token eagleview.Token) (err error) {
+		report, err = p.client.GetReport(ctx, token, order.ReportID)
+		return err
+	})
+	var statusErr *eagleview.StatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound:
+		next, detail = StatusFailed, "EagleView has no report with this id"
+	case err != nil:
+		return order, err
+	default:
+		var ok bool
+		next, ok = FromEagleView(report.Status)
+		if !ok {
+			return order, nil
+		}
+		detail = report.Status
+		if report.SubStatus != "" {
+			detail += " - " + report.SubStatus
+		}
+	}
+
+	if next == order.Status {
+		return order, nil
+	}
+	if err := p.store.UpdateStatus(ctx, order.ReportID, order.Status, next, detail); err != nil {
+		return order, err
+	}
+	changed, err := p.store.Order(ctx, order.ReportID)
+	if err == nil && p.Changed != nil {
+		p.Changed(ctx, changed)
+	}
+	return changed, err
+}
@@ -0,0 +1,160 @@
+package orders_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"test/eagleview"
+	"test/orders"
+	"test/storage"
+)
+
+// fakeClient answers GetReport with report, or with err when it is set
+type fakeClient struct {
+	eagleview.Client
+
+	report eagleview.Report
+	err    error
+	calls  int
+}
+
+func (c *fakeClient) GetReport(ctx context.Context, token eagleview.Token, reportId string) (eagleview.Report, error) {
+	c.calls++
+	return c.report, c.err
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) Token(ctx context.Context) (eagleview.Token, error) {
+	return eagleview.Token{AccessToken: "access"}, nil
+}
+
+func (fakeTokens) Invalidate(rejected eagleview.Token) {}
+
+// openStore returns a new SQLite store holding the order 1 in status
+func openStore(t *testing.T, status orders.Status) *storage.SQLStore {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.OpenSQLite(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.CreateOrder(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if status != orders.StatusPlaced {
+		if err := store.UpdateStatus(ctx, "1", orders.StatusPlaced, status, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestPollerCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored orders.Status
+		client fakeClient
+		want   orders.Status
+		detail string
+	}{
+		{
+			name: "in progress", stored: orders.StatusPlaced,
+			client: fakeClient{report: eagleview.Report{Status: "In Process", SubStatus: "Measuring"}},
+			want:   orders.StatusInProgress, detail: "In Process - Measuring",
+		},
+		{
+			name: "completed", stored: orders.StatusInProgress,
+			client: fakeClient{report: eagleview.Report{Status: "Completed"}},
+			want:   orders.StatusCompleted, detail: "Completed",
+		},
+		{
+			name: "not found", stored: orders.StatusPlaced,
+			client: fakeClient{err: &eagleview.StatusError{Code: http.StatusNotFound, Status: "404 Not Found"}},
+			want:   orders.StatusFailed, detail: "EagleView has no report with this id",
+		},
+		{
+			name: "no change", stored: orders.StatusInProgress,
+			client: fakeClient{report: eagleview.Report{Status: "Pending"}},
+			want:   orders.StatusInProgress,
+		},
+		{
+			name: "unknown status", stored: orders.StatusPlaced,
+			client: fakeClient{report: eagleview.Report{Status: "On Hold"}},
+			want:   orders.StatusPlaced,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := openStore(t, test.stored)
+			poller := orders.NewPoller(store, &test.client, fakeTokens{}, 0)
+			var changed []orders.Order
+			poller.Changed = func(ctx context.Context, order orders.Order) {
+				changed = append(changed, order)
+			}
+
+			order, err := store.Order(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := poller.Check(ctx, order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != test.want || got.Detail != test.detail {
+				t.Errorf("Check = %s %q, want %s %q", got.Status, got.Detail, test.want, test.detail)
+			}
+			stored, err := store.Order(ctx, "1")
+			if err != nil || stored.Status != test.want {
+				t.Errorf("stored = %+v, %v, want %s", stored, err, test.want)
+			}
+
+			if test.want == test.stored {
+				if len(changed) != 0 {
+					t.Errorf("Changed called with %+v, want no call", changed)
+				}
+				return
+			}
+			if len(changed) != 1 || changed[0].Status != test.want {
+				t.Errorf("Changed called with %+v, want once with %s", changed, test.want)
+			}
+		})
+	}
+}
+
+func TestPollerCheckTerminal(t *testing.T) {
+	store := openStore(t, orders.StatusCompleted)
+	client := &fakeClient{report: eagleview.Report{Status: "Cancelled"}}
+	poller := orders.NewPoller(store, client, fakeTokens{}, 0)
+	order := orders.Order{ReportID: "1", Status: orders.StatusCompleted}
+	if got, err := poller.Check(context.Background(), order); err != nil || got != order {
+		t.Errorf("Check = %+v, %v, want the order unchanged", got, err)
+	}
+	if client.calls != 0 {
+		t.Errorf("GetReport called %d times for a finished order", client.calls)
+	}
+}
+
+func TestPollerCheckConflict(t *testing.T) {
+	ctx := context.Background()
+	// another instance already moved the order on since it was listed
+	store := openStore(t, orders.StatusInProgress)
+	poller := orders.NewPoller(store, &fakeClient{report: eagleview.Report{Status: "Completed"}}, fakeTokens{}, 0)
+	called := false
+	poller.Changed = func(ctx context.Context, order orders.Order) { called = true }
+
+	_, err := poller.Check(ctx, orders.Order{ReportID: "1", Status: orders.StatusPlaced})
+	if !errors.Is(err, orders.ErrConflict) {
+		t.Errorf("err = %v, want ErrConflict", err)
+	}
+	if called {
+		t.Error("Changed called for a conflicting update")
+	}
+	if stored, err := store.Order(ctx, "1"); err != nil || stored.Status != orders.StatusInProgress {
+		t.Errorf("stored = %+v, %v, want it left in progress", stored, err)
+	}
+}
@@ -0,0 +1,102 @@
+package orders
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is where an EagleView report is in its lifecycle
+type Status string
+
+const (
+	StatusPlaced     Status = "placed"
+	StatusInProgress Status = "in-progress"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+	StatusCancelled  Status = "cancelled"
+)
+
+// Statuses lists every status in lifecycle order
+var Statuses = []Status{StatusPlaced, StatusInProgress, StatusCompleted, StatusFailed, StatusCancelled}
+
+// transitions lists the states each status may move to
+var transitions = map[Status][]Status{
+	StatusPlaced:     {StatusInProgress, StatusCompleted, StatusFailed, StatusCancelled},
+	StatusInProgress: {StatusCompleted, StatusFailed, StatusCancelled},
+	StatusCompleted:  {},
+	StatusFailed:     {},
+	StatusCancelled:  {},
+}
+
+// Valid reports whether s is one of the known statuses
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Terminal reports whether no further transition is possible
+func (s Status) Terminal() bool {
+	return s.Valid() && len(transitions[s]) == 0
+}
+
+// CanTransition reports whether an order may move from s to next
+func (s Status) CanTransition(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Label is the status as shown to customers
+func (s Status) Label() string {
+	switch s {
+	case StatusPlaced:
+		return "Order placed"
+	case StatusInProgress:
+		return "In progress"
+	case StatusCompleted:
+		return "Completed"
+	case StatusFailed:
+		return "Failed"
+	case StatusCancelled:
+		return "Cancelled"
+	}
+	return string(s)
+}
+
+// TransitionError is returned when a status change is not allowed
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("orders: cannot move order from %s to %s", e.From, e.To)
+}
+
+// Order is the lifecycle state stored for one report
+type Order struct {
+	ReportID  string
+	Status    Status
+	Detail    string
+	UpdatedAt time.Time
+}
+
+// FromEagleView maps the status text of the v3 GetReport call onto our
+// statuses. ok is false when the text is not recognised.
+func FromEagleView(status string) (Status, bool) {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "completed", "complete", "closed", "delivered":
+		return StatusCompleted, true
+	case "cancelled", "canceled":
+		return StatusCancelled, true
+	case "failed", "unable to complete", "exception", "rejected":
+		return StatusFailed, true
+	case "in process", "in progress", "inprocess", "pending", "created", "received", "under review":
+		return StatusInProgress, true
+	}
+	return "", false
+}
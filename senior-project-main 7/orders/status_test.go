@@ -0,0 +1,65 @@
+package orders
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{from: StatusPlaced, to: StatusInProgress, want: true},
+		{from: StatusPlaced, to: StatusCompleted, want: true},
+		{from: StatusPlaced, to: StatusFailed, want: true},
+		{from: StatusPlaced, to: StatusCancelled, want: true},
+		{from: StatusInProgress, to: StatusCompleted, want: true},
+		{from: StatusInProgress, to: StatusFailed, want: true},
+		{from: StatusInProgress, to: StatusCancelled, want: true},
+		{from: StatusPlaced, to: StatusPlaced},
+		{from: StatusInProgress, to: StatusPlaced},
+		{from: StatusCompleted, to: StatusInProgress},
+		{from: StatusCompleted, to: StatusFailed},
+		{from: StatusFailed, to: StatusCompleted},
+		{from: StatusCancelled, to: StatusPlaced},
+		{from: "unknown", to: StatusCompleted},
+		{from: StatusPlaced, to: "unknown"},
+	}
+	for _, test := range tests {
+		if got := test.from.CanTransition(test.to); got != test.want {
+			t.Errorf("%s.CanTransition(%s) = %t, want %t", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	want := map[Status]bool{StatusCompleted: true, StatusFailed: true, StatusCancelled: true}
+	for _, status := range append(Statuses, "unknown") {
+		if got := status.Terminal(); got != want[status] {
+			t.Errorf("%s.Terminal() = %t, want %t", status, got, want[status])
+		}
+	}
+}
+
+func TestFromEagleView(t *testing.T) {
+	tests := []struct {
+		status string
+		want   Status
+		ok     bool
+	}{
+		{status: "Completed", want: StatusCompleted, ok: true},
+		{status: " delivered ", want: StatusCompleted, ok: true},
+		{status: "Canceled", want: StatusCancelled, ok: true},
+		{status: "Unable To Complete", want: StatusFailed, ok: true},
+		{status: "REJECTED", want: StatusFailed, ok: true},
+		{status: "In Process", want: StatusInProgress, ok: true},
+		{status: "Under Review", want: StatusInProgress, ok: true},
+		{status: "Pending", want: StatusInProgress, ok: true},
+		{status: "On Hold"},
+		{status: ""},
+	}
+	for _, test := range tests {
+		got, ok := FromEagleView(test.status)
+		if got != test.want || ok != test.ok {
+			t.Errorf("FromEagleView(%q) = %q, %t, want %q, %t", test.status, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package orders
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when no status is stored for a report
+var ErrNotFound = errors.New("orders: order not found")
+
+// ErrConflict is returned when the order changed status concurrently
+var ErrConflict = errors.New("orders: order status changed concurrently")
+
+// Store persists the lifecycle state of orders
+type Store interface {
+	Order(ctx context.Context, reportId string) (Order, error)
+	PendingOrders(ctx context.Context) ([]Order, error)
+	// CreateOrder records a newly placed order, leaving an existing record alone
+	CreateOrder(ctx context.Context, reportId string) error
+	// UpdateStatus moves an order from one status to another, failing when the
+	// transition is not allowed or the stored status is no longer from
+	UpdateStatus(ctx context.Context, reportId string, from Status, to Status, detail string) error
+}
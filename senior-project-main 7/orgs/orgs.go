@@ -0,0 +1,93 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when no organization matches
+var ErrNotFound = errors.New("orgs: organization not found")
+
+var (
+	slugPattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Organization is a tenant: the installer whose users order reports under
+// its own name, logo, colors, invoice address and prices
+type Organization struct {
+	ID int64
+	// Slug names the organization in URLs such as /register?org=acme-solar
+	Slug       string
+	Name       string
+	Address    string
+	Address2   string
+	PostalCode string
+	// Logo is the path of a PNG or JPEG file
+	Logo string
+	// PrimaryColor and AccentColor are written #rrggbb
+	PrimaryColor string
+	AccentColor  string
+	// BasicPrice and AdvancedPrice are charged per report in whole dollars
+	BasicPrice    int
+	AdvancedPrice int
+	// SignupCode must be given to register with the organization, anyone can
+	// when it is empty
+	SignupCode string
+}
+
+// Store persists organizations
+type Store interface {
+	// SaveOrganization creates the organization or updates the one with the
+	// same slug, returning it with its id
+	SaveOrganization(ctx context.Context, org Organization) (Organization, error)
+	Organization(ctx context.Context, id int64) (Organization, error)
+	OrganizationBySlug(ctx context.Context, slug string) (Organization, error)
+	Organizations(ctx context.Context) ([]Organization, error)
+}
+
+// Price returns the price of a report type, "basic" or "advanced"
+func (o Organization) Price(reportType string) int {
+	if strings.EqualFold(reportType, "basic") {
+		return o.BasicPrice
+	}
+	return o.AdvancedPrice
+}
+
+// Validate lists the problems of the organization
+func (o Organization) Validate() []string {
+	var problems []string
+	if !slugPattern.MatchString(o.Slug) {
+		problems = append(problems, fmt.Sprintf("slug %q must be lower case letters, digits and dashes", o.Slug))
+	}
+	if strings.TrimSpace(o.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	for _, color := range []string{o.PrimaryColor, o.AccentColor} {
+		if !colorPattern.MatchString(color) {
+			problems = append(problems, fmt.Sprintf("color %q must be written #rrggbb", color))
+		}
+	}
+	if o.BasicPrice <= 0 || o.AdvancedPrice <= 0 {
+		problems = append(problems, "prices must be positive")
+	}
+	return problems
+}
+
+// RGB returns the red, green and blue of a #rrggbb color, black when it is
+// malformed
+func RGB(color string) [3]int {
+	var rgb [3]int
+	if !colorPattern.MatchString(color) {
+		return rgb
+	}
+	for i := range rgb {
+		value, _ := strconv.ParseUint(color[1+2*i:3+2*i], 16, 8)
+		rgb[i] = int(value)
+	}
+	return rgb
+}
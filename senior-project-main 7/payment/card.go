@@ -0,0 +1,210 @@
+package payment
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Card is a card as entered by the customer. Only the Fake provider sees
+// one, real providers take the card in the browser.
+type Card struct {
+	Name     string
+	Number   string
+	ExpMonth int
+	// ExpYear is written with four digits, or two for the years 2000 to 2099
+	ExpYear int
+	CVC     string
+}
+
+// Brand is the card network of a card
+type Brand string
+
+// The brands accepted for orders
+const (
+	Visa       Brand = "visa"
+	Mastercard Brand = "mastercard"
+	Amex       Brand = "amex"
+	Discover   Brand = "discover"
+)
+
+// Name returns the brand as shown to customers
+func (b Brand) Name() string {
+	switch b {
+	case Visa:
+		return "Visa"
+	case Mastercard:
+		return "Mastercard"
+	case Amex:
+		return "American Express"
+	case Discover:
+		return "Discover"
+	}
+	return string(b)
+}
+
+// brandOf recognizes the brand from the leading digits of a card number,
+// "" when it is not an accepted brand
+func brandOf(number string) Brand {
+	prefix := func(n int) int {
+		if len(number) < n {
+			return -1
+		}
+		value := 0
+		for _, digit := range number[:n] {
+			value = value*10 + int(digit-'0')
+		}
+		return value
+	}
+	switch {
+	case number[0] == '4':
+		return Visa
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return Mastercard
+	case prefix(2) == 34, prefix(2) == 37:
+		return Amex
+	case prefix(4) == 6011, prefix(3) >= 644 && prefix(3) <= 649, prefix(2) == 65, prefix(6) >= 622126 && prefix(6) <= 622925:
+		return Discover
+	}
+	return ""
+}
+
+// numberLengths are the card number lengths issued by each brand
+var numberLengths = map[Brand][]int{
+	Visa:       {13, 16, 19},
+	Mastercard: {16},
+	Amex:       {15},
+	Discover:   {16, 17, 18, 19},
+}
+
+// luhn checks the check digit of a card number
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ValidationError lists the problems of a card by field: "name", "number",
+// "expiry" and "cvc"
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	var fields []string
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	problems := make([]string, len(fields))
+	for i, field := range fields {
+		problems[i] = field + ": " + e.Fields[field]
+	}
+	return "payment: invalid card: " + strings.Join(problems, "; ")
+}
+
+// Normalized returns the card with the spaces and dashes of its number
+// removed, the name and CVC trimmed and a two digit year made four digits
+func (c Card) Normalized() Card {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Number = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(c.Number))
+	c.CVC = strings.TrimSpace(c.CVC)
+	if c.ExpYear >= 0 && c.ExpYear < 100 {
+		c.ExpYear += 2000
+	}
+	return c
+}
+
+// Brand returns the brand of the card number, "" when it is not accepted
+func (c Card) Brand() Brand {
+	number := c.Normalized().Number
+	if number == "" || !digitsOnly(number) {
+		return ""
+	}
+	return brandOf(number)
+}
+
+// Validate checks the card the way the networks do before it is sent
+// anywhere: a known brand, the length and check digit of the number, an
+// expiry date not passed at now and a CVC of the length of the brand
+func (c Card) Validate(now time.Time) error {
+	c = c.Normalized()
+	fields := map[string]string{}
+	if c.Name == "" {
+		fields["name"] = "Enter the name on the card"
+	}
+
+	brand := c.Brand()
+	switch {
+	case c.Number == "":
+		fields["number"] = "Enter the card number"
+	case !digitsOnly(c.Number):
+		fields["number"] = "The card number can only contain digits"
+	case brand == "":
+		fields["number"] = "We accept Visa, Mastercard, American Express and Discover cards"
+	case !validLength(brand, len(c.Number)) || !luhn(c.Number):
+		fields["number"] = "This is not a valid " + brand.Name() + " card number"
+	}
+
+	switch {
+	case c.ExpMonth < 1 || c.ExpMonth > 12:
+		fields["expiry"] = "Enter the expiration month, 1 to 12"
+	case expired(c.ExpMonth, c.ExpYear, now):
+		fields["expiry"] = "The card has expired"
+	case c.ExpYear > now.Year()+20:
+		fields["expiry"] = "Check the expiration year"
+	}
+
+	cvcLength := 3
+	if brand == Amex {
+		cvcLength = 4
+	}
+	if len(c.CVC) != cvcLength || !digitsOnly(c.CVC) {
+		if brand == Amex {
+			fields["cvc"] = "Enter the 4 digit code printed on the front of the card"
+		} else {
+			fields["cvc"] = "Enter the 3 digit code printed on the back of the card"
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// expired reports whether a card valid through month of year has expired at
+// now. Cards work until the end of their expiry month.
+func expired(month int, year int, now time.Time) bool {
+	nowYear, nowMonth, _ := now.Date()
+	return year < nowYear || year == nowYear && month < int(nowMonth)
+}
+
+func validLength(brand Brand, length int) bool {
+	for _, valid := range numberLengths[brand] {
+		if length == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func digitsOnly(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -0,0 +1,144 @@
+package payment
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{number: "4242424242424242", want: true},
+		{number: "4242424242424241", want: false},
+		{number: "378282246310005", want: true},
+		{number: "6011111111111117", want: true},
+		{number: "79927398713", want: true},
+		{number: "79927398710", want: false},
+		{number: "0", want: true},
+	}
+	for _, test := range tests {
+		if got := luhn(test.number); got != test.want {
+			t.Errorf("luhn(%s) = %t, want %t", test.number, got, test.want)
+		}
+	}
+}
+
+func TestCardBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   Brand
+	}{
+		{number: "4242 4242 4242 4242", want: Visa},
+		{number: "4222222222222", want: Visa},
+		{number: "5555-5555-5555-4444", want: Mastercard},
+		{number: "2223003122003222", want: Mastercard},
+		{number: "2721000000000000", want: ""},
+		{number: "378282246310005", want: Amex},
+		{number: "340000000000009", want: Amex},
+		{number: "6011111111111117", want: Discover},
+		{number: "6445644564456445", want: Discover},
+		{number: "6500000000000002", want: Discover},
+		{number: "6221260000000000", want: Discover},
+		{number: "3530111333300000", want: ""},
+		{number: "30569309025904", want: ""},
+		{number: "4242x", want: ""},
+		{number: "", want: ""},
+	}
+	for _, test := range tests {
+		if got := (Card{Number: test.number}).Brand(); got != test.want {
+			t.Errorf("Brand(%q) = %q, want %q", test.number, got, test.want)
+		}
+	}
+}
+
+func TestCardNormalized(t *testing.T) {
+	card := Card{Name: " Jane Doe ", Number: " 4242 4242-4242 4242 ", ExpMonth: 4, ExpYear: 29, CVC: " 123 "}
+	want := Card{Name: "Jane Doe", Number: "4242424242424242", ExpMonth: 4, ExpYear: 2029, CVC: "123"}
+	if got := card.Normalized(); got != want {
+		t.Errorf("Normalized = %+v, want %+v", got, want)
+	}
+	if got := (Card{ExpYear: 2031}).Normalized().ExpYear; got != 2031 {
+		t.Errorf("ExpYear = %d, want a four digit year kept", got)
+	}
+}
+
+func TestCardValidate(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	valid := Card{Name: "Jane Doe", Number: "4242424242424242", ExpMonth: 12, ExpYear: 2026, CVC: "123"}
+	tests := []struct {
+		name   string
+		change func(card *Card)
+		// fields are the fields with a problem, none for a valid card
+		fields []string
+	}{
+		{name: "valid", change: func(card *Card) {}},
+		{name: "two digit year", change: func(card *Card) { card.ExpYear = 26 }},
+		{name: "this month", change: func(card *Card) { card.ExpMonth, card.ExpYear = 5, 2024 }},
+		{name: "amex", change: func(card *Card) { card.Number, card.CVC = "3782 822463 10005", "1234" }},
+		{name: "no name", change: func(card *Card) { card.Name = "  " }, fields: []string{"name"}},
+		{name: "no number", change: func(card *Card) { card.Number = "" }, fields: []string{"number"}},
+		{name: "letters", change: func(card *Card) { card.Number = "4242abcd42424242" }, fields: []string{"number"}},
+		{name: "other brand", change: func(card *Card) { card.Number = "3530111333300000" }, fields: []string{"number"}},
+		{name: "check digit", change: func(card *Card) { card.Number = "4242424242424241" }, fields: []string{"number"}},
+		{name: "length", change: func(card *Card) { card.Number = "42424242424242" }, fields: []string{"number"}},
+		{name: "no month", change: func(card *Card) { card.ExpMonth = 0 }, fields: []string{"expiry"}},
+		{name: "month 13", change: func(card *Card) { card.ExpMonth = 13 }, fields: []string{"expiry"}},
+		{name: "last month", change: func(card *Card) { card.ExpMonth, card.ExpYear = 4, 2024 }, fields: []string{"expiry"}},
+		{name: "last year", change: func(card *Card) { card.ExpYear = 2023 }, fields: []string{"expiry"}},
+		{name: "far future", change: func(card *Card) { card.ExpYear = 2045 }, fields: []string{"expiry"}},
+		{name: "short cvc", change: func(card *Card) { card.CVC = "12" }, fields: []string{"cvc"}},
+		{name: "amex cvc", change: func(card *Card) { card.Number = "378282246310005" }, fields: []string{"cvc"}},
+		{name: "cvc letters", change: func(card *Card) { card.CVC = "12a" }, fields: []string{"cvc"}},
+		{name: "empty", change: func(card *Card) { *card = Card{} }, fields: []string{"cvc", "expiry", "name", "number"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := valid
+			test.change(&card)
+			err := card.Validate(now)
+			var invalid *ValidationError
+			if test.fields == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want a valid card", err)
+				}
+				return
+			}
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			var fields []string
+			for field := range invalid.Fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("fields = %v, want %v\n%v", fields, test.fields, err)
+			}
+		})
+	}
+}
+
+func TestTokenValidate(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		month, year int
+		expired     bool
+	}{
+		{month: 5, year: 2024},
+		{month: 1, year: 2025},
+		{month: 4, year: 2024, expired: true},
+		{month: 12, year: 2023, expired: true},
+	}
+	for _, test := range tests {
+		err := Token{ID: "pm_card", ExpMonth: test.month, ExpYear: test.year}.Validate(now)
+		var invalid *ValidationError
+		if test.expired != errors.As(err, &invalid) || test.expired && invalid.Fields["expiry"] == "" {
+			t.Errorf("Validate of %d/%d = %v, want expired %t", test.month, test.year, err, test.expired)
+		}
+	}
+}
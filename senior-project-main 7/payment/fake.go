@@ -0,0 +1,172 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Test card numbers the Fake provider declines, every other valid card is
+// accepted
+const (
+	DeclinedCard          = "4000000000000002"
+	InsufficientFundsCard = "4000000000009995"
+)
+
+// Fake is a provider kept in memory that charges nothing, for development
+// and tests. It logs what a real provider would do. Its ServeHTTP stands in
+// for the tokenization a real provider does in the browser.
+type Fake struct {
+	mu       sync.Mutex
+	next     int
+	methods  map[string]Token
+	declined map[string]string
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	amount   int
+	captured bool
+	refunded bool
+}
+
+func NewFake() *Fake {
+	return &Fake{methods: map[string]Token{}, declined: map[string]string{}, payments: map[string]*fakePayment{}}
+}
+
+func (f *Fake) id(prefix string) string {
+	f.next++
+	return fmt.Sprintf("%s_fake_%d", prefix, f.next)
+}
+
+// Tokenize validates a card and keeps it as a payment method, the way the
+// script of a real provider does on the payment page
+func (f *Fake) Tokenize(ctx context.Context, card Card) (Token, error) {
+	card = card.Normalized()
+	if err := card.Validate(time.Now()); err != nil {
+		return Token{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token := Token{
+		ID:       f.id("tok"),
+		Brand:    card.Brand(),
+		Last4:    card.Number[len(card.Number)-4:],
+		ExpMonth: card.ExpMonth,
+		ExpYear:  card.ExpYear,
+	}
+	switch card.Number {
+	case DeclinedCard:
+		f.declined[token.ID] = "Your card was declined."
+	case InsufficientFundsCard:
+		f.declined[token.ID] = "Your card has insufficient funds."
+	}
+	f.methods[token.ID] = token
+	return token, nil
+}
+
+func (f *Fake) PaymentMethod(ctx context.Context, id string) (Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.methods[id]
+	if !ok {
+		return Token{}, ErrUnknownMethod
+	}
+	return token, nil
+}
+
+// fakeCard is the body posted to ServeHTTP
+type fakeCard struct {
+	Name     string `json:"name"`
+	Number   string `json:"number"`
+	ExpMonth int    `json:"expMonth"`
+	ExpYear  int    `json:"expYear"`
+	CVC      string `json:"cvc"`
+}
+
+// fakeMethod is the answer of ServeHTTP, Errors are the problems of the card
+// by field
+type fakeMethod struct {
+	ID     string            `json:"id,omitempty"`
+	Brand  Brand             `json:"brand,omitempty"`
+	Last4  string            `json:"last4,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ServeHTTP tokenizes the card posted as JSON by the payment page, answering
+// the id of the payment method or the problems of the card with a 422
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body fakeCard
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+		http.Error(w, "Invalid card", http.StatusBadRequest)
+		return
+	}
+	card := Card{Name: body.Name, Number: body.Number, ExpMonth: body.ExpMonth, ExpYear: body.ExpYear, CVC: body.CVC}
+	token, err := f.Tokenize(r.Context(), card)
+	w.Header().Set("Content-Type", "application/json")
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(fakeMethod{Errors: invalid.Fields})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(fakeMethod{ID: token.ID, Brand: token.Brand, Last4: token.Last4})
+}
+
+func (f *Fake) Authorize(ctx context.Context, token Token, amount int, description string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if reason, ok := f.declined[token.ID]; ok {
+		return "", &DeclinedError{Reason: reason}
+	}
+	id := f.id("pay")
+	f.payments[id] = &fakePayment{amount: amount}
+	log.Printf("fake payment %s: authorized %d cents on %s ending %s for %s", id, amount, token.Brand.Name(), token.Last4, description)
+	return id, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, paymentId string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[paymentId]
+	if !ok || payment.refunded || payment.captured {
+		return fmt.Errorf("payment: no authorization %s to capture", paymentId)
+	}
+	if amount <= 0 || amount > payment.amount {
+		return fmt.Errorf("payment: cannot capture %d cents of the %d authorized by %s", amount, payment.amount, paymentId)
+	}
+	payment.amount = amount
+	payment.captured = true
+	log.Printf("fake payment %s: captured %d cents", paymentId, payment.amount)
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, paymentId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[paymentId]
+	if !ok || payment.refunded {
+		return fmt.Errorf("payment: no payment %s to refund", paymentId)
+	}
+	payment.refunded = true
+	if payment.captured {
+		log.Printf("fake payment %s: refunded %d cents", paymentId, payment.amount)
+	} else {
+		log.Printf("fake payment %s: released the authorization", paymentId)
+	}
+	return nil
+}
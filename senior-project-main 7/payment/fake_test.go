@@ -0,0 +1,187 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCard is a valid card with number, expiring in two years
+func testCard(number string) Card {
+	return Card{Name: "Jane Doe", Number: number, ExpMonth: 12, ExpYear: time.Now().Year() + 2, CVC: "123"}
+}
+
+func TestFakeTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		number   string
+		invalid  bool
+		declined string
+	}{
+		{name: "accepted", number: "4242 4242 4242 4242"},
+		{name: "declined", number: DeclinedCard, declined: "Your card was declined."},
+		{name: "insufficient funds", number: InsufficientFundsCard, declined: "Your card has insufficient funds."},
+		{name: "invalid", number: "4242424242424241", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := NewFake()
+			token, err := f.Tokenize(ctx, testCard(test.number))
+			var invalid *ValidationError
+			if test.invalid {
+				if !errors.As(err, &invalid) {
+					t.Fatalf("err = %v, want a ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Brand != Visa || token.Last4 != test.number[len(test.number)-4:] || token.ExpMonth != 12 {
+				t.Errorf("token = %+v", token)
+			}
+			method, err := f.PaymentMethod(ctx, token.ID)
+			if err != nil || method != token {
+				t.Errorf("PaymentMethod = %+v, %v, want the token", method, err)
+			}
+
+			_, err = f.Authorize(ctx, token, 7500, "order")
+			var declined *DeclinedError
+			if test.declined == "" {
+				if err != nil {
+					t.Errorf("Authorize err = %v", err)
+				}
+			} else if !errors.As(err, &declined) || declined.Reason != test.declined {
+				t.Errorf("Authorize err = %v, want declined with %q", err, test.declined)
+			}
+		})
+	}
+}
+
+func TestFakePaymentMethodUnknown(t *testing.T) {
+	f := NewFake()
+	for _, id := range []string{"", "tok_fake_1", "pm_card_visa"} {
+		if _, err := f.PaymentMethod(context.Background(), id); !errors.Is(err, ErrUnknownMethod) {
+			t.Errorf("PaymentMethod(%q) err = %v, want ErrUnknownMethod", id, err)
+		}
+	}
+}
+
+func TestFakePayment(t *testing.T) {
+	// step is one call on the payment authorized for 7500 cents
+	type step struct {
+		call   string
+		amount int
+		ok     bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "capture", steps: []step{{call: "capture", amount: 7500, ok: true}}},
+		{name: "partial capture", steps: []step{{call: "capture", amount: 5000, ok: true}, {call: "refund", ok: true}}},
+		{name: "capture more than authorized", steps: []step{{call: "capture", amount: 7501}, {call: "capture", amount: 7500, ok: true}}},
+		{name: "capture nothing", steps: []step{{call: "capture", amount: 0}}},
+		{name: "capture twice", steps: []step{{call: "capture", amount: 2500, ok: true}, {call: "capture", amount: 2500}}},
+		{name: "release", steps: []step{{call: "refund", ok: true}, {call: "capture", amount: 7500}}},
+		{name: "refund twice", steps: []step{{call: "capture", amount: 7500, ok: true}, {call: "refund", ok: true}, {call: "refund"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := NewFake()
+			token, err := f.Tokenize(ctx, testCard("4242424242424242"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := f.Authorize(ctx, token, 7500, "order")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, step := range test.steps {
+				if step.call == "capture" {
+					err = f.Capture(ctx, id, step.amount)
+				} else {
+					err = f.Refund(ctx, id)
+				}
+				if (err == nil) != step.ok {
+					t.Errorf("step %d, %s %d: err = %v, want success %t", i, step.call, step.amount, err, step.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeUnknownPayment(t *testing.T) {
+	f := NewFake()
+	if err := f.Capture(context.Background(), "pay_fake_1", 100); err == nil {
+		t.Error("Capture of an unknown payment succeeded")
+	}
+	if err := f.Refund(context.Background(), "pay_fake_1"); err == nil {
+		t.Error("Refund of an unknown payment succeeded")
+	}
+}
+
+func TestFakeServeHTTP(t *testing.T) {
+	year := time.Now().Year() + 2
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		code   int
+		errors []string
+	}{
+		{
+			name: "valid", method: http.MethodPost, code: http.StatusOK,
+			body: `{"name": "Jane Doe", "number": "4242 4242 4242 4242", "expMonth": 12, "expYear": ` + strconv.Itoa(year) + `, "cvc": "123"}`,
+		},
+		{
+			name: "invalid card", method: http.MethodPost, code: http.StatusUnprocessableEntity,
+			body:   `{"name": "", "number": "4242424242424241", "expMonth": 12, "expYear": ` + strconv.Itoa(year) + `, "cvc": "1"}`,
+			errors: []string{"cvc", "name", "number"},
+		},
+		{name: "not json", method: http.MethodPost, body: `number=4242`, code: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, code: http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := NewFake()
+			rec := httptest.NewRecorder()
+			f.ServeHTTP(rec, httptest.NewRequest(test.method, "/payment/cards", strings.NewReader(test.body)))
+			if rec.Code != test.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.code, rec.Body)
+			}
+			if rec.Code != http.StatusOK && rec.Code != http.StatusUnprocessableEntity {
+				return
+			}
+			var got fakeMethod
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if test.errors != nil {
+				for _, field := range test.errors {
+					if got.Errors[field] == "" {
+						t.Errorf("no error for %s in %+v", field, got.Errors)
+					}
+				}
+				if len(got.Errors) != len(test.errors) || got.ID != "" {
+					t.Errorf("answer = %+v, want only errors for %v", got, test.errors)
+				}
+				return
+			}
+			if got.Brand != Visa || got.Last4 != "4242" {
+				t.Errorf("answer = %+v", got)
+			}
+			if _, err := f.PaymentMethod(context.Background(), got.ID); err != nil {
+				t.Errorf("PaymentMethod(%q) err = %v", got.ID, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDeclined matches the errors of a card or a charge refused by the
+// provider, see DeclinedError
+var ErrDeclined = errors.New("payment: card declined")
+
+// DeclinedError carries the reason the provider gave for refusing a card, a
+// sentence that can be shown to the customer
+type DeclinedError struct {
+	Reason string
+}
+
+func (e *DeclinedError) Error() string {
+	return "payment: card declined: " + e.Reason
+}
+
+func (e *DeclinedError) Is(target error) bool {
+	return target == ErrDeclined
+}
+
+// ErrUnknownMethod is returned for a payment method id the provider does not
+// know
+var ErrUnknownMethod = errors.New("payment: unknown payment method")
+
+// Token stands for a card kept by the provider. The card is tokenized in the
+// browser, so the card number and CVC never reach the application: only the
+// id of the payment method is sent, and its brand, last four digits and
+// expiry looked up.
+type Token struct {
+	ID       string
+	Brand    Brand
+	Last4    string
+	ExpMonth int
+	ExpYear  int
+}
+
+// Validate checks the card of the token has not expired at now. A payment
+// method may outlive its card, and is only refused by the provider once it
+// is charged.
+func (t Token) Validate(now time.Time) error {
+	if expired(t.ExpMonth, t.ExpYear, now) {
+		return &ValidationError{Fields: map[string]string{"expiry": "The card has expired"}}
+	}
+	return nil
+}
+
+// Provider charges cards. A charge is authorized before the order is placed
+// with EagleView and captured once EagleView accepted it, so a failed order
+// costs the customer nothing.
+type Provider interface {
+	// PaymentMethod looks up the card of a payment method created in the
+	// browser, failing with ErrUnknownMethod for an id the provider does not
+	// know
+	PaymentMethod(ctx context.Context, id string) (Token, error)
+	// Authorize holds amount, in cents, on the card and returns the id of the
+	// payment
+	Authorize(ctx context.Context, token Token, amount int, description string) (string, error)
+	// Capture charges amount, in cents, of an authorized payment and releases
+	// the rest of the authorization
+	Capture(ctx context.Context, paymentId string, amount int) error
+	// Refund returns a captured payment, or releases an authorization that
+	// was not captured
+	Refund(ctx context.Context, paymentId string) error
+}
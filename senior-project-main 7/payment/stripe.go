@@ -0,0 +1,177 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStripeURL is the Stripe API
+const DefaultStripeURL = "https://api.stripe.com"
+
+// Stripe charges cards with Stripe payment intents captured manually. Prices
+// are in US dollars.
+type Stripe struct {
+	baseURL   string
+	secretKey string
+	http      *http.Client
+}
+
+// NewStripe returns the provider of the account of secretKey, baseURL falls
+// back to the Stripe API when empty
+func NewStripe(baseURL string, secretKey string, httpClient *http.Client) *Stripe {
+	if baseURL == "" {
+		baseURL = DefaultStripeURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Stripe{baseURL: strings.TrimRight(baseURL, "/"), secretKey: secretKey, http: httpClient}
+}
+
+// stripeError is the error body of the Stripe API
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type stripePaymentMethod struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Card struct {
+		Brand    string `json:"brand"`
+		Last4    string `json:"last4"`
+		ExpMonth int    `json:"exp_month"`
+		ExpYear  int    `json:"exp_year"`
+	} `json:"card"`
+}
+
+type stripePaymentIntent struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// errMissing is the error of the Stripe API for an id it does not know
+var errMissing = errors.New("payment: stripe resource missing")
+
+// PaymentMethod reads a payment method created by Stripe.js on the payment
+// page with the publishable key of the account
+func (s *Stripe) PaymentMethod(ctx context.Context, id string) (Token, error) {
+	if strings.TrimSpace(id) == "" {
+		return Token{}, ErrUnknownMethod
+	}
+	var method stripePaymentMethod
+	err := s.do(ctx, "GET", "/v1/payment_methods/"+url.PathEscape(id), nil, &method)
+	if errors.Is(err, errMissing) || (err == nil && method.Type != "card") {
+		return Token{}, ErrUnknownMethod
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{
+		ID:       method.ID,
+		Brand:    Brand(method.Card.Brand),
+		Last4:    method.Card.Last4,
+		ExpMonth: method.Card.ExpMonth,
+		ExpYear:  method.Card.ExpYear,
+	}, nil
+}
+
+func (s *Stripe) Authorize(ctx context.Context, token Token, amount int, description string) (string, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.Itoa(amount))
+	form.Set("currency", "usd")
+	form.Set("payment_method", token.ID)
+	form.Set("payment_method_types[]", "card")
+	form.Set("capture_method", "manual")
+	form.Set("confirm", "true")
+	form.Set("description", description)
+	var intent stripePaymentIntent
+	if err := s.post(ctx, "/v1/payment_intents", form, &intent); err != nil {
+		return "", err
+	}
+	if intent.Status != "requires_capture" {
+		return "", &DeclinedError{Reason: "The payment could not be authorized."}
+	}
+	return intent.ID, nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, paymentId string, amount int) error {
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.Itoa(amount))
+	return s.post(ctx, "/v1/payment_intents/"+url.PathEscape(paymentId)+"/capture", form, nil)
+}
+
+func (s *Stripe) Refund(ctx context.Context, paymentId string) error {
+	var intent stripePaymentIntent
+	if err := s.do(ctx, "GET", "/v1/payment_intents/"+url.PathEscape(paymentId), nil, &intent); err != nil {
+		return err
+	}
+	if intent.Status == "requires_capture" {
+		return s.post(ctx, "/v1/payment_intents/"+url.PathEscape(paymentId)+"/cancel", url.Values{}, nil)
+	}
+	form := url.Values{}
+	form.Set("payment_intent", paymentId)
+	return s.post(ctx, "/v1/refunds", form, nil)
+}
+
+func (s *Stripe) post(ctx context.Context, path string, form url.Values, result interface{}) error {
+	return s.do(ctx, "POST", path, form, result)
+}
+
+// do calls the API, card errors are returned as a DeclinedError
+func (s *Stripe) do(ctx context.Context, method string, path string, form url.Values, result interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("payment: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("payment: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr stripeError
+		json.Unmarshal(data, &apiErr)
+		if apiErr.Error.Type == "card_error" {
+			return &DeclinedError{Reason: apiErr.Error.Message}
+		}
+		if apiErr.Error.Code == "resource_missing" {
+			return fmt.Errorf("%w: %s %s: %s", errMissing, method, path, apiErr.Error.Message)
+		}
+		if apiErr.Error.Message != "" {
+			return fmt.Errorf("payment: stripe %s %s: %s: %s", method, path, resp.Status, apiErr.Error.Message)
+		}
+		return fmt.Errorf("payment: stripe %s %s: %s", method, path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("payment: stripe %s %s: %w", method, path, err)
+	}
+	return nil
+}
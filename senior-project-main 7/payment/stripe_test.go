@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripePaymentMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Authorization") != "Bearer sk_test" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/payment_methods/pm_card":
+			w.Write([]byte(`{"id": "pm_card", "type": "card", "card": {"brand": "visa", "last4": "4242", "exp_month": 12, "exp_year": 2030}}`))
+		case "/v1/payment_methods/pm_bank":
+			w.Write([]byte(`{"id": "pm_bank", "type": "us_bank_account"}`))
+		case "/v1/payment_methods/pm_down":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"type": "api_error", "message": "Try again"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "code": "resource_missing", "message": "No such PaymentMethod"}}`))
+		}
+	}))
+	defer server.Close()
+	stripe := NewStripe(server.URL, "sk_test", server.Client())
+
+	tests := []struct {
+		id      string
+		want    Token
+		wantErr error
+	}{
+		{id: "pm_card", want: Token{ID: "pm_card", Brand: Visa, Last4: "4242", ExpMonth: 12, ExpYear: 2030}},
+		{id: "pm_bank", wantErr: ErrUnknownMethod},
+		{id: "pm_missing", wantErr: ErrUnknownMethod},
+		{id: " ", wantErr: ErrUnknownMethod},
+	}
+	for _, test := range tests {
+		got, err := stripe.PaymentMethod(context.Background(), test.id)
+		if !errors.Is(err, test.wantErr) || got != test.want {
+			t.Errorf("PaymentMethod(%q) = %+v, %v, want %+v, %v", test.id, got, err, test.want, test.wantErr)
+		}
+	}
+	if _, err := stripe.PaymentMethod(context.Background(), "pm_down"); err == nil || errors.Is(err, ErrUnknownMethod) {
+		t.Errorf("err = %v, want the error of the API", err)
+	}
+}
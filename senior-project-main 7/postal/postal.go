@@ -0,0 +1,266 @@
+package postal
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Address is a US street address in the canonical USPS form: upper case,
+// with the standard abbreviations of directionals, street suffixes, unit
+// designators and states
+type Address struct {
+	// Number is the house number, such as 123, 123A or 123 1/2
+	Number         string
+	PreDirectional string
+	// Name is the street name between the directionals and the suffix
+	Name            string
+	Suffix          string
+	PostDirectional string
+	// Unit is the secondary unit, such as APT 4B or # 4, empty for none
+	Unit string
+	// UnitID is the unit without its designators, APT 4B and # 4B are the
+	// same unit 4B
+	UnitID string
+	City   string
+	// State is the two letter code
+	State string
+	Zip   string
+	// Zip4 is the ZIP+4 add-on, empty when it was not given
+	Zip4 string
+}
+
+// ValidationError lists the problems of an address by field: "street",
+// "city", "state" and "zip"
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	for _, field := range []string{"street", "city", "state", "zip"} {
+		if problem, ok := e.Fields[field]; ok {
+			problems = append(problems, field+": "+problem)
+		}
+	}
+	return "postal: invalid address: " + strings.Join(problems, "; ")
+}
+
+// Parse reads an address as entered by a customer, in any case, with or
+// without abbreviations and punctuation
+func Parse(street, city, state, zip string) (Address, error) {
+	var address Address
+	fields := map[string]string{}
+	if err := address.parseStreet(street); err != "" {
+		fields["street"] = err
+	}
+
+	address.City = strings.Join(words(city), " ")
+	if address.City == "" {
+		fields["city"] = "Enter the city"
+	} else {
+		cityWords := strings.Fields(address.City)
+		if prefix, ok := cityPrefixes[cityWords[0]]; ok && len(cityWords) > 1 {
+			cityWords[0] = prefix
+		}
+		address.City = strings.Join(cityWords, " ")
+	}
+
+	//N.Y. is read as NY
+	stateName := strings.Join(words(state), " ")
+	if code, ok := states[stateName]; ok {
+		address.State = code
+	} else if code, ok := states[strings.ReplaceAll(stateName, " ", "")]; ok && len(code) == 2 && len(stateName) == 3 {
+		address.State = code
+	} else if stateName == "" {
+		fields["state"] = "Enter the state"
+	} else {
+		fields["state"] = "Enter the two letter code of a US state, such as CA"
+	}
+
+	if !address.parseZip(zip) {
+		fields["zip"] = "Enter a 5 digit ZIP code, or ZIP+4 such as 91103-1234"
+	}
+
+	if len(fields) > 0 {
+		return address, &ValidationError{Fields: fields}
+	}
+	return address, nil
+}
+
+// words upper cases value and splits it into words, dropping the punctuation
+// USPS leaves out. # is kept apart as the unit designator it stands for.
+func words(value string) []string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r == '.' || r == ',' || r == '\'' || r == '"':
+			return ' '
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return unicode.ToUpper(r)
+	}, value)
+	return strings.Fields(strings.ReplaceAll(value, "#", " # "))
+}
+
+// parseStreet fills in the street line fields, returning the problem found
+// or ""
+func (a *Address) parseStreet(street string) string {
+	tokens := words(street)
+	if len(tokens) == 0 {
+		return "Enter the street address"
+	}
+	if !strings.ContainsAny(tokens[0], "0123456789") {
+		return "Start the street address with the house number"
+	}
+	a.Number = tokens[0]
+	tokens = tokens[1:]
+	if len(tokens) > 0 && isFraction(tokens[0]) {
+		a.Number += " " + tokens[0]
+		tokens = tokens[1:]
+	}
+
+	//The unit starts at the first designator after at least one word of the street name
+	for i := 1; i < len(tokens); i++ {
+		if unitStart(tokens, i) {
+			a.Unit, a.UnitID = parseUnit(tokens[i:])
+			tokens = tokens[:i]
+			break
+		}
+	}
+
+	if len(tokens) > 1 {
+		if directional, ok := directionals[tokens[len(tokens)-1]]; ok {
+			a.PostDirectional = directional
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+	if len(tokens) > 1 {
+		if suffix, ok := suffixes[tokens[len(tokens)-1]]; ok {
+			a.Suffix = suffix
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+	if len(tokens) > 1 {
+		if directional, ok := directionals[tokens[0]]; ok {
+			a.PreDirectional = directional
+			tokens = tokens[1:]
+		}
+	}
+	a.Name = strings.Join(tokens, " ")
+	if a.Name == "" {
+		return "Enter the street name after the house number"
+	}
+	return ""
+}
+
+// unitStart tells whether tokens[i] is a designator starting the unit: one
+// followed by its number, itself followed by nothing or another designator,
+// or one without number ending the address
+func unitStart(tokens []string, i int) bool {
+	designator, ok := unitDesignators[tokens[i]]
+	if !ok {
+		return false
+	}
+	if unitsWithoutNumber[designator] {
+		return i == len(tokens)-1 || unitStart(tokens, i+1)
+	}
+	if i+1 >= len(tokens) {
+		return false
+	}
+	return i+2 == len(tokens) || unitStart(tokens, i+2)
+}
+
+// parseUnit returns the canonical unit of tokens, which start with a
+// designator, and its identifier
+func parseUnit(tokens []string) (string, string) {
+	var unit, id []string
+	for i := 0; i < len(tokens); i++ {
+		designator := unitDesignators[tokens[i]]
+		unit = append(unit, designator)
+		if !unitsWithoutNumber[designator] && i+1 < len(tokens) {
+			i++
+			unit = append(unit, tokens[i])
+			id = append(id, tokens[i])
+		} else {
+			id = append(id, designator)
+		}
+	}
+	return strings.Join(unit, " "), strings.Join(id, " ")
+}
+
+func isFraction(token string) bool {
+	parts := strings.Split(token, "/")
+	return len(parts) == 2 && digitsOnly(parts[0]) && digitsOnly(parts[1])
+}
+
+// parseZip accepts 12345, 12345-6789 and 123456789
+func (a *Address) parseZip(zip string) bool {
+	zip = strings.ReplaceAll(strings.TrimSpace(zip), " ", "")
+	switch {
+	case len(zip) == 5 && digitsOnly(zip):
+		a.Zip = zip
+	case len(zip) == 10 && zip[5] == '-' && digitsOnly(zip[:5]) && digitsOnly(zip[6:]):
+		a.Zip, a.Zip4 = zip[:5], zip[6:]
+	case len(zip) == 9 && digitsOnly(zip):
+		a.Zip, a.Zip4 = zip[:5], zip[5:]
+	default:
+		return false
+	}
+	return true
+}
+
+func digitsOnly(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// Street returns the street line without the unit, such as
+// 85 N RAYMOND AVE
+func (a Address) Street() string {
+	var parts []string
+	for _, part := range []string{a.Number, a.PreDirectional, a.Name, a.Suffix, a.PostDirectional} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Line1 returns the street line with the unit, such as
+// 85 N RAYMOND AVE APT 4
+func (a Address) Line1() string {
+	if a.Unit == "" {
+		return a.Street()
+	}
+	return a.Street() + " " + a.Unit
+}
+
+// ZipCode returns the ZIP code, with its +4 when known
+func (a Address) ZipCode() string {
+	if a.Zip4 == "" {
+		return a.Zip
+	}
+	return a.Zip + "-" + a.Zip4
+}
+
+// Location returns the address of the building, without the unit or the
+// +4, as geocoders expect it
+func (a Address) Location() string {
+	return a.Street() + ", " + a.City + ", " + a.State + " " + a.Zip
+}
+
+func (a Address) String() string {
+	return a.Line1() + ", " + a.City + ", " + a.State + " " + a.ZipCode()
+}
+
+// Key identifies the property of the address: two addresses with the same
+// key are the same place, however they were written. The city, state and
+// +4 are left out as the 5 digit ZIP code already places the street, and
+// several city names can be valid for one ZIP code.
+func (a Address) Key() string {
+	return strings.Join([]string{a.Street(), a.UnitID, a.Zip}, "|")
+}
@@ -0,0 +1,182 @@
+package postal
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name                     string
+		street, city, state, zip string
+		// line1, city, state and zip are the canonical fields, unitID the
+		// unit without its designators
+		want   [4]string
+		unitID string
+	}{
+		{
+			name:   "abbreviations",
+			street: "85 North Raymond Avenue", city: "Pasadena", state: "California", zip: "91103",
+			want: [4]string{"85 N RAYMOND AVE", "PASADENA", "CA", "91103"},
+		},
+		{
+			name:   "apartment",
+			street: "123 North Main Street, Apt. 4", city: "pasadena", state: "ca", zip: "91103",
+			want: [4]string{"123 N MAIN ST APT 4", "PASADENA", "CA", "91103"}, unitID: "4",
+		},
+		{
+			name:   "number sign",
+			street: "123 main st #4B", city: "Pasadena", state: "CA", zip: "91103",
+			want: [4]string{"123 MAIN ST # 4B", "PASADENA", "CA", "91103"}, unitID: "4B",
+		},
+		{
+			name:   "two designators",
+			street: "500 Park Ave Suite 200 Floor 3", city: "New York", state: "NY", zip: "10022",
+			want: [4]string{"500 PARK AVE STE 200 FL 3", "NEW YORK", "NY", "10022"}, unitID: "200 3",
+		},
+		{
+			name:   "designator without number",
+			street: "1 Main St Rear", city: "Pasadena", state: "CA", zip: "91103",
+			want: [4]string{"1 MAIN ST REAR", "PASADENA", "CA", "91103"}, unitID: "REAR",
+		},
+		{
+			name:   "fraction",
+			street: "100 1/2 Elm St", city: "Pasadena", state: "CA", zip: "91103",
+			want: [4]string{"100 1/2 ELM ST", "PASADENA", "CA", "91103"},
+		},
+		{
+			name:   "post directional",
+			street: "742 Evergreen Terrace Southwest", city: "Springfield", state: "OR", zip: "97477",
+			want: [4]string{"742 EVERGREEN TER SW", "SPRINGFIELD", "OR", "97477"},
+		},
+		{
+			name:   "directional as the name",
+			street: "10 North St", city: "Pasadena", state: "CA", zip: "91103",
+			want: [4]string{"10 NORTH ST", "PASADENA", "CA", "91103"},
+		},
+		{
+			name:   "designator as the name",
+			street: "123 Unit Road", city: "Pasadena", state: "CA", zip: "91103",
+			want: [4]string{"123 UNIT RD", "PASADENA", "CA", "91103"},
+		},
+		{
+			name:   "city prefix and zip+4",
+			street: "1 Market St", city: "St. Louis", state: "mo", zip: "63101-1234",
+			want: [4]string{"1 MARKET ST", "SAINT LOUIS", "MO", "63101-1234"},
+		},
+		{
+			name:   "dotted state and zip+4 without dash",
+			street: "1 Broadway", city: "New York", state: "N.Y.", zip: "100041234",
+			want: [4]string{"1 BROADWAY", "NEW YORK", "NY", "10004-1234"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address, err := Parse(test.street, test.city, test.state, test.zip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [4]string{address.Line1(), address.City, address.State, address.ZipCode()}
+			if got != test.want {
+				t.Errorf("Parse = %q, want %q", got, test.want)
+			}
+			if address.UnitID != test.unitID {
+				t.Errorf("UnitID = %q, want %q", address.UnitID, test.unitID)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name                     string
+		street, city, state, zip string
+		fields                   []string
+	}{
+		{name: "empty", fields: []string{"city", "state", "street", "zip"}},
+		{name: "no house number", street: "Main St", city: "Pasadena", state: "CA", zip: "91103", fields: []string{"street"}},
+		{name: "no street name", street: "123", city: "Pasadena", state: "CA", zip: "91103", fields: []string{"street"}},
+		{name: "unknown state", street: "123 Main St", city: "Pasadena", state: "Cal", zip: "91103", fields: []string{"state"}},
+		{name: "short zip", street: "123 Main St", city: "Pasadena", state: "CA", zip: "9110", fields: []string{"zip"}},
+		{name: "short zip+4", street: "123 Main St", city: "Pasadena", state: "CA", zip: "91103-12", fields: []string{"zip"}},
+		{name: "letters in zip", street: "123 Main St", city: "Pasadena", state: "CA", zip: "9110a", fields: []string{"zip"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.street, test.city, test.state, test.zip)
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			var fields []string
+			for field := range invalid.Fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("fields = %v, want %v\n%v", fields, test.fields, err)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	// address is one address as entered, street, city and zip, in California
+	type address [3]string
+	tests := []struct {
+		name string
+		a, b address
+		same bool
+	}{
+		{name: "suffix spelling", a: address{"123 Main St", "Pasadena", "91103"}, b: address{"123 main street", "pasadena", "91103"}, same: true},
+		{name: "unit designators", a: address{"123 Main St Apt 4", "Pasadena", "91103"}, b: address{"123 Main Street #4", "Pasadena", "91103"}, same: true},
+		{name: "punctuation", a: address{"123 N. Main St., Apt. 4", "Pasadena", "91103"}, b: address{"123 North Main St Apartment 4", "Pasadena", "91103"}, same: true},
+		{name: "city name", a: address{"123 Main St", "Pasadena", "91103"}, b: address{"123 Main St", "Altadena", "91103"}, same: true},
+		{name: "zip+4", a: address{"123 Main St", "Pasadena", "91103-1234"}, b: address{"123 Main St", "Pasadena", "91103"}, same: true},
+		{name: "other unit", a: address{"123 Main St Apt 4", "Pasadena", "91103"}, b: address{"123 Main St Apt 5", "Pasadena", "91103"}},
+		{name: "unit and building", a: address{"123 Main St Apt 4", "Pasadena", "91103"}, b: address{"123 Main St", "Pasadena", "91103"}},
+		{name: "directional", a: address{"123 N Main St", "Pasadena", "91103"}, b: address{"123 Main St", "Pasadena", "91103"}},
+		{name: "house number", a: address{"123 Main St", "Pasadena", "91103"}, b: address{"125 Main St", "Pasadena", "91103"}},
+		{name: "zip", a: address{"123 Main St", "Pasadena", "91103"}, b: address{"123 Main St", "Pasadena", "91104"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := Parse(test.a[0], test.a[1], "CA", test.a[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(test.b[0], test.b[1], "CA", test.b[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := a.Key() == b.Key(); same != test.same {
+				t.Errorf("keys %q and %q: same = %t, want %t", a.Key(), b.Key(), same, test.same)
+			}
+		})
+	}
+}
+
+func TestAddressLines(t *testing.T) {
+	address, err := Parse("123 North Main Street Apt 4", "Pasadena", "CA", "91103-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "Street", got: address.Street(), want: "123 N MAIN ST"},
+		{name: "Line1", got: address.Line1(), want: "123 N MAIN ST APT 4"},
+		{name: "Location", got: address.Location(), want: "123 N MAIN ST, PASADENA, CA 91103"},
+		{name: "String", got: address.String(), want: "123 N MAIN ST APT 4, PASADENA, CA 91103-1234"},
+		{name: "Key", got: address.Key(), want: "123 N MAIN ST|4|91103"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package postal
+
+// Abbreviations of USPS Publication 28. Each table maps the spellings found
+// in the wild, the abbreviation included, to the standard abbreviation.
+
+var directionals = map[string]string{
+	"N": "N", "NORTH": "N",
+	"S": "S", "SOUTH": "S",
+	"E": "E", "EAST": "E",
+	"W": "W", "WEST": "W",
+	"NE": "NE", "NORTHEAST": "NE",
+	"NW": "NW", "NORTHWEST": "NW",
+	"SE": "SE", "SOUTHEAST": "SE",
+	"SW": "SW", "SOUTHWEST": "SW",
+}
+
+var suffixes = map[string]string{
+	"ALLEY": "ALY", "ALLEE": "ALY", "ALLY": "ALY", "ALY": "ALY",
+	"ANNEX": "ANX", "ANEX": "ANX", "ANNX": "ANX", "ANX": "ANX",
+	"ARCADE": "ARC", "ARC": "ARC",
+	"AVENUE": "AVE", "AV": "AVE", "AVE": "AVE", "AVEN": "AVE", "AVENU": "AVE", "AVN": "AVE", "AVNUE": "AVE",
+	"BAYOU": "BYU", "BAYOO": "BYU", "BYU": "BYU",
+	"BEACH": "BCH", "BCH": "BCH",
+	"BEND": "BND", "BND": "BND",
+	"BLUFF": "BLF", "BLUF": "BLF", "BLF": "BLF",
+	"BOTTOM": "BTM", "BOTTM": "BTM", "BOT": "BTM", "BTM": "BTM",
+	"BOULEVARD": "BLVD", "BOUL": "BLVD", "BOULV": "BLVD", "BLVD": "BLVD",
+	"BRANCH": "BR", "BRNCH": "BR", "BR": "BR",
+	"BRIDGE": "BRG", "BRDGE": "BRG", "BRG": "BRG",
+	"BROOK": "BRK", "BRK": "BRK",
+	"BYPASS": "BYP", "BYPA": "BYP", "BYPAS": "BYP", "BYPS": "BYP", "BYP": "BYP",
+	"CAMP": "CP", "CMP": "CP", "CP": "CP",
+	"CANYON": "CYN", "CANYN": "CYN", "CNYN": "CYN", "CYN": "CYN",
+	"CAPE": "CPE", "CPE": "CPE",
+	"CAUSEWAY": "CSWY", "CAUSWA": "CSWY", "CSWY": "CSWY",
+	"CENTER": "CTR", "CEN": "CTR", "CENT": "CTR", "CENTR": "CTR", "CENTRE": "CTR", "CNTER": "CTR", "CNTR": "CTR", "CTR": "CTR",
+	"CIRCLE": "CIR", "CIRC": "CIR", "CIRCL": "CIR", "CRCL": "CIR", "CRCLE": "CIR", "CIR": "CIR",
+	"CLIFF": "CLF", "CLF": "CLF",
+	"CLIFFS": "CLFS", "CLFS": "CLFS",
+	"CLUB": "CLB", "CLB": "CLB",
+	"COMMON": "CMN", "CMN": "CMN",
+	"CORNER": "COR", "COR": "COR",
+	"CORNERS": "CORS", "CORS": "CORS",
+	"COURSE": "CRSE", "CRSE": "CRSE",
+	"COURT": "CT", "CRT": "CT", "CT": "CT",
+	"COURTS": "CTS", "CTS": "CTS",
+	"COVE": "CV", "CV": "CV",
+	"CREEK": "CRK", "CRK": "CRK",
+	"CRESCENT": "CRES", "CRSENT": "CRES", "CRSNT": "CRES", "CRES": "CRES",
+	"CROSSING": "XING", "CRSSNG": "XING", "XING": "XING",
+	"CROSSROAD": "XRD", "XRD": "XRD",
+	"CURVE": "CURV", "CURV": "CURV",
+	"DALE": "DL", "DL": "DL",
+	"DAM": "DM", "DM": "DM",
+	"DIVIDE": "DV", "DIV": "DV", "DVD": "DV", "DV": "DV",
+	"DRIVE": "DR", "DRIV": "DR", "DRV": "DR", "DR": "DR",
+	"DRIVES": "DRS", "DRS": "DRS",
+	"ESTATE": "EST", "EST": "EST",
+	"ESTATES": "ESTS", "ESTS": "ESTS",
+	"EXPRESSWAY": "EXPY", "EXP": "EXPY", "EXPR": "EXPY", "EXPRESS": "EXPY", "EXPW": "EXPY", "EXPY": "EXPY",
+	"EXTENSION": "EXT", "EXTN": "EXT", "EXTNSN": "EXT", "EXT": "EXT",
+	"FALLS": "FLS", "FLS": "FLS",
+	"FERRY": "FRY", "FRRY": "FRY", "FRY": "FRY",
+	"FIELD": "FLD", "FLD": "FLD",
+	"FIELDS": "FLDS", "FLDS": "FLDS",
+	"FLAT": "FLT", "FLT": "FLT",
+	"FLATS": "FLTS", "FLTS": "FLTS",
+	"FORD": "FRD", "FRD": "FRD",
+	"FOREST": "FRST", "FORESTS": "FRST", "FRST": "FRST",
+	"FORGE": "FRG", "FORG": "FRG", "FRG": "FRG",
+	"FORK": "FRK", "FRK": "FRK",
+	"FORKS": "FRKS", "FRKS": "FRKS",
+	"FORT": "FT", "FRT": "FT", "FT": "FT",
+	"FREEWAY": "FWY", "FREEWY": "FWY", "FRWAY": "FWY", "FRWY": "FWY", "FWY": "FWY",
+	"GARDEN": "GDN", "GARDN": "GDN", "GRDEN": "GDN", "GRDN": "GDN", "GDN": "GDN",
+	"GARDENS": "GDNS", "GRDNS": "GDNS", "GDNS": "GDNS",
+	"GATEWAY": "GTWY", "GATEWY": "GTWY", "GATWAY": "GTWY", "GTWAY": "GTWY", "GTWY": "GTWY",
+	"GLEN": "GLN", "GLN": "GLN",
+	"GREEN": "GRN", "GRN": "GRN",
+	"GROVE": "GRV", "GROV": "GRV", "GRV": "GRV",
+	"HARBOR": "HBR", "HARB": "HBR", "HARBR": "HBR", "HRBOR": "HBR", "HBR": "HBR",
+	"HAVEN": "HVN", "HVN": "HVN",
+	"HEIGHTS": "HTS", "HT": "HTS", "HTS": "HTS",
+	"HIGHWAY": "HWY", "HIGHWY": "HWY", "HIWAY": "HWY", "HIWY": "HWY", "HWAY": "HWY", "HWY": "HWY",
+	"HILL": "HL", "HL": "HL",
+	"HILLS": "HLS", "HLS": "HLS",
+	"HOLLOW": "HOLW", "HLLW": "HOLW", "HOLLOWS": "HOLW", "HOLWS": "HOLW", "HOLW": "HOLW",
+	"ISLAND": "IS", "ISLND": "IS", "IS": "IS",
+	"JUNCTION": "JCT", "JCTION": "JCT", "JCTN": "JCT", "JUNCTN": "JCT", "JUNCTON": "JCT", "JCT": "JCT",
+	"KNOLL": "KNL", "KNOL": "KNL", "KNL": "KNL",
+	"LAKE": "LK", "LK": "LK",
+	"LAKES": "LKS", "LKS": "LKS",
+	"LANDING": "LNDG", "LNDNG": "LNDG", "LNDG": "LNDG",
+	"LANE": "LN", "LN": "LN",
+	"LOOP": "LOOP", "LOOPS": "LOOP",
+	"MALL":  "MALL",
+	"MANOR": "MNR", "MNR": "MNR",
+	"MEADOW": "MDW", "MDW": "MDW",
+	"MEADOWS": "MDWS", "MEDOWS": "MDWS", "MDWS": "MDWS",
+	"MILL": "ML", "ML": "ML",
+	"MILLS": "MLS", "MLS": "MLS",
+	"MISSION": "MSN", "MISSN": "MSN", "MSSN": "MSN", "MSN": "MSN",
+	"MOTORWAY": "MTWY", "MTWY": "MTWY",
+	"MOUNT": "MT", "MNT": "MT", "MT": "MT",
+	"MOUNTAIN": "MTN", "MNTAIN": "MTN", "MNTN": "MTN", "MOUNTIN": "MTN", "MTIN": "MTN", "MTN": "MTN",
+	"ORCHARD": "ORCH", "ORCHRD": "ORCH", "ORCH": "ORCH",
+	"OVAL": "OVAL", "OVL": "OVAL",
+	"OVERPASS": "OPAS", "OPAS": "OPAS",
+	"PARK": "PARK", "PRK": "PARK", "PARKS": "PARK",
+	"PARKWAY": "PKWY", "PARKWY": "PKWY", "PKWAY": "PKWY", "PKY": "PKWY", "PARKWAYS": "PKWY", "PKWYS": "PKWY", "PKWY": "PKWY",
+	"PASS":    "PASS",
+	"PASSAGE": "PSGE", "PSGE": "PSGE",
+	"PATH": "PATH", "PATHS": "PATH",
+	"PIKE": "PIKE", "PIKES": "PIKE",
+	"PINE": "PNE", "PNE": "PNE",
+	"PINES": "PNES", "PNES": "PNES",
+	"PLACE": "PL", "PL": "PL",
+	"PLAIN": "PLN", "PLN": "PLN",
+	"PLAINS": "PLNS", "PLNS": "PLNS",
+	"PLAZA": "PLZ", "PLZA": "PLZ", "PLZ": "PLZ",
+	"POINT": "PT", "PT": "PT",
+	"POINTS": "PTS", "PTS": "PTS",
+	"PORT": "PRT", "PRT": "PRT",
+	"PRAIRIE": "PR", "PRR": "PR", "PR": "PR",
+	"RADIAL": "RADL", "RAD": "RADL", "RADIEL": "RADL", "RADL": "RADL",
+	"RANCH": "RNCH", "RANCHES": "RNCH", "RNCHS": "RNCH", "RNCH": "RNCH",
+	"RIDGE": "RDG", "RDGE": "RDG", "RDG": "RDG",
+	"RIVER": "RIV", "RVR": "RIV", "RIVR": "RIV", "RIV": "RIV",
+	"ROAD": "RD", "RD": "RD",
+	"ROADS": "RDS", "RDS": "RDS",
+	"ROUTE": "RTE", "RTE": "RTE",
+	"ROW":   "ROW",
+	"RUN":   "RUN",
+	"SHORE": "SHR", "SHOAR": "SHR", "SHR": "SHR",
+	"SHORES": "SHRS", "SHOARS": "SHRS", "SHRS": "SHRS",
+	"SKYWAY": "SKWY", "SKWY": "SKWY",
+	"SPRING": "SPG", "SPNG": "SPG", "SPRNG": "SPG", "SPG": "SPG",
+	"SPRINGS": "SPGS", "SPNGS": "SPGS", "SPRNGS": "SPGS", "SPGS": "SPGS",
+	"SQUARE": "SQ", "SQR": "SQ", "SQRE": "SQ", "SQU": "SQ", "SQ": "SQ",
+	"STATION": "STA", "STATN": "STA", "STN": "STA", "STA": "STA",
+	"STREAM": "STRM", "STREME": "STRM", "STRM": "STRM",
+	"STREET": "ST", "STRT": "ST", "STR": "ST", "ST": "ST",
+	"STREETS": "STS", "STS": "STS",
+	"SUMMIT": "SMT", "SUMIT": "SMT", "SUMITT": "SMT", "SMT": "SMT",
+	"TERRACE": "TER", "TERR": "TER", "TER": "TER",
+	"THROUGHWAY": "TRWY", "TRWY": "TRWY",
+	"TRACE": "TRCE", "TRACES": "TRCE", "TRCE": "TRCE",
+	"TRAIL": "TRL", "TRAILS": "TRL", "TRLS": "TRL", "TRL": "TRL",
+	"TUNNEL": "TUNL", "TUNEL": "TUNL", "TUNLS": "TUNL", "TUNNELS": "TUNL", "TUNNL": "TUNL", "TUNL": "TUNL",
+	"TURNPIKE": "TPKE", "TRNPK": "TPKE", "TURNPK": "TPKE", "TPKE": "TPKE",
+	"UNDERPASS": "UPAS", "UPAS": "UPAS",
+	"VALLEY": "VLY", "VALLY": "VLY", "VLLY": "VLY", "VLY": "VLY",
+	"VIADUCT": "VIA", "VDCT": "VIA", "VIADCT": "VIA", "VIA": "VIA",
+	"VIEW": "VW", "VW": "VW",
+	"VILLAGE": "VLG", "VILL": "VLG", "VILLAG": "VLG", "VILLG": "VLG", "VLG": "VLG",
+	"VISTA": "VIS", "VIST": "VIS", "VST": "VIS", "VSTA": "VIS", "VIS": "VIS",
+	"WALK": "WALK", "WALKS": "WALK",
+	"WAY": "WAY", "WY": "WAY",
+	"WELLS": "WLS", "WLS": "WLS",
+}
+
+// unitDesignators are the secondary unit designators, # stands for a unit
+// of unknown kind
+var unitDesignators = map[string]string{
+	"APARTMENT": "APT", "APT": "APT",
+	"BUILDING": "BLDG", "BLDG": "BLDG",
+	"DEPARTMENT": "DEPT", "DEPT": "DEPT",
+	"FLOOR": "FL", "FL": "FL",
+	"HANGAR": "HNGR", "HNGR": "HNGR",
+	"LOT":  "LOT",
+	"PIER": "PIER",
+	"ROOM": "RM", "RM": "RM",
+	"SLIP":  "SLIP",
+	"SPACE": "SPC", "SPC": "SPC",
+	"STOP":  "STOP",
+	"SUITE": "STE", "STE": "STE",
+	"TRAILER": "TRLR", "TRLR": "TRLR",
+	"UNIT":     "UNIT",
+	"#":        "#",
+	"BASEMENT": "BSMT", "BSMT": "BSMT",
+	"FRONT": "FRNT", "FRNT": "FRNT",
+	"LOBBY": "LBBY", "LBBY": "LBBY",
+	"LOWER": "LOWR", "LOWR": "LOWR",
+	"OFFICE": "OFC", "OFC": "OFC",
+	"PENTHOUSE": "PH", "PH": "PH",
+	"REAR":  "REAR",
+	"SIDE":  "SIDE",
+	"UPPER": "UPPR", "UPPR": "UPPR",
+}
+
+// unitsWithoutNumber are the designators that are not followed by a number
+// or letter
+var unitsWithoutNumber = map[string]bool{
+	"BSMT": true, "FRNT": true, "LBBY": true, "LOWR": true, "OFC": true, "PH": true, "REAR": true, "SIDE": true, "UPPR": true,
+}
+
+// states maps the two letter codes, and the names of the states, DC and
+// the territories, to the code
+var states = map[string]string{
+	"AL": "AL", "ALABAMA": "AL",
+	"AK": "AK", "ALASKA": "AK",
+	"AZ": "AZ", "ARIZONA": "AZ",
+	"AR": "AR", "ARKANSAS": "AR",
+	"CA": "CA", "CALIFORNIA": "CA",
+	"CO": "CO", "COLORADO": "CO",
+	"CT": "CT", "CONNECTICUT": "CT",
+	"DE": "DE", "DELAWARE": "DE",
+	"DC": "DC", "DISTRICT OF COLUMBIA": "DC",
+	"FL": "FL", "FLORIDA": "FL",
+	"GA": "GA", "GEORGIA": "GA",
+	"HI": "HI", "HAWAII": "HI",
+	"ID": "ID", "IDAHO": "ID",
+	"IL": "IL", "ILLINOIS": "IL",
+	"IN": "IN", "INDIANA": "IN",
+	"IA": "IA", "IOWA": "IA",
+	"KS": "KS", "KANSAS": "KS",
+	"KY": "KY", "KENTUCKY": "KY",
+	"LA": "LA", "LOUISIANA": "LA",
+	"ME": "ME", "MAINE": "ME",
+	"MD": "MD", "MARYLAND": "MD",
+	"MA": "MA", "MASSACHUSETTS": "MA",
+	"MI": "MI", "MICHIGAN": "MI",
+	"MN": "MN", "MINNESOTA": "MN",
+	"MS": "MS", "MISSISSIPPI": "MS",
+	"MO": "MO", "MISSOURI": "MO",
+	"MT": "MT", "MONTANA": "MT",
+	"NE": "NE", "NEBRASKA": "NE",
+	"NV": "NV", "NEVADA": "NV",
+	"NH": "NH", "NEW HAMPSHIRE": "NH",
+	"NJ": "NJ", "NEW JERSEY": "NJ",
+	"NM": "NM", "NEW MEXICO": "NM",
+	"NY": "NY", "NEW YORK": "NY",
+	"NC": "NC", "NORTH CAROLINA": "NC",
+	"ND": "ND", "NORTH DAKOTA": "ND",
+	"OH": "OH", "OHIO": "OH",
+	"OK": "OK", "OKLAHOMA": "OK",
+	"OR": "OR", "OREGON": "OR",
+	"PA": "PA", "PENNSYLVANIA": "PA",
+	"RI": "RI", "RHODE ISLAND": "RI",
+	"SC": "SC", "SOUTH CAROLINA": "SC",
+	"SD": "SD", "SOUTH DAKOTA": "SD",
+	"TN": "TN", "TENNESSEE": "TN",
+	"TX": "TX", "TEXAS": "TX",
+	"UT": "UT", "UTAH": "UT",
+	"VT": "VT", "VERMONT": "VT",
+	"VA": "VA", "VIRGINIA": "VA",
+	"WA": "WA", "WASHINGTON": "WA",
+	"WV": "WV", "WEST VIRGINIA": "WV",
+	"WI": "WI", "WISCONSIN": "WI",
+	"WY": "WY", "WYOMING": "WY",
+	"AS": "AS", "AMERICAN SAMOA": "AS",
+	"GU": "GU", "GUAM": "GU",
+	"MP": "MP", "NORTHERN MARIANA ISLANDS": "MP",
+	"PR": "PR", "PUERTO RICO": "PR",
+	"VI": "VI", "VIRGIN ISLANDS": "VI",
+}
+
+// cityPrefixes are written out at the start of a city name, so St Louis and
+// Saint Louis are the same city
+var cityPrefixes = map[string]string{
+	"ST":  "SAINT",
+	"STE": "SAINTE",
+	"FT":  "FORT",
+	"MT":  "MOUNT",
+	"PT":  "POINT",
+}
@@ -0,0 +1,202 @@
+package production
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"test/nrel"
+)
+
+const sqFtToSqM = 0.09290304
+
+// Config holds the assumptions used to turn a roof facet into a PV system
+type Config struct {
+	// UsableAreaFraction is the share of a facet that can hold modules
+	UsableAreaFraction float64
+	// ModuleKwPerSqM is the nameplate DC capacity per square meter of modules
+	ModuleKwPerSqM float64
+	Losses         float64
+	ArrayType      int
+	ModuleType     int
+	// Concurrency limits the PVWatts calls made at once for a report
+	Concurrency int
+}
+
+// Facet is one roof plane of the Radiance deliverable
+type Facet struct {
+	Designator string
+	// Azimuth is the compass direction the facet faces, 180 is south
+	Azimuth float64
+	// Tilt is the facet pitch in degrees
+	Tilt     float64
+	AreaSqFt float64
+	// SolarAccess is the unshaded share of sunlight, 0 to 1, nil when the
+	// deliverable has none and the facet is taken as unshaded
+	SolarAccess *float64
+	// SolarAccessMonthly replaces SolarAccess month by month when it holds
+	// twelve values. Months left nil use SolarAccess.
+	SolarAccessMonthly []*float64
+	// SystemCapacity overrides the area based estimate when set, in kW DC
+	SystemCapacity float64
+}
+
+// FacetProduction is the PVWatts estimate of one facet
+type FacetProduction struct {
+	Designator     string
+	Azimuth        float64
+	Tilt           float64
+	AreaSqFt       float64
+	SystemCapacity float64
+	// AcAnnual is the yearly AC output in kWh after shading
+	AcAnnual float64
+	// SolradAnnual is the daily irradiance on the facet plane in kWh/m2/day
+	SolradAnnual float64
+	// AcMonthly is the AC output in kWh of each month after shading
+	AcMonthly []float64
+	// Err is why PVWatts failed for the facet, which then has no output
+	Err error
+}
+
+// Estimator computes per facet production with PVWatts
+type Estimator struct {
+	client *nrel.Client
+	cfg    Config
+}
+
+func NewEstimator(client *nrel.Client, cfg Config) *Estimator {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	return &Estimator{client: client, cfg: cfg}
+}
+
+// Capacity is the DC size in kW a facet can hold
+func (e *Estimator) Capacity(facet Facet) float64 {
+	if facet.SystemCapacity > 0 {
+		return facet.SystemCapacity
+	}
+	return facet.AreaSqFt * sqFtToSqM * e.cfg.UsableAreaFraction * e.cfg.ModuleKwPerSqM
+}
+
+// Estimate runs PVWatts for every facet at lat/lon using the facet's own
+// azimuth and tilt. Facets too small for a system get a zero estimate. The
+// results are returned even when PVWatts fails for some facets, with Err set
+// on those and the error of the first one returned.
+func (e *Estimator) Estimate(ctx context.Context, lat float64, lon float64, facets []Facet) ([]FacetProduction, error) {
+	results := make([]FacetProduction, len(facets))
+	limit := make(chan struct{}, e.cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, facet := range facets {
+		results[i] = FacetProduction{
+			Designator:     facet.Designator,
+			Azimuth:        facet.Azimuth,
+			Tilt:           facet.Tilt,
+			AreaSqFt:       facet.AreaSqFt,
+			SystemCapacity: e.Capacity(facet),
+		}
+		if results[i].SystemCapacity < nrel.MinSystemCapacity {
+			results[i].SystemCapacity = 0
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, facet Facet) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			response, err := e.client.PVWatts(ctx, nrel.Request{
+				Lat:            lat,
+				Lon:            lon,
+				SystemCapacity: results[i].SystemCapacity,
+				Azimuth:        facet.Azimuth,
+				Tilt:           facet.Tilt,
+				ArrayType:      e.cfg.ArrayType,
+				ModuleType:     e.cfg.ModuleType,
+				Losses:         e.cfg.Losses,
+			})
+			if err != nil {
+				results[i].Err = fmt.Errorf("facet %s: %w", facet.Designator, err)
+				return
+			}
+			shade := facet.shade()
+			results[i].AcAnnual = response.Outputs.AcAnnual * shade
+			results[i].SolradAnnual = response.Outputs.SolradAnnual
+			results[i].AcMonthly = make([]float64, len(response.Outputs.AcMonthly))
+			for month, ac := range response.Outputs.AcMonthly {
+				results[i].AcMonthly[month] = ac * shade
+			}
+			// Monthly shading is applied to each month and the year is their sum
+			if len(facet.SolarAccessMonthly) == 12 && len(response.Outputs.AcMonthly) == 12 {
+				results[i].AcAnnual = 0
+				for month, ac := range response.Outputs.AcMonthly {
+					results[i].AcMonthly[month] = ac * facet.monthShade(month)
+					results[i].AcAnnual += results[i].AcMonthly[month]
+				}
+			}
+		}(i, facet)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+	return results, nil
+}
+
+// shade is the share of the yearly output left after shading
+func (f Facet) shade() float64 {
+	return solarAccess(f.SolarAccess)
+}
+
+// monthShade is the share of the output of month left after shading, the
+// yearly one when the month has no solar access of its own
+func (f Facet) monthShade(month int) float64 {
+	if month < len(f.SolarAccessMonthly) && f.SolarAccessMonthly[month] != nil {
+		return solarAccess(f.SolarAccessMonthly[month])
+	}
+	return f.shade()
+}
+
+// solarAccess treats a missing value as unshaded. A value of 0 is a facet
+// fully shaded and produces nothing.
+func solarAccess(value *float64) float64 {
+	if value == nil {
+		return 1
+	}
+	return math.Max(0, math.Min(*value, 1))
+}
+
+// NormalizeAzimuth maps any angle onto the 0-360 range PVWatts expects
+func NormalizeAzimuth(degrees float64) float64 {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	return degrees
+}
+
+// ParseTilt reads the facet pitch in degrees, falling back to the rise per
+// foot ("6/12" or "6") when the degree value is missing
+func ParseTilt(pitchDeg string, pitch string) (float64, bool) {
+	if deg, err := strconv.ParseFloat(strings.TrimSpace(pitchDeg), 64); err == nil && deg >= 0 && deg <= 90 {
+		return deg, true
+	}
+	rise, run := strings.TrimSpace(pitch), "12"
+	if parts := strings.SplitN(rise, "/", 2); len(parts) == 2 {
+		rise, run = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	riseValue, err1 := strconv.ParseFloat(rise, 64)
+	runValue, err2 := strconv.ParseFloat(run, 64)
+	if err1 != nil || err2 != nil || runValue <= 0 || riseValue < 0 {
+		return 0, false
+	}
+	return math.Atan(riseValue/runValue) * 180 / math.Pi, true
+}
@@ -0,0 +1,28 @@
+package production
+
+import "testing"
+
+func TestFacetShade(t *testing.T) {
+	zero, half, over := 0.0, 0.5, 1.5
+	monthly := make([]*float64, 12)
+	monthly[0], monthly[1] = &zero, &half
+	tests := []struct {
+		name  string
+		facet Facet
+		// want is the shade of the year, then of January, February and March
+		want [4]float64
+	}{
+		{name: "missing", facet: Facet{}, want: [4]float64{1, 1, 1, 1}},
+		{name: "fully shaded", facet: Facet{SolarAccess: &zero}, want: [4]float64{0, 0, 0, 0}},
+		{name: "half", facet: Facet{SolarAccess: &half}, want: [4]float64{0.5, 0.5, 0.5, 0.5}},
+		{name: "above 1", facet: Facet{SolarAccess: &over}, want: [4]float64{1, 1, 1, 1}},
+		{name: "monthly", facet: Facet{SolarAccess: &over, SolarAccessMonthly: monthly}, want: [4]float64{1, 0, 0.5, 1}},
+		{name: "monthly without annual", facet: Facet{SolarAccessMonthly: monthly}, want: [4]float64{1, 0, 0.5, 1}},
+	}
+	for _, test := range tests {
+		got := [4]float64{test.facet.shade(), test.facet.monthShade(0), test.facet.monthShade(1), test.facet.monthShade(2)}
+		if got != test.want {
+			t.Errorf("%s: shade = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package proposal
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"test/finance"
+)
+
+// Branding is the company the proposal is issued by
+type Branding struct {
+	Name         string
+	AddressLines []string
+	// Logo is a PNG or JPEG image, left out when empty
+	Logo []byte
+	// Color is the RGB accent of headings and table headers
+	Color [3]int
+}
+
+// Image is one aerial view of the property
+type Image struct {
+	Title string
+	// Data is a PNG or JPEG image, the view is skipped when empty
+	Data []byte
+}
+
+// System is the recommended PV system and its production
+type System struct {
+	Module   string
+	Panels   int
+	SystemKw float64
+	// AcAnnual is the first year AC production in kWh
+	AcAnnual float64
+	// AcMonthly is the AC production of each month, January first
+	AcMonthly []float64
+}
+
+// Proposal is everything printed in the document
+type Proposal struct {
+	Branding Branding
+	Address  string
+	ReportID string
+	Date     time.Time
+	// Images are the overhead view followed by the side views
+	Images []Image
+	// FacetHeader and Facets are the roof facet table
+	FacetHeader []string
+	Facets      [][]string
+	System      System
+	// Finance is printed on the pricing page, which is left out when nil
+	Finance *finance.Analysis
+}
+
+// DefaultColor is the accent used when the branding has none
+var DefaultColor = [3]int{76, 153, 0}
+
+var months = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+const (
+	pageWidth = 215.9
+	margin    = 15.0
+	bodyWidth = pageWidth - 2*margin
+)
+
+// Build renders the proposal as a PDF
+func Build(p Proposal) ([]byte, error) {
+	if p.Branding.Color == [3]int{} {
+		p.Branding.Color = DefaultColor
+	}
+	if p.Date.IsZero() {
+		p.Date = time.Now()
+	}
+
+	doc := &document{pdf: gofpdf.New("P", "mm", "Letter", ""), p: p}
+	doc.tr = doc.pdf.UnicodeTranslatorFromDescriptor("")
+	doc.pdf.SetMargins(margin, 25, margin)
+	doc.pdf.SetAutoPageBreak(true, 20)
+	doc.pdf.SetTitle(doc.tr(p.Branding.Name+" Solar Proposal "+p.ReportID), false)
+	doc.pdf.SetAuthor(doc.tr(p.Branding.Name), false)
+	doc.pdf.SetHeaderFuncMode(doc.header, true)
+	doc.pdf.SetFooterFunc(doc.footer)
+
+	doc.cover()
+	doc.views()
+	doc.system()
+	if p.Finance != nil {
+		doc.pricing()
+	}
+
+	var buf bytes.Buffer
+	if err := doc.pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("proposal: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type document struct {
+	pdf    *gofpdf.Fpdf
+	tr     func(string) string
+	p      Proposal
+	images int
+}
+
+func (d *document) accent() (int, int, int) {
+	c := d.p.Branding.Color
+	return c[0], c[1], c[2]
+}
+
+func (d *document) header() {
+	d.pdf.SetFillColor(d.accent())
+	d.pdf.Rect(0, 0, pageWidth, 8, "F")
+	if d.pdf.PageNo() == 1 {
+		return
+	}
+	d.pdf.SetY(12)
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.SetTextColor(80, 80, 80)
+	d.pdf.CellFormat(bodyWidth/2, 6, d.tr(d.p.Branding.Name), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(bodyWidth/2, 6, d.tr(d.p.Address), "", 1, "R", false, 0, "")
+	d.pdf.SetY(25)
+}
+
+func (d *document) footer() {
+	d.pdf.SetY(-15)
+	d.pdf.SetFont("Helvetica", "", 8)
+	d.pdf.SetTextColor(120, 120, 120)
+	d.pdf.CellFormat(bodyWidth/2, 6, d.tr(fmt.Sprintf("Report %s, prepared %s", d.p.ReportID, d.p.Date.Format("January 2, 2006"))), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(bodyWidth/2, 6, fmt.Sprintf("Page %d", d.pdf.PageNo()), "", 0, "R", false, 0, "")
+}
+
+func (d *document) heading(text string) {
+	d.pdf.SetFont("Helvetica", "B", 16)
+	d.pdf.SetTextColor(d.accent())
+	d.pdf.CellFormat(bodyWidth, 10, d.tr(text), "", 1, "L", false, 0, "")
+	d.pdf.SetTextColor(0, 0, 0)
+	d.pdf.Ln(2)
+}
+
+func (d *document) cover() {
+	d.pdf.AddPage()
+	y := 20.0
+	if len(d.p.Branding.Logo) > 0 {
+		if name, ok := d.register(d.p.Branding.Logo); ok {
+			d.fit(name, margin, y, 60, 25)
+			y += 30
+		}
+	}
+	d.pdf.SetXY(margin, y)
+	d.pdf.SetFont("Helvetica", "B", 28)
+	d.pdf.SetTextColor(d.accent())
+	d.pdf.CellFormat(bodyWidth, 14, d.tr("Solar Proposal"), "", 1, "L", false, 0, "")
+	d.pdf.SetTextColor(0, 0, 0)
+	d.pdf.SetFont("Helvetica", "", 13)
+	d.pdf.CellFormat(bodyWidth, 8, d.tr("Prepared for "+d.p.Address), "", 1, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.SetTextColor(80, 80, 80)
+	d.pdf.CellFormat(bodyWidth, 6, d.tr(fmt.Sprintf("Report %s, %s", d.p.ReportID, d.p.Date.Format("January 2, 2006"))), "", 1, "L", false, 0, "")
+	d.pdf.SetTextColor(0, 0, 0)
+
+	if len(d.p.Images) > 0 {
+		if name, ok := d.register(d.p.Images[0].Data); ok {
+			d.fit(name, margin, d.pdf.GetY()+6, bodyWidth, 120)
+		}
+	}
+
+	d.pdf.SetY(215)
+	d.pdf.SetFont("Helvetica", "B", 12)
+	d.pdf.CellFormat(bodyWidth, 7, d.tr(d.p.Branding.Name), "", 1, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 10)
+	for _, line := range d.p.Branding.AddressLines {
+		if strings.TrimSpace(line) != "" {
+			d.pdf.CellFormat(bodyWidth, 5, d.tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+}
+
+func (d *document) views() {
+	var views []Image
+	for i, image := range d.p.Images {
+		if i > 0 && len(image.Data) > 0 {
+			views = append(views, image)
+		}
+	}
+	if len(views) == 0 {
+		return
+	}
+
+	d.pdf.AddPage()
+	d.heading("Property Views")
+	top := d.pdf.GetY()
+	width, height := (bodyWidth-6)/2, 95.0
+	for i, view := range views {
+		x := margin + float64(i%2)*(width+6)
+		y := top + float64(i/2)*(height+14)
+		d.pdf.SetXY(x, y)
+		d.pdf.SetFont("Helvetica", "B", 11)
+		d.pdf.CellFormat(width, 6, d.tr(view.Title), "", 0, "L", false, 0, "")
+		if name, ok := d.register(view.Data); ok {
+			d.fit(name, x, y+7, width, height)
+		}
+	}
+}
+
+func (d *document) system() {
+	d.pdf.AddPage()
+	d.heading("Recommended System")
+	s := d.p.System
+	d.pdf.SetFont("Helvetica", "", 11)
+	if s.SystemKw > 0 {
+		d.line(fmt.Sprintf("%d x %s modules, %.2f kW DC", s.Panels, s.Module, s.SystemKw))
+		d.line(fmt.Sprintf("Estimated first year production: %s kWh", thousands(s.AcAnnual)))
+	} else {
+		d.line("No roof facet of this property qualifies for panels.")
+	}
+	d.pdf.Ln(4)
+
+	if len(d.p.Facets) > 0 {
+		d.pdf.SetFont("Helvetica", "B", 12)
+		d.pdf.CellFormat(bodyWidth, 8, d.tr("Roof Facets"), "", 1, "L", false, 0, "")
+		d.table(d.p.FacetHeader, d.p.Facets)
+		d.pdf.Ln(6)
+	}
+
+	if len(s.AcMonthly) == 12 {
+		d.pdf.SetFont("Helvetica", "B", 12)
+		d.pdf.CellFormat(bodyWidth, 8, d.tr("Estimated Monthly Production (kWh)"), "", 1, "L", false, 0, "")
+		d.chart(s.AcMonthly, 70)
+	}
+}
+
+func (d *document) pricing() {
+	f := d.p.Finance
+	d.pdf.AddPage()
+	d.heading("Pricing and Savings")
+	d.pdf.SetFont("Helvetica", "", 11)
+	rows := [][2]string{
+		{"System price", "$" + thousands(f.SystemCost)},
+		{"Incentives", "-$" + thousands(f.Incentives)},
+		{"Net cost", "$" + thousands(f.NetCost)},
+		{"First year savings", "$" + thousands(f.FirstYearSavings)},
+		{fmt.Sprintf("Savings over %d years", len(f.CashFlow)-1), "$" + thousands(f.LifetimeSavings)},
+		{"Simple payback", fmt.Sprintf("%.1f years", f.PaybackYears)},
+		{"Net present value", money(f.NPV)},
+	}
+	if f.HasIRR {
+		rows = append(rows, [2]string{"Internal rate of return", fmt.Sprintf("%.1f%%", f.IRR)})
+	}
+	for _, row := range rows {
+		d.pdf.CellFormat(70, 6, d.tr(row[0]), "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(50, 6, d.tr(row[1]), "", 1, "R", false, 0, "")
+	}
+	d.pdf.Ln(4)
+
+	d.pdf.SetFont("Helvetica", "B", 12)
+	d.pdf.CellFormat(bodyWidth, 8, d.tr("Cash Flow"), "", 1, "L", false, 0, "")
+	var table [][]string
+	for _, year := range f.CashFlow {
+		table = append(table, []string{
+			fmt.Sprint(year.Year),
+			thousands(year.Production),
+			fmt.Sprintf("%.3f", year.Rate),
+			money(year.Savings),
+			money(year.CashFlow),
+			money(year.Cumulative),
+		})
+	}
+	d.table([]string{"Year", "Production (kWh)", "Rate ($/kWh)", "Savings", "Cash Flow", "Cumulative"}, table)
+}
+
+func (d *document) line(text string) {
+	d.pdf.CellFormat(bodyWidth, 7, d.tr(text), "", 1, "L", false, 0, "")
+}
+
+// table prints rows with a shaded header, splitting the body width evenly
+func (d *document) table(header []string, rows [][]string) {
+	if len(header) == 0 {
+		return
+	}
+	width := bodyWidth / float64(len(header))
+	d.pdf.SetFont("Helvetica", "B", 8)
+	d.pdf.SetFillColor(d.accent())
+	d.pdf.SetTextColor(255, 255, 255)
+	for _, title := range header {
+		d.pdf.CellFormat(width, 7, d.tr(title), "1", 0, "C", true, 0, "")
+	}
+	d.pdf.Ln(-1)
+	d.pdf.SetFont("Helvetica", "", 8)
+	d.pdf.SetTextColor(0, 0, 0)
+	d.pdf.SetFillColor(242, 242, 242)
+	for i, row := range rows {
+		for j := range header {
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			d.pdf.CellFormat(width, 5.5, d.tr(cell), "1", 0, "C", i%2 == 1, 0, "")
+		}
+		d.pdf.Ln(-1)
+	}
+}
+
+// chart draws the monthly values as bars, height is in mm
+func (d *document) chart(values []float64, height float64) {
+	top := d.pdf.GetY() + 2
+	if top+height+10 > 260 {
+		d.pdf.AddPage()
+		top = d.pdf.GetY()
+	}
+	max := 0.0
+	for _, value := range values {
+		max = math.Max(max, value)
+	}
+	if max <= 0 {
+		return
+	}
+	slot := bodyWidth / float64(len(values))
+	d.pdf.SetFillColor(d.accent())
+	d.pdf.SetFont("Helvetica", "", 7)
+	for i, value := range values {
+		barHeight := height * value / max
+		x := margin + float64(i)*slot
+		d.pdf.Rect(x+slot*0.15, top+height-barHeight, slot*0.7, barHeight, "F")
+		d.pdf.SetXY(x, top+height-barHeight-4)
+		d.pdf.CellFormat(slot, 4, thousands(value), "", 0, "C", false, 0, "")
+		d.pdf.SetXY(x, top+height+1)
+		d.pdf.CellFormat(slot, 4, months[i], "", 0, "C", false, 0, "")
+	}
+	d.pdf.SetDrawColor(0, 0, 0)
+	d.pdf.Line(margin, top+height, margin+bodyWidth, top+height)
+	d.pdf.SetY(top + height + 8)
+}
+
+// register adds a PNG or JPEG image to the document
+func (d *document) register(data []byte) (string, bool) {
+	var imageType string
+	switch http.DetectContentType(data) {
+	case "image/png":
+		imageType = "PNG"
+	case "image/jpeg":
+		imageType = "JPG"
+	default:
+		return "", false
+	}
+	d.images++
+	name := fmt.Sprintf("image%d", d.images)
+	d.pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if d.pdf.Err() {
+		//A broken image is left out instead of failing the whole document
+		d.pdf.ClearError()
+		return "", false
+	}
+	return name, true
+}
+
+// fit draws a registered image as large as it fits in the box, keeping its proportions
+func (d *document) fit(name string, x, y, width, height float64) {
+	imageWidth, imageHeight := d.pdf.GetImageInfo(name).Extent()
+	if imageWidth <= 0 || imageHeight <= 0 {
+		return
+	}
+	scale := math.Min(width/imageWidth, height/imageHeight)
+	d.pdf.ImageOptions(name, x, y, imageWidth*scale, imageHeight*scale, false, gofpdf.ImageOptions{}, 0, "")
+}
+
+// thousands formats a number without decimals and with thousands separators
+func thousands(value float64) string {
+	digits := fmt.Sprintf("%.0f", math.Abs(value))
+	var out strings.Builder
+	if math.Round(value) < 0 {
+		out.WriteString("-")
+	}
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteString(",")
+		}
+		out.WriteRune(digit)
+	}
+	return out.String()
+}
+
+func money(value float64) string {
+	if math.Round(value) < 0 {
+		return "-$" + thousands(-value)
+	}
+	return "$" + thousands(value)
+}
@@ -0,0 +1,51 @@
+package radiance
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError reports a deliverable that is not well formed JSON
+type SyntaxError struct {
+	// Offset is the byte the decoder stopped at
+	Offset int64
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("radiance: malformed JSON at byte %d: %s", e.Offset, e.Msg)
+}
+
+// VersionError reports a deliverable in a format version this package does
+// not read
+type VersionError struct {
+	Version string
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("radiance: format version %q is not supported, expected %s", e.Version, Version)
+}
+
+// FieldError is a missing or invalid field, named by its path such as
+// roofs[2].irradiance[0].TSRF
+type FieldError struct {
+	Field   string
+	Problem string
+}
+
+func (e *FieldError) Error() string {
+	return "radiance: " + e.Field + " " + e.Problem
+}
+
+// ValidationError lists every invalid field of a deliverable
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = problem.Field + " " + problem.Problem
+	}
+	return "radiance: invalid deliverable:\n  " + strings.Join(lines, "\n  ")
+}
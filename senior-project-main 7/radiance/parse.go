@@ -0,0 +1,292 @@
+package radiance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Parse decodes a deliverable from r in a single pass, reading the roofs one
+// at a time, and checks it against the format. It returns a *SyntaxError for
+// malformed JSON, a *VersionError for an unsupported format version and a
+// *ValidationError listing every missing or invalid field. TSRF and SA
+// given as percentages are returned as fractions.
+func Parse(r io.Reader) (Model, error) {
+	p := &parser{dec: json.NewDecoder(r)}
+	model, err := p.model()
+	if err != nil {
+		return Model{}, err
+	}
+	if len(p.problems) > 0 {
+		return Model{}, &ValidationError{Problems: p.problems}
+	}
+	fractions(&model)
+	return model, nil
+}
+
+// fractions turns the TSRF and SA of a deliverable giving percentages into
+// fractions. A deliverable uses one unit throughout, so a single value above
+// 1 means they all are percentages.
+func fractions(model *Model) {
+	percentages := false
+	for _, roof := range model.Roofs {
+		for _, irradiance := range roof.Irradiance {
+			if irradiance.Tsrf > 1 || irradiance.Sa > 1 {
+				percentages = true
+			}
+		}
+	}
+	if !percentages {
+		return
+	}
+	for _, roof := range model.Roofs {
+		for i := range roof.Irradiance {
+			roof.Irradiance[i].Tsrf /= 100
+			roof.Irradiance[i].Sa /= 100
+		}
+	}
+}
+
+type parser struct {
+	dec      *json.Decoder
+	problems []*FieldError
+}
+
+func (p *parser) problem(field string, format string, args ...interface{}) {
+	p.problems = append(p.problems, &FieldError{Field: field, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) model() (Model, error) {
+	model := Model{Version: Version}
+	if err := p.delim('{', "the deliverable must be a JSON object"); err != nil {
+		return model, err
+	}
+	var hasLocation, hasRoofs bool
+	for p.dec.More() {
+		token, err := p.dec.Token()
+		if err != nil {
+			return model, p.syntaxError(err)
+		}
+		key, _ := token.(string)
+		switch key {
+		case "version":
+			var version interface{}
+			if err := p.decode(&version, "version"); err != nil {
+				return model, err
+			}
+			if version != nil {
+				model.Version = strings.TrimSpace(fmt.Sprint(version))
+			}
+		case "reportid":
+			var id interface{}
+			if err := p.decode(&id, "reportid"); err != nil {
+				return model, err
+			}
+			if id != nil {
+				model.ReportID = fmt.Sprint(id)
+			}
+		case "location":
+			hasLocation = true
+			if err := p.decode(&model.Location, "location"); err != nil {
+				return model, err
+			}
+			p.checkLocation(model.Location)
+		case "roofs":
+			hasRoofs = true
+			roofs, err := p.roofs()
+			if err != nil {
+				return model, err
+			}
+			model.Roofs = roofs
+		default:
+			var skip json.RawMessage
+			if err := p.decode(&skip, key); err != nil {
+				return model, err
+			}
+		}
+	}
+	if err := p.delim('}', "the deliverable must be a JSON object"); err != nil {
+		return model, err
+	}
+
+	//Fields of another format version mean something else, so their problems are not reported
+	if major := strings.SplitN(model.Version, ".", 2)[0]; major != strings.SplitN(Version, ".", 2)[0] {
+		return model, &VersionError{Version: model.Version}
+	}
+	if !hasLocation {
+		p.problem("location", "is required")
+	}
+	if !hasRoofs {
+		p.problem("roofs", "is required")
+	}
+	return model, nil
+}
+
+// roofs reads the roofs array one facet at a time
+func (p *parser) roofs() ([]Roof, error) {
+	token, err := p.dec.Token()
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	if token == nil {
+		p.problem("roofs", "is required")
+		return nil, nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		p.problem("roofs", "must be an array")
+		//The rest of a scalar value was already read, an object still has to be skipped
+		if ok && delim == '{' {
+			return nil, p.skipRest()
+		}
+		return nil, nil
+	}
+
+	roofs := []Roof{}
+	designators := map[string]int{}
+	for i := 0; p.dec.More(); i++ {
+		field := fmt.Sprintf("roofs[%d]", i)
+		var roof Roof
+		before := len(p.problems)
+		if err := p.decode(&roof, field); err != nil {
+			return nil, err
+		}
+		//An entry that is not an object has no fields to check
+		if len(p.problems) > before && p.problems[len(p.problems)-1].Field == field {
+			continue
+		}
+		p.checkRoof(field, roof)
+		if first, ok := designators[roof.Designator]; ok && roof.Designator != "" {
+			p.problem(field+".designator", "%q is already used by roofs[%d]", roof.Designator, first)
+		} else {
+			designators[roof.Designator] = i
+		}
+		roofs = append(roofs, roof)
+	}
+	if _, err := p.dec.Token(); err != nil {
+		return nil, p.syntaxError(err)
+	}
+	if len(roofs) == 0 {
+		p.problem("roofs", "must list at least one roof facet")
+	}
+	return roofs, nil
+}
+
+func (p *parser) checkLocation(location Location) {
+	if location.Latitude == 0 && location.Longitude == 0 {
+		p.problem("location", "needs a latitude and longitude")
+		return
+	}
+	if location.Latitude < -90 || location.Latitude > 90 {
+		p.problem("location.latitude", "must be between -90 and 90, got %g", location.Latitude)
+	}
+	if location.Longitude < -180 || location.Longitude > 180 {
+		p.problem("location.longitude", "must be between -180 and 180, got %g", location.Longitude)
+	}
+}
+
+func (p *parser) checkRoof(field string, roof Roof) {
+	if strings.TrimSpace(roof.Designator) == "" {
+		p.problem(field+".designator", "is required")
+	}
+	if area, err := strconv.ParseFloat(strings.TrimSpace(roof.Unroundedsize), 64); err != nil || area <= 0 {
+		p.problem(field+".unroundedsize", "must be a positive area in square feet, got %q", roof.Unroundedsize)
+	}
+	if strings.TrimSpace(roof.Pitch) == "" && strings.TrimSpace(roof.PitchDeg) == "" {
+		p.problem(field+".pitch", "or pitchDeg is required")
+	}
+	if roof.Orientation < 0 || roof.Orientation > 360 {
+		p.problem(field+".orientation", "must be between 0 and 360 degrees, got %g", roof.Orientation)
+	}
+	for i, irradiance := range roof.Irradiance {
+		entry := fmt.Sprintf("%s.irradiance[%d]", field, i)
+		//Values are fractions, some deliverables give percentages instead
+		if irradiance.Tsrf < 0 || irradiance.Tsrf > 100 {
+			p.problem(entry+".TSRF", "must be a fraction or a percentage, got %g", irradiance.Tsrf)
+		}
+		if irradiance.Sa < 0 || irradiance.Sa > 100 {
+			p.problem(entry+".SA", "must be a fraction or a percentage, got %g", irradiance.Sa)
+		}
+		if irradiance.Month < 0 || irradiance.Month > 12 {
+			p.problem(entry+".month", "must be between 1 and 12, got %d", irradiance.Month)
+		}
+	}
+}
+
+// decode reads the next value into target. A value of the wrong type is a
+// problem of the field, anything else ends the parse.
+func (p *parser) decode(target interface{}, field string) error {
+	err := p.dec.Decode(target)
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		name := field
+		if typeError.Field != "" {
+			name += "." + typeError.Field
+		}
+		p.problem(name, "must be %s, got %s", jsonType(typeError.Type), typeError.Value)
+		return nil
+	}
+	if err != nil {
+		return p.syntaxError(err)
+	}
+	return nil
+}
+
+// jsonType names the JSON value a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	}
+	return "a number"
+}
+
+func (p *parser) delim(want json.Delim, problem string) error {
+	token, err := p.dec.Token()
+	if err != nil {
+		return p.syntaxError(err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return &SyntaxError{Offset: p.dec.InputOffset(), Msg: problem}
+	}
+	return nil
+}
+
+// skipRest consumes the rest of an object or array whose opening token was read
+func (p *parser) skipRest() error {
+	for depth := 1; depth > 0; {
+		token, err := p.dec.Token()
+		if err != nil {
+			return p.syntaxError(err)
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+func (p *parser) syntaxError(err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return &SyntaxError{Offset: syntax.Offset, Msg: syntax.Error()}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &SyntaxError{Offset: p.dec.InputOffset(), Msg: "unexpected end of the file"}
+	}
+	return &SyntaxError{Offset: p.dec.InputOffset(), Msg: err.Error()}
+}
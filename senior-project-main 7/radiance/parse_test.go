@@ -0,0 +1,147 @@
+package radiance
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const location = `"location": {"latitude": 34.1, "longitude": -118.1}`
+
+// roof is a valid facet with irradiance entries
+func roof(designator string, irradiance string) string {
+	return `{"designator": "` + designator + `", "unroundedsize": "512.4", "pitch": "6/12", "orientation": 181.2, "irradiance": [` + irradiance + `]}`
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		syntax  bool
+		version string
+		// fields are the fields of a ValidationError, in order
+		fields []string
+	}{
+		{name: "empty", input: ``, syntax: true},
+		{name: "not an object", input: `[]`, syntax: true},
+		{name: "truncated", input: `{` + location + `, "roofs": [` + roof("A", ""), syntax: true},
+		{name: "malformed", input: `{"roofs": [}`, syntax: true},
+		{name: "other major version", input: `{"version": "2.0", "roofs": 5}`, version: "2.0"},
+		{name: "nothing", input: `{}`, fields: []string{"location", "roofs"}},
+		{name: "null roofs", input: `{` + location + `, "roofs": null}`, fields: []string{"roofs"}},
+		{name: "roofs not an array", input: `{` + location + `, "roofs": {"A": 1}}`, fields: []string{"roofs"}},
+		{name: "no roofs", input: `{` + location + `, "roofs": []}`, fields: []string{"roofs"}},
+		{name: "location without coordinates", input: `{"location": {}, "roofs": [` + roof("A", "") + `]}`, fields: []string{"location"}},
+		{
+			name:   "location out of range",
+			input:  `{"location": {"latitude": 91, "longitude": -181}, "roofs": [` + roof("A", "") + `]}`,
+			fields: []string{"location.latitude", "location.longitude"},
+		},
+		{
+			name:   "wrong type",
+			input:  `{` + location + `, "roofs": [{"designator": "A", "unroundedsize": "10", "pitch": "6/12", "orientation": "south"}]}`,
+			fields: []string{"roofs[0].orientation"},
+		},
+		{
+			name:   "roof not an object",
+			input:  `{` + location + `, "roofs": [5, ` + roof("A", "") + `]}`,
+			fields: []string{"roofs[0]"},
+		},
+		{
+			name:   "invalid facet",
+			input:  `{` + location + `, "roofs": [{"designator": " ", "unroundedsize": "0", "orientation": 400}]}`,
+			fields: []string{"roofs[0].designator", "roofs[0].unroundedsize", "roofs[0].pitch", "roofs[0].orientation"},
+		},
+		{
+			name:   "invalid irradiance",
+			input:  `{` + location + `, "roofs": [` + roof("A", `{"TSRF": 150, "SA": -1, "month": 13}`) + `]}`,
+			fields: []string{"roofs[0].irradiance[0].TSRF", "roofs[0].irradiance[0].SA", "roofs[0].irradiance[0].month"},
+		},
+		{
+			name:   "duplicate designator",
+			input:  `{` + location + `, "roofs": [` + roof("A", "") + `, ` + roof("A", "") + `]}`,
+			fields: []string{"roofs[1].designator"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(test.input))
+			var syntaxErr *SyntaxError
+			var versionErr *VersionError
+			var validationErr *ValidationError
+			switch {
+			case test.syntax:
+				if !errors.As(err, &syntaxErr) {
+					t.Fatalf("err = %v, want a SyntaxError", err)
+				}
+			case test.version != "":
+				if !errors.As(err, &versionErr) || versionErr.Version != test.version {
+					t.Fatalf("err = %v, want a VersionError for %s", err, test.version)
+				}
+			default:
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("err = %v, want a ValidationError", err)
+				}
+				var fields []string
+				for _, problem := range validationErr.Problems {
+					fields = append(fields, problem.Field)
+				}
+				if !reflect.DeepEqual(fields, test.fields) {
+					t.Errorf("fields = %v, want %v\n%v", fields, test.fields, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseIrradiance(t *testing.T) {
+	tests := []struct {
+		name  string
+		roofs string
+		// want are the TSRF and SA of every irradiance entry, facet by facet
+		want [][][2]float64
+	}{
+		{
+			name:  "fractions",
+			roofs: roof("A", `{"TSRF": 0.91, "SA": 0.95}`) + `, ` + roof("B", `{"TSRF": 0.7, "SA": 1}`),
+			want:  [][][2]float64{{{0.91, 0.95}}, {{0.7, 1}}},
+		},
+		{
+			name:  "percentages",
+			roofs: roof("A", `{"TSRF": 91, "SA": 95}, {"TSRF": 0.5, "SA": 1, "month": 1}`) + `, ` + roof("B", `{"TSRF": 70, "SA": 100}`),
+			want:  [][][2]float64{{{0.91, 0.95}, {0.005, 0.01}}, {{0.7, 1}}},
+		},
+		{
+			name:  "missing irradiance",
+			roofs: `{"designator": "A", "unroundedsize": "512.4", "pitchDeg": "26.57", "orientation": 180}, ` + roof("B", ""),
+			want:  [][][2]float64{nil, nil},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model, err := Parse(strings.NewReader(`{` + location + `, "roofs": [` + test.roofs + `]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if model.Version != Version {
+				t.Errorf("Version = %q, want %q", model.Version, Version)
+			}
+			if len(model.Roofs) != len(test.want) {
+				t.Fatalf("%d roofs, want %d", len(model.Roofs), len(test.want))
+			}
+			for i, roof := range model.Roofs {
+				if len(roof.Irradiance) != len(test.want[i]) {
+					t.Fatalf("roof %s has %d irradiance entries, want %d", roof.Designator, len(roof.Irradiance), len(test.want[i]))
+				}
+				for j, irradiance := range roof.Irradiance {
+					want := test.want[i][j]
+					if math.Abs(irradiance.Tsrf-want[0]) > 1e-9 || math.Abs(irradiance.Sa-want[1]) > 1e-9 {
+						t.Errorf("roof %s entry %d = %g, %g, want %g, %g", roof.Designator, j, irradiance.Tsrf, irradiance.Sa, want[0], want[1])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package radiance
+
+// Version is the deliverable format this package reads. Files without a
+// version are read as this version, files of another major version are
+// rejected with a VersionError.
+const Version = "1.0"
+
+// Model is the Radiance deliverable of a report: the location of the
+// property and every roof facet with its irradiance
+type Model struct {
+	Version  string   `json:"version"`
+	ReportID string   `json:"reportid"`
+	Location Location `json:"location"`
+	Roofs    []Roof   `json:"roofs"`
+}
+
+type Location struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	Northorientation float64 `json:"northorientation"`
+	Address          string  `json:"address"`
+	City             string  `json:"city"`
+	Postal           string  `json:"postal"`
+	State            string  `json:"state"`
+}
+
+// Roof is one roof facet
+type Roof struct {
+	Designator    string `json:"designator"`
+	Unroundedsize string `json:"unroundedsize"`
+	Pitch         string `json:"pitch"`
+	PitchDeg      string `json:"pitchDeg"`
+	ID            string `json:"id"`
+	// Orientation is the compass direction the facet faces in degrees
+	Orientation float64 `json:"orientation"`
+	// Irradiance is empty for facets without irradiance data, whether the
+	// deliverable gives an empty array or leaves the key out
+	Irradiance []Irradiance `json:"irradiance"`
+}
+
+// Irradiance is the solar resource of a facet for the year, a month or a
+// named scenario. Tsrf and Sa are fractions from 0 to 1.
+type Irradiance struct {
+	Tsrf float64 `json:"TSRF"`
+	Sa   float64 `json:"SA"`
+	// Month is 1 to 12 for a monthly value and 0 for the annual one
+	Month    int    `json:"month,omitempty"`
+	Scenario string `json:"scenario,omitempty"`
+}
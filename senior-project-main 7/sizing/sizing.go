@@ -0,0 +1,105 @@
+package sizing
+
+import (
+	"fmt"
+	"math"
+)
+
+const sqFtToSqM = 0.09290304
+const ftToM = 0.3048
+
+// Module is one PV module of the catalog. Dimensions are in meters.
+type Module struct {
+	Name   string
+	Length float64
+	Width  float64
+	Watts  float64
+}
+
+// Config holds the module choice and the layout rules
+type Config struct {
+	Module Module
+	// EdgeSetbackFt is kept clear along the eaves and rakes of a facet
+	EdgeSetbackFt float64
+	// RidgeSetbackFt is kept clear below the ridge for fire access
+	RidgeSetbackFt float64
+	// MinTSRF skips facets whose total solar resource fraction is lower, 0 to 1
+	MinTSRF float64
+}
+
+// Facet is one roof plane to lay out
+type Facet struct {
+	Designator string
+	// AreaSqFt is the sloped area of the facet
+	AreaSqFt float64
+	// TSRF is the total solar resource fraction, 0 when unknown
+	TSRF float64
+}
+
+// FacetLayout is the panels that fit on one facet
+type FacetLayout struct {
+	Designator string
+	Panels     int
+	SystemKw   float64
+	// Skipped explains why a facet holds no panels
+	Skipped string
+}
+
+// Layout is the recommended system for a report
+type Layout struct {
+	Module   Module
+	Facets   []FacetLayout
+	Panels   int
+	SystemKw float64
+}
+
+// Plan lays modules out on every facet. The Radiance deliverable only gives
+// the area of a facet, so each facet is treated as a square of that area,
+// trimmed by the setbacks, and filled with a grid of modules in whichever
+// orientation fits more.
+func Plan(cfg Config, facets []Facet) (Layout, error) {
+	if cfg.Module.Length <= 0 || cfg.Module.Width <= 0 || cfg.Module.Watts <= 0 {
+		return Layout{}, fmt.Errorf("sizing: module %q needs a length, width and wattage", cfg.Module.Name)
+	}
+
+	layout := Layout{Module: cfg.Module}
+	for _, facet := range facets {
+		result := FacetLayout{Designator: facet.Designator}
+		switch {
+		case facet.AreaSqFt <= 0:
+			result.Skipped = "no area"
+		case facet.TSRF > 0 && facet.TSRF < cfg.MinTSRF:
+			result.Skipped = fmt.Sprintf("TSRF %.0f%% is below %.0f%%", facet.TSRF*100, cfg.MinTSRF*100)
+		default:
+			result.Panels = fit(cfg, facet.AreaSqFt)
+			if result.Panels == 0 {
+				result.Skipped = "too small after setbacks"
+			}
+		}
+		result.SystemKw = float64(result.Panels) * cfg.Module.Watts / 1000
+		layout.Panels += result.Panels
+		layout.SystemKw += result.SystemKw
+		layout.Facets = append(layout.Facets, result)
+	}
+	return layout, nil
+}
+
+// fit counts the modules that fit on a square facet of areaSqFt
+func fit(cfg Config, areaSqFt float64) int {
+	side := math.Sqrt(areaSqFt * sqFtToSqM)
+	width := side - 2*cfg.EdgeSetbackFt*ftToM
+	height := side - (cfg.EdgeSetbackFt+cfg.RidgeSetbackFt)*ftToM
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	portrait := grid(width, cfg.Module.Width) * grid(height, cfg.Module.Length)
+	landscape := grid(width, cfg.Module.Length) * grid(height, cfg.Module.Width)
+	if landscape > portrait {
+		return landscape
+	}
+	return portrait
+}
+
+func grid(space float64, size float64) int {
+	return int(math.Floor(space / size))
+}
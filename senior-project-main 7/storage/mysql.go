@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/go-sql-driver/mysql"
+
+	"test/migrations"
+)
+
+// OpenMySQL connects to MySQL and applies the MySQL migrations
+func OpenMySQL(ctx context.Context, dsn string) (*SQLStore, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	//DATETIME columns are scanned into time.Time
+	cfg.ParseTime = true
+	//RowsAffected counts the rows matched, as on SQLite, so an update that
+	//writes the values already stored, such as a claim renewed within the
+	//same second, is not taken for a missing row
+	cfg.ClientFoundRows = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err := migrations.Run(ctx, db, migrations.MySQL); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newSQLStore(db), nil
+}
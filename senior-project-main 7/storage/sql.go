@@ -0,0 +1,788 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"test/accounts"
+	"test/batches"
+	"test/orders"
+	"test/orgs"
+	"test/postal"
+)
+
+// SQLStore implements Store on top of database/sql. The queries are shared by
+// MySQL and SQLite; only the connection setup and migrations differ.
+type SQLStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func newSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, now: time.Now}
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) SaveOrder(ctx context.Context, order OrderRecord) error {
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = s.now().UTC()
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT INTO OrderHistory (firstName, lastName, email, street, city, state, zipcode, reportId, reportType, userId, orgId, price, createdAt, paymentId, cardBrand, cardLast4) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		order.FirstName, order.LastName, order.Email, order.Street, order.City, order.State, order.Zip, order.ReportID, order.ReportType,
+		nullZero(order.UserID), nullZero(order.OrgID), nullZero(int64(order.Price)), order.CreatedAt,
+		nullEmpty(order.PaymentID), nullEmpty(order.CardBrand), nullEmpty(order.CardLast4))
+	return err
+}
+
+// nullZero stores 0 as NULL, for the columns added after rows existed
+func nullZero(value int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: value, Valid: value != 0}
+}
+
+// nullEmpty stores "" as NULL, like nullZero
+func nullEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func (s *SQLStore) ReportOwner(ctx context.Context, reportId string) (int64, error) {
+	var userId sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT userId FROM OrderHistory WHERE reportId=?", reportId).Scan(&userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return userId.Int64, err
+}
+
+// orderColumns are read by scanOrder, from OrderHistory aliased o
+const orderColumns = "o.firstName, o.lastName, o.email, o.street, o.city, o.state, o.zipcode, o.reportId, o.reportType, COALESCE(o.userId, 0), COALESCE(o.orgId, 0), COALESCE(o.price, 0), o.createdAt, COALESCE(o.paymentId, ''), COALESCE(o.cardBrand, ''), COALESCE(o.cardLast4, '')"
+
+// newestOrdersFirst sorts by report id, older orders have no date and report
+// ids grow with time
+const newestOrdersFirst = " ORDER BY LENGTH(o.reportId) DESC, o.reportId DESC"
+
+func scanOrder(row interface{ Scan(...interface{}) error }, dest ...interface{}) (OrderRecord, error) {
+	var record OrderRecord
+	var createdAt sql.NullTime
+	columns := []interface{}{&record.FirstName, &record.LastName, &record.Email, &record.Street, &record.City, &record.State, &record.Zip,
+		&record.ReportID, &record.ReportType, &record.UserID, &record.OrgID, &record.Price, &createdAt,
+		&record.PaymentID, &record.CardBrand, &record.CardLast4}
+	err := row.Scan(append(columns, dest...)...)
+	record.CreatedAt = createdAt.Time
+	return record, err
+}
+
+func (s *SQLStore) UserOrders(ctx context.Context, userId int64) ([]OrderRecord, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+orderColumns+" FROM OrderHistory o WHERE o.userId=?"+newestOrdersFirst, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []OrderRecord
+	for rows.Next() {
+		record, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLStore) OrderDetails(ctx context.Context, reportId string) (OrderRecord, error) {
+	record, err := scanOrder(s.db.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM OrderHistory o WHERE o.reportId=?", reportId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, ErrNotFound
+	}
+	return record, err
+}
+
+func (s *SQLStore) SearchOrders(ctx context.Context, filter OrderFilter) ([]OrderListing, int, error) {
+	var where []string
+	var args []interface{}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(search)) + "%"
+		where = append(where, "(o.reportId LIKE ? ESCAPE '!' OR LOWER(o.email) LIKE ? ESCAPE '!' OR LOWER(o.firstName) LIKE ? ESCAPE '!' OR LOWER(o.lastName) LIKE ? ESCAPE '!' OR LOWER(o.street) LIKE ? ESCAPE '!' OR LOWER(o.city) LIKE ? ESCAPE '!')")
+		args = append(args, pattern, pattern, pattern, pattern, pattern, pattern)
+	}
+	if filter.Status != "" {
+		where = append(where, "COALESCE(st.status, ?) = ?")
+		args = append(args, orders.StatusPlaced, filter.Status)
+	}
+	if filter.ReportType != "" {
+		where = append(where, "LOWER(o.reportType) = ?")
+		args = append(args, strings.ToLower(filter.ReportType))
+	}
+	if filter.State != "" {
+		where = append(where, "LOWER(o.state) = ?")
+		args = append(args, strings.ToLower(strings.TrimSpace(filter.State)))
+	}
+	if filter.Email != "" {
+		where = append(where, "LOWER(o.email) = ?")
+		args = append(args, strings.ToLower(strings.TrimSpace(filter.Email)))
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "o.createdAt >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "o.createdAt < ?")
+		args = append(args, filter.To.UTC())
+	}
+	from := " FROM OrderHistory o LEFT JOIN OrderStatus st ON st.reportId = o.reportId"
+	if len(where) > 0 {
+		from += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query := "SELECT " + orderColumns + ", COALESCE(st.status, ?), st.updatedAt" + from + newestOrdersFirst + " LIMIT ? OFFSET ?"
+	rows, err := s.db.QueryContext(ctx, query, append(append([]interface{}{orders.StatusPlaced}, args...), limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var listings []OrderListing
+	for rows.Next() {
+		var listing OrderListing
+		var updatedAt sql.NullTime
+		if listing.OrderRecord, err = scanOrder(rows, &listing.Status, &updatedAt); err != nil {
+			return nil, 0, err
+		}
+		listing.StatusUpdatedAt = updatedAt.Time
+		listings = append(listings, listing)
+	}
+	return listings, total, rows.Err()
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern written with ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (s *SQLStore) OrderTotals(ctx context.Context, since time.Time) ([]OrderTotal, error) {
+	//The month is taken in Go, MySQL and SQLite format dates differently
+	rows, err := s.db.QueryContext(ctx, "SELECT o.createdAt, COALESCE(o.orgId, 0), LOWER(o.reportType), COALESCE(o.price, 0) FROM OrderHistory o LEFT JOIN OrderStatus st ON st.reportId = o.reportId"+
+		" WHERE o.createdAt >= ? AND (st.status IS NULL OR st.status NOT IN (?, ?))", since.UTC(), orders.StatusFailed, orders.StatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []OrderTotal
+	index := map[OrderTotal]int{}
+	for rows.Next() {
+		var total OrderTotal
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt, &total.OrgID, &total.ReportType, &total.Price); err != nil {
+			return nil, err
+		}
+		createdAt = createdAt.UTC()
+		total.Month = time.Date(createdAt.Year(), createdAt.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if i, ok := index[total]; ok {
+			totals[i].Count++
+			continue
+		}
+		index[total] = len(totals)
+		total.Count = 1
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+func (s *SQLStore) ReportType(ctx context.Context, reportId string) (string, error) {
+	var reportType string
+	err := s.db.QueryRowContext(ctx, "SELECT reportType FROM OrderHistory WHERE reportId=?", reportId).Scan(&reportType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return reportType, err
+}
+
+func (s *SQLStore) FindReportByAddress(ctx context.Context, address postal.Address) (string, error) {
+	//Orders of the same ZIP code are compared by their canonical address, which SQL cannot compute
+	rows, err := s.db.QueryContext(ctx, "SELECT o.reportId, o.street, o.city, o.state, o.zipcode FROM OrderHistory o WHERE o.zipcode LIKE ?"+newestOrdersFirst, address.Zip+"%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reportId, street, city, state, zip string
+		if err := rows.Scan(&reportId, &street, &city, &state, &zip); err != nil {
+			return "", err
+		}
+		//Orders stored before addresses were checked may have a city or state that does not parse, the key does not use them
+		ordered, err := postal.Parse(street, city, state, zip)
+		var invalid *postal.ValidationError
+		if errors.As(err, &invalid) && (invalid.Fields["street"] != "" || invalid.Fields["zip"] != "") {
+			continue
+		}
+		if ordered.Key() == address.Key() {
+			return reportId, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNotFound
+}
+
+func (s *SQLStore) SaveNREL(ctx context.Context, result NRELResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//A result fetched again replaces the old one, deleting first upserts the same way on MySQL and SQLite
+	if _, err := tx.ExecContext(ctx, "DELETE FROM NREL WHERE reportId=?", result.ReportID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO NREL (reportId, street, city, state, zipcode, lat, lon, azimuth, tilt, solrad_annual, ac_annual, capacity_factor) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)",
+		result.ReportID, result.Street, result.City, result.State, result.Zip, result.Lat, result.Lon, result.Azimuth, result.Tilt, result.SolradAnnual, result.AcAnnual, result.CapacityFactor)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM NRELMonthly WHERE reportId=?", result.ReportID); err != nil {
+		return err
+	}
+	for _, month := range result.Monthly {
+		_, err := tx.ExecContext(ctx, "INSERT INTO NRELMonthly (reportId, month, ac_monthly, poa_monthly, solrad_monthly, dc_monthly) VALUES (?,?,?,?,?,?)",
+			result.ReportID, month.Month, month.AC, month.POA, month.Solrad, month.DC)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) NRELMonthly(ctx context.Context, reportId string) ([]MonthlyNREL, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT month, ac_monthly, poa_monthly, solrad_monthly, dc_monthly FROM NRELMonthly WHERE reportId=? ORDER BY month", reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var months []MonthlyNREL
+	for rows.Next() {
+		var month MonthlyNREL
+		if err := rows.Scan(&month.Month, &month.AC, &month.POA, &month.Solrad, &month.DC); err != nil {
+			return nil, err
+		}
+		months = append(months, month)
+	}
+	return months, rows.Err()
+}
+
+func (s *SQLStore) ReportData(ctx context.Context, reportId string) (ReportData, error) {
+	var data ReportData
+	err := s.db.QueryRowContext(ctx, "SELECT o.street, o.city, o.state, o.zipcode, n.azimuth, n.tilt, n.solrad_annual, n.ac_annual, COALESCE(n.capacity_factor, 0), o.reportId, COALESCE(o.orgId, 0) FROM OrderHistory o JOIN NREL n ON n.reportId = o.reportId WHERE o.reportId=?", reportId).
+		Scan(&data.Street, &data.City, &data.State, &data.Zip, &data.Azimuth, &data.Tilt, &data.SolradAnnual, &data.AcAnnual, &data.CapacityFactor, &data.ReportID, &data.OrgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return data, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *SQLStore) SaveRoofProduction(ctx context.Context, reportId string, roofs []RoofProduction) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM RoofProduction WHERE reportId=?", reportId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM RoofProductionMonthly WHERE reportId=?", reportId); err != nil {
+		return err
+	}
+	for _, roof := range roofs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO RoofProduction (reportId, designator, azimuth, tilt, areaSqFt, systemCapacity, ac_annual, solrad_annual, panels, module, failed) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
+			reportId, roof.Designator, roof.Azimuth, roof.Tilt, roof.AreaSqFt, roof.SystemCapacity, roof.AcAnnual, roof.SolradAnnual, roof.Panels, roof.Module, roof.Failed)
+		if err != nil {
+			return err
+		}
+		for i, ac := range roof.AcMonthly {
+			_, err := tx.ExecContext(ctx, "INSERT INTO RoofProductionMonthly (reportId, designator, month, ac_monthly) VALUES (?,?,?,?)",
+				reportId, roof.Designator, i+1, ac)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) RoofProduction(ctx context.Context, reportId string) ([]RoofProduction, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT designator, azimuth, tilt, areaSqFt, systemCapacity, ac_annual, solrad_annual, panels, module, failed FROM RoofProduction WHERE reportId=? ORDER BY designator", reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roofs []RoofProduction
+	for rows.Next() {
+		var roof RoofProduction
+		if err := rows.Scan(&roof.Designator, &roof.Azimuth, &roof.Tilt, &roof.AreaSqFt, &roof.SystemCapacity, &roof.AcAnnual, &roof.SolradAnnual, &roof.Panels, &roof.Module, &roof.Failed); err != nil {
+			return nil, err
+		}
+		roofs = append(roofs, roof)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(roofs) == 0 {
+		return roofs, nil
+	}
+
+	monthly, err := s.db.QueryContext(ctx, "SELECT designator, month, ac_monthly FROM RoofProductionMonthly WHERE reportId=? ORDER BY designator, month", reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer monthly.Close()
+
+	byDesignator := make(map[string]int, len(roofs))
+	for i, roof := range roofs {
+		byDesignator[roof.Designator] = i
+	}
+	for monthly.Next() {
+		var designator string
+		var month int
+		var ac float64
+		if err := monthly.Scan(&designator, &month, &ac); err != nil {
+			return nil, err
+		}
+		i, ok := byDesignator[designator]
+		if !ok || month < 1 || month > 12 {
+			continue
+		}
+		if roofs[i].AcMonthly == nil {
+			roofs[i].AcMonthly = make([]float64, 12)
+		}
+		roofs[i].AcMonthly[month-1] = ac
+	}
+	return roofs, monthly.Err()
+}
+
+func (s *SQLStore) SaveArtifact(ctx context.Context, artifact Artifact) error {
+	if artifact.CreatedAt.IsZero() {
+		artifact.CreatedAt = s.now().UTC()
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ReportArtifact WHERE reportId=? AND kind=?", artifact.ReportID, artifact.Kind); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO ReportArtifact (reportId, kind, contentType, data, createdAt) VALUES (?,?,?,?,?)",
+		artifact.ReportID, artifact.Kind, artifact.ContentType, artifact.Data, artifact.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Artifact(ctx context.Context, reportId string, kind string) (Artifact, error) {
+	artifact := Artifact{ReportID: reportId, Kind: kind}
+	err := s.db.QueryRowContext(ctx, "SELECT contentType, data, createdAt FROM ReportArtifact WHERE reportId=? AND kind=?", reportId, kind).
+		Scan(&artifact.ContentType, &artifact.Data, &artifact.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return artifact, ErrNotFound
+	}
+	return artifact, err
+}
+
+func (s *SQLStore) Order(ctx context.Context, reportId string) (orders.Order, error) {
+	order := orders.Order{ReportID: reportId}
+	err := s.db.QueryRowContext(ctx, "SELECT status, detail, updatedAt FROM OrderStatus WHERE reportId=?", reportId).
+		Scan(&order.Status, &order.Detail, &order.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return order, orders.ErrNotFound
+	}
+	return order, err
+}
+
+func (s *SQLStore) PendingOrders(ctx context.Context) ([]orders.Order, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT reportId, status, detail, updatedAt FROM OrderStatus WHERE status IN (?, ?) ORDER BY updatedAt",
+		orders.StatusPlaced, orders.StatusInProgress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []orders.Order
+	for rows.Next() {
+		var order orders.Order
+		if err := rows.Scan(&order.ReportID, &order.Status, &order.Detail, &order.UpdatedAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, order)
+	}
+	return pending, rows.Err()
+}
+
+// CreateOrder records a newly placed order, leaving an existing record alone
+func (s *SQLStore) CreateOrder(ctx context.Context, reportId string) error {
+	_, err := s.Order(ctx, reportId)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, orders.ErrNotFound) {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO OrderStatus (reportId, status, detail, updatedAt) VALUES (?, ?, '', ?)",
+		reportId, orders.StatusPlaced, s.now().UTC())
+	return err
+}
+
+// UpdateStatus moves an order from one status to another, failing when the
+// transition is not allowed or the stored status is no longer from
+func (s *SQLStore) UpdateStatus(ctx context.Context, reportId string, from orders.Status, to orders.Status, detail string) error {
+	if !from.CanTransition(to) {
+		return &orders.TransitionError{From: from, To: to}
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE OrderStatus SET status=?, detail=?, updatedAt=? WHERE reportId=? AND status=?",
+		to, detail, s.now().UTC(), reportId, from)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return orders.ErrConflict
+	}
+	return nil
+}
+
+// CreateUser returns accounts.ErrEmailTaken when the email is already registered
+func (s *SQLStore) CreateUser(ctx context.Context, user accounts.User) (accounts.User, error) {
+	if _, err := s.UserByEmail(ctx, user.Email); err == nil {
+		return accounts.User{}, accounts.ErrEmailTaken
+	} else if !errors.Is(err, accounts.ErrNotFound) {
+		return accounts.User{}, err
+	}
+	user.CreatedAt = s.now().UTC()
+	res, err := s.db.ExecContext(ctx, "INSERT INTO Users (email, passwordHash, orgId, createdAt) VALUES (?,?,?,?)", user.Email, user.PasswordHash, nullZero(user.OrgID), user.CreatedAt)
+	if err != nil {
+		//A concurrent registration of the same email fails the unique index
+		if _, lookupErr := s.UserByEmail(ctx, user.Email); lookupErr == nil {
+			return accounts.User{}, accounts.ErrEmailTaken
+		}
+		return accounts.User{}, err
+	}
+	user.ID, err = res.LastInsertId()
+	return user, err
+}
+
+func (s *SQLStore) User(ctx context.Context, id int64) (accounts.User, error) {
+	return s.user(ctx, "SELECT id, email, passwordHash, COALESCE(orgId, 0), isAdmin, createdAt FROM Users WHERE id=?", id)
+}
+
+func (s *SQLStore) UserByEmail(ctx context.Context, email string) (accounts.User, error) {
+	return s.user(ctx, "SELECT id, email, passwordHash, COALESCE(orgId, 0), isAdmin, createdAt FROM Users WHERE email=?", email)
+}
+
+func (s *SQLStore) user(ctx context.Context, query string, arg interface{}) (accounts.User, error) {
+	var user accounts.User
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.OrgID, &user.Admin, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, accounts.ErrNotFound
+	}
+	return user, err
+}
+
+// SetPasswordHash replaces the password of a user and drops their pending resets
+func (s *SQLStore) SetPasswordHash(ctx context.Context, id int64, passwordHash string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE Users SET passwordHash=? WHERE id=?", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return accounts.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM PasswordReset WHERE userId=?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) SetAdmin(ctx context.Context, id int64, admin bool) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE Users SET isAdmin=? WHERE id=?", admin, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return accounts.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) SaveReset(ctx context.Context, reset accounts.Reset) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO PasswordReset (tokenHash, userId, expiresAt) VALUES (?,?,?)", reset.TokenHash, reset.UserID, reset.ExpiresAt)
+	return err
+}
+
+// TakeReset deletes and returns the reset of tokenHash, so a token works once
+func (s *SQLStore) TakeReset(ctx context.Context, tokenHash string) (accounts.Reset, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return accounts.Reset{}, err
+	}
+	defer tx.Rollback()
+
+	reset := accounts.Reset{TokenHash: tokenHash}
+	err = tx.QueryRowContext(ctx, "SELECT userId, expiresAt FROM PasswordReset WHERE tokenHash=?", tokenHash).Scan(&reset.UserID, &reset.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reset, accounts.ErrNotFound
+	}
+	if err != nil {
+		return reset, err
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM PasswordReset WHERE tokenHash=?", tokenHash)
+	if err != nil {
+		return reset, err
+	}
+	//Another request took the token between the select and the delete
+	if affected, err := res.RowsAffected(); err != nil {
+		return reset, err
+	} else if affected == 0 {
+		return reset, accounts.ErrNotFound
+	}
+	return reset, tx.Commit()
+}
+
+// SaveOrganization creates the organization or updates the one with the same slug
+func (s *SQLStore) SaveOrganization(ctx context.Context, org orgs.Organization) (orgs.Organization, error) {
+	existing, err := s.OrganizationBySlug(ctx, org.Slug)
+	if errors.Is(err, orgs.ErrNotFound) {
+		res, err := s.db.ExecContext(ctx, "INSERT INTO Organizations (slug, name, address, address2, postalCode, logo, primaryColor, accentColor, basicPrice, advancedPrice, signupCode) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
+			org.Slug, org.Name, org.Address, org.Address2, org.PostalCode, org.Logo, org.PrimaryColor, org.AccentColor, org.BasicPrice, org.AdvancedPrice, org.SignupCode)
+		if err != nil {
+			return org, err
+		}
+		org.ID, err = res.LastInsertId()
+		return org, err
+	}
+	if err != nil {
+		return org, err
+	}
+	org.ID = existing.ID
+	_, err = s.db.ExecContext(ctx, "UPDATE Organizations SET name=?, address=?, address2=?, postalCode=?, logo=?, primaryColor=?, accentColor=?, basicPrice=?, advancedPrice=?, signupCode=? WHERE id=?",
+		org.Name, org.Address, org.Address2, org.PostalCode, org.Logo, org.PrimaryColor, org.AccentColor, org.BasicPrice, org.AdvancedPrice, org.SignupCode, org.ID)
+	return org, err
+}
+
+const organizationColumns = "id, slug, name, address, address2, postalCode, logo, primaryColor, accentColor, basicPrice, advancedPrice, signupCode"
+
+func (s *SQLStore) Organization(ctx context.Context, id int64) (orgs.Organization, error) {
+	return scanOrganization(s.db.QueryRowContext(ctx, "SELECT "+organizationColumns+" FROM Organizations WHERE id=?", id))
+}
+
+func (s *SQLStore) OrganizationBySlug(ctx context.Context, slug string) (orgs.Organization, error) {
+	return scanOrganization(s.db.QueryRowContext(ctx, "SELECT "+organizationColumns+" FROM Organizations WHERE slug=?", slug))
+}
+
+func (s *SQLStore) Organizations(ctx context.Context) ([]orgs.Organization, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+organizationColumns+" FROM Organizations ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []orgs.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, org)
+	}
+	return list, rows.Err()
+}
+
+// scanOrganization reads the organizationColumns of a *sql.Row or *sql.Rows
+func scanOrganization(row interface{ Scan(...interface{}) error }) (orgs.Organization, error) {
+	var org orgs.Organization
+	err := row.Scan(&org.ID, &org.Slug, &org.Name, &org.Address, &org.Address2, &org.PostalCode, &org.Logo,
+		&org.PrimaryColor, &org.AccentColor, &org.BasicPrice, &org.AdvancedPrice, &org.SignupCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return org, orgs.ErrNotFound
+	}
+	return org, err
+}
+
+// CreateBatch records an uploaded batch with its rows, returning it with its id
+func (s *SQLStore) CreateBatch(ctx context.Context, batch batches.Batch) (batches.Batch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return batch, err
+	}
+	defer tx.Rollback()
+
+	batch.CreatedAt = s.now().UTC()
+	res, err := tx.ExecContext(ctx, "INSERT INTO OrderBatches (userId, orgId, name, poNumber, status, createdAt) VALUES (?,?,?,?,?,?)",
+		batch.UserID, nullZero(batch.OrgID), batch.Name, batch.PONumber, batch.Status, batch.CreatedAt)
+	if err != nil {
+		return batch, err
+	}
+	if batch.ID, err = res.LastInsertId(); err != nil {
+		return batch, err
+	}
+	for _, row := range batch.Rows {
+		_, err := tx.ExecContext(ctx, "INSERT INTO OrderBatchRows (batchId, line, street, city, state, zipcode, reportType, referenceId, claimNumber, price, status, detail, reportId) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)",
+			batch.ID, row.Line, row.Street, row.City, row.State, row.Zip, row.ReportType, row.ReferenceID, row.ClaimNumber, row.Price, row.Status, row.Detail, nullEmpty(row.ReportID))
+		if err != nil {
+			return batch, err
+		}
+	}
+	return batch, tx.Commit()
+}
+
+// batchColumns are read by scanBatch
+const batchColumns = "id, userId, COALESCE(orgId, 0), name, poNumber, status, firstName, lastName, email, street, city, state, zipcode, COALESCE(paymentId, ''), COALESCE(cardBrand, ''), COALESCE(cardLast4, ''), createdAt"
+
+func scanBatch(row interface{ Scan(...interface{}) error }) (batches.Batch, error) {
+	var batch batches.Batch
+	err := row.Scan(&batch.ID, &batch.UserID, &batch.OrgID, &batch.Name, &batch.PONumber, &batch.Status,
+		&batch.FirstName, &batch.LastName, &batch.Email, &batch.Street, &batch.City, &batch.State, &batch.Zip,
+		&batch.PaymentID, &batch.CardBrand, &batch.CardLast4, &batch.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return batch, batches.ErrNotFound
+	}
+	return batch, err
+}
+
+func (s *SQLStore) Batch(ctx context.Context, id int64) (batches.Batch, error) {
+	batch, err := scanBatch(s.db.QueryRowContext(ctx, "SELECT "+batchColumns+" FROM OrderBatches WHERE id=?", id))
+	if err != nil {
+		return batch, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT line, street, city, state, zipcode, reportType, referenceId, claimNumber, price, status, detail, COALESCE(reportId, '') FROM OrderBatchRows WHERE batchId=? ORDER BY line", id)
+	if err != nil {
+		return batch, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row batches.Row
+		err := rows.Scan(&row.Line, &row.Street, &row.City, &row.State, &row.Zip, &row.ReportType, &row.ReferenceID, &row.ClaimNumber,
+			&row.Price, &row.Status, &row.Detail, &row.ReportID)
+		if err != nil {
+			return batch, err
+		}
+		batch.Rows = append(batch.Rows, row)
+	}
+	return batch, rows.Err()
+}
+
+func (s *SQLStore) UserBatches(ctx context.Context, userId int64) ([]batches.Batch, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+batchColumns+" FROM OrderBatches WHERE userId=? ORDER BY id DESC", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []batches.Batch
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, batch)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLStore) PlacingBatches(ctx context.Context) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM OrderBatches WHERE status=? ORDER BY id", batches.StatusPlacing)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StartBatch records the payment of a previewed batch and moves it to
+// placing, failing with batches.ErrConflict when it is no longer previewed
+func (s *SQLStore) StartBatch(ctx context.Context, batch batches.Batch) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE OrderBatches SET status=?, firstName=?, lastName=?, email=?, street=?, city=?, state=?, zipcode=?, paymentId=?, cardBrand=?, cardLast4=? WHERE id=? AND status=?",
+		batches.StatusPlacing, batch.FirstName, batch.LastName, batch.Email, batch.Street, batch.City, batch.State, batch.Zip,
+		nullEmpty(batch.PaymentID), nullEmpty(batch.CardBrand), nullEmpty(batch.CardLast4), batch.ID, batches.StatusPreview)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return batches.ErrConflict
+	}
+	return nil
+}
+
+// ClaimBatch makes claim the only placer of a placing batch until the time
+// until, failing with batches.ErrConflict when the batch is no longer placing
+// or another claim on it has not expired
+func (s *SQLStore) ClaimBatch(ctx context.Context, id int64, claim string, until time.Time) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE OrderBatches SET claim=?, claimedUntil=? WHERE id=? AND status=? AND (claim IS NULL OR claim=? OR claimedUntil<?)",
+		claim, until.UTC(), id, batches.StatusPlacing, claim, s.now().UTC())
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return batches.ErrConflict
+	}
+	return nil
+}
+
+func (s *SQLStore) FinishBatch(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE OrderBatches SET status=? WHERE id=? AND status=?", batches.StatusPlaced, id, batches.StatusPlacing)
+	return err
+}
+
+func (s *SQLStore) UpdateBatchRow(ctx context.Context, batchId int64, line int, status batches.RowStatus, reportId string, detail string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE OrderBatchRows SET status=?, reportId=?, detail=? WHERE batchId=? AND line=?",
+		status, nullEmpty(reportId), detail, batchId, line)
+	return err
+}
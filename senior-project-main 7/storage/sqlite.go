@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"test/migrations"
+)
+
+// OpenSQLite opens (creating if needed) the SQLite database file at path and
+// applies the SQLite migrations. It needs no server, so the whole application
+// can run offline; use ":memory:" for a throwaway database.
+func OpenSQLite(ctx context.Context, path string) (*SQLStore, error) {
+	dsn := path
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	dsn += separator + "_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	//SQLite allows a single writer, and every connection to ":memory:" would
+	//see its own empty database
+	db.SetMaxOpenConns(1)
+
+	if err := migrations.Run(ctx, db, migrations.SQLite); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newSQLStore(db), nil
+}
@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"test/accounts"
+	"test/batches"
+	"test/orders"
+	"test/postal"
+)
+
+// openTestStore returns an empty SQLite store with every migration applied
+func openTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	store, err := OpenSQLite(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteUpdateStatus(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if _, err := store.Order(ctx, "1"); !errors.Is(err, orders.ErrNotFound) {
+		t.Fatalf("Order of an unknown report err = %v, want ErrNotFound", err)
+	}
+	if err := store.CreateOrder(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to orders.Status
+		// want is the error expected, a TransitionError when transition is set
+		want       error
+		transition bool
+	}{
+		{name: "allowed", from: orders.StatusPlaced, to: orders.StatusInProgress},
+		{name: "stale from", from: orders.StatusPlaced, to: orders.StatusCompleted, want: orders.ErrConflict},
+		{name: "not allowed", from: orders.StatusInProgress, to: orders.StatusPlaced, transition: true},
+		{name: "unknown report", from: orders.StatusInProgress, to: orders.StatusFailed, want: orders.ErrConflict},
+		{name: "finished", from: orders.StatusInProgress, to: orders.StatusCompleted},
+	}
+	for _, test := range tests {
+		reportId := "1"
+		if test.name == "unknown report" {
+			reportId = "2"
+		}
+		err := store.UpdateStatus(ctx, reportId, test.from, test.to, test.name)
+		var transition *orders.TransitionError
+		if test.transition {
+			if !errors.As(err, &transition) {
+				t.Errorf("%s: err = %v, want a TransitionError", test.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	order, err := store.Order(ctx, "1")
+	if err != nil || order.Status != orders.StatusCompleted || order.Detail != "finished" {
+		t.Errorf("Order = %+v, %v, want completed", order, err)
+	}
+	if err := store.CreateOrder(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := store.Order(ctx, "1"); order.Status != orders.StatusCompleted {
+		t.Errorf("CreateOrder of a known report reset it to %s", order.Status)
+	}
+	if pending, err := store.PendingOrders(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("PendingOrders = %+v, %v, want none", pending, err)
+	}
+}
+
+func TestSQLiteTakeReset(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	user, err := store.CreateUser(ctx, accounts.User{Email: "jane@example.com", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser(ctx, accounts.User{Email: "jane@example.com"}); !errors.Is(err, accounts.ErrEmailTaken) {
+		t.Errorf("CreateUser of a taken email err = %v, want ErrEmailTaken", err)
+	}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, hash := range []string{"a", "b"} {
+		if err := store.SaveReset(ctx, accounts.Reset{TokenHash: hash, UserID: user.ID, ExpiresAt: expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reset, err := store.TakeReset(ctx, "a")
+	if err != nil || reset.UserID != user.ID || !reset.ExpiresAt.Equal(expires) {
+		t.Errorf("TakeReset = %+v, %v", reset, err)
+	}
+	if _, err := store.TakeReset(ctx, "a"); !errors.Is(err, accounts.ErrNotFound) {
+		t.Errorf("second TakeReset err = %v, want ErrNotFound", err)
+	}
+
+	// a new password drops the resets still pending
+	if err := store.SetPasswordHash(ctx, user.ID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.TakeReset(ctx, "b"); !errors.Is(err, accounts.ErrNotFound) {
+		t.Errorf("TakeReset after a password change err = %v, want ErrNotFound", err)
+	}
+	if err := store.SetPasswordHash(ctx, user.ID+1, "new"); !errors.Is(err, accounts.ErrNotFound) {
+		t.Errorf("SetPasswordHash of an unknown user err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteFindReportByAddress(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	ordered := []OrderRecord{
+		{ReportID: "1", Street: "123 North Main Street, Apt. 4", City: "Pasadena", State: "CA", Zip: "91103"},
+		{ReportID: "2", Street: "123 N Main St", City: "Pasadena", State: "CA", Zip: "91103"},
+		{ReportID: "3", Street: "no number", City: "Pasadena", State: "CA", Zip: "91103"},
+	}
+	for _, order := range ordered {
+		if err := store.SaveOrder(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		street, zip string
+		want        string
+	}{
+		{street: "123 N. Main St #4", zip: "91103-1234", want: "1"},
+		{street: "123 north main street", zip: "91103", want: "2"},
+		{street: "123 N Main St Apt 5", zip: "91103"},
+		{street: "123 N Main St", zip: "91104"},
+	}
+	for _, test := range tests {
+		address, err := postal.Parse(test.street, "Pasadena", "CA", test.zip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.FindReportByAddress(ctx, address)
+		if test.want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("FindReportByAddress(%s) = %q, %v, want ErrNotFound", address, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("FindReportByAddress(%s) = %q, %v, want %q", address, got, err, test.want)
+		}
+	}
+}
+
+func TestSQLiteSaveNREL(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.SaveOrder(ctx, OrderRecord{ReportID: "1", Street: "1 Main St", City: "Pasadena", State: "CA", Zip: "91103"}); err != nil {
+		t.Fatal(err)
+	}
+	first := NRELResult{ReportID: "1", Azimuth: "180", Tilt: "20", AcAnnual: 5000,
+		Monthly: []MonthlyNREL{{Month: 1, AC: 300}, {Month: 2, AC: 350}, {Month: 3, AC: 400}}}
+	second := NRELResult{ReportID: "1", Azimuth: "170", Tilt: "25", AcAnnual: 6000, CapacityFactor: 18.5,
+		Monthly: []MonthlyNREL{{Month: 2, AC: 420, DC: 440, POA: 150, Solrad: 5.1}, {Month: 1, AC: 410}}}
+	for _, result := range []NRELResult{first, second} {
+		if err := store.SaveNREL(ctx, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := store.ReportData(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Azimuth != "170" || data.AcAnnual != 6000 || data.CapacityFactor != 18.5 {
+		t.Errorf("ReportData = %+v, want the second result", data)
+	}
+	monthly, err := store.NRELMonthly(ctx, "1")
+	want := []MonthlyNREL{{Month: 1, AC: 410}, {Month: 2, AC: 420, DC: 440, POA: 150, Solrad: 5.1}}
+	if err != nil || !reflect.DeepEqual(monthly, want) {
+		t.Errorf("NRELMonthly = %+v, %v, want %+v", monthly, err, want)
+	}
+	if _, err := store.ReportData(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReportData of an unknown report err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteBatch(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	user, err := store.CreateUser(ctx, accounts.User{Email: "jane@example.com", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := store.CreateBatch(ctx, batches.Batch{UserID: user.ID, Name: "roofs.csv", PONumber: "PO-1", Status: batches.StatusPreview, Rows: []batches.Row{
+		{Line: 2, Street: "1 MAIN ST", City: "PASADENA", State: "CA", Zip: "91103", ReportType: "basic", Price: 25, Status: batches.RowReady},
+		{Line: 3, Street: "nowhere", ReportType: "basic", Status: batches.RowInvalid, Detail: "no house number"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Batch(ctx, batch.ID+1); !errors.Is(err, batches.ErrNotFound) {
+		t.Errorf("Batch of an unknown id err = %v, want ErrNotFound", err)
+	}
+
+	batch.Email, batch.PaymentID, batch.CardBrand, batch.CardLast4 = "jane@example.com", "pay_1", "visa", "4242"
+	if err := store.StartBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StartBatch(ctx, batch); !errors.Is(err, batches.ErrConflict) {
+		t.Errorf("second StartBatch err = %v, want ErrConflict", err)
+	}
+	if ids, err := store.PlacingBatches(ctx); err != nil || !reflect.DeepEqual(ids, []int64{batch.ID}) {
+		t.Errorf("PlacingBatches = %v, %v, want [%d]", ids, err, batch.ID)
+	}
+
+	if err := store.UpdateBatchRow(ctx, batch.ID, 2, batches.RowPlaced, "42", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishBatch(ctx, batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Batch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != batches.StatusPlaced || got.PaymentID != "pay_1" || got.CardLast4 != "4242" || len(got.Rows) != 2 {
+		t.Fatalf("Batch = %+v", got)
+	}
+	if row := got.Rows[0]; row.Status != batches.RowPlaced || row.ReportID != "42" || row.Price != 25 {
+		t.Errorf("row 2 = %+v, want placed as report 42", row)
+	}
+	if row := got.Rows[1]; row.Status != batches.RowInvalid || row.ReportID != "" || row.Detail != "no house number" {
+		t.Errorf("row 3 = %+v, want it left invalid", row)
+	}
+	if ids, err := store.PlacingBatches(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("PlacingBatches = %v, %v, want none", ids, err)
+	}
+	if list, err := store.UserBatches(ctx, user.ID); err != nil || len(list) != 1 || list[0].ID != batch.ID {
+		t.Errorf("UserBatches = %+v, %v", list, err)
+	}
+}
+
+func TestSQLiteSetAdmin(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	// CreateUser never stores the flag, only SetAdmin does
+	user, err := store.CreateUser(ctx, accounts.User{Email: "jane@example.com", PasswordHash: "hash", Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.User(ctx, user.ID); err != nil || got.Admin {
+		t.Errorf("User = %+v, %v, want a registered user not admin", got, err)
+	}
+	for _, admin := range []bool{true, false} {
+		if err := store.SetAdmin(ctx, user.ID, admin); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.UserByEmail(ctx, user.Email); err != nil || got.Admin != admin {
+			t.Errorf("UserByEmail = %+v, %v, want Admin %t", got, err, admin)
+		}
+	}
+	if err := store.SetAdmin(ctx, user.ID+1, true); !errors.Is(err, accounts.ErrNotFound) {
+		t.Errorf("SetAdmin of an unknown user err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteClaimBatch(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	batch, err := store.CreateBatch(ctx, batches.Batch{UserID: 1, Name: "roofs.csv", Status: batches.StatusPreview})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClaimBatch(ctx, batch.ID, "a", now.Add(time.Minute)); !errors.Is(err, batches.ErrConflict) {
+		t.Errorf("claim of a previewed batch err = %v, want ErrConflict", err)
+	}
+	if err := store.StartBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name  string
+		claim string
+		// after is how long after the first claim the step runs
+		after time.Duration
+		want  error
+	}{
+		{name: "first", claim: "a"},
+		{name: "other instance", claim: "b", after: 30 * time.Second, want: batches.ErrConflict},
+		{name: "renewed", claim: "a", after: 50 * time.Second},
+		{name: "renewed in the same second", claim: "a", after: 50 * time.Second},
+		{name: "still renewed", claim: "b", after: 100 * time.Second, want: batches.ErrConflict},
+		{name: "expired", claim: "b", after: 120 * time.Second},
+		{name: "taken over", claim: "a", after: 130 * time.Second, want: batches.ErrConflict},
+	}
+	start := now
+	for _, step := range steps {
+		now = start.Add(step.after)
+		if err := store.ClaimBatch(ctx, batch.ID, step.claim, now.Add(time.Minute)); !errors.Is(err, step.want) {
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.want)
+		}
+	}
+
+	if err := store.FinishBatch(ctx, batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClaimBatch(ctx, batch.ID, "b", now.Add(time.Minute)); !errors.Is(err, batches.ErrConflict) {
+		t.Errorf("claim of a placed batch err = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"test/accounts"
+	"test/batches"
+	"test/orders"
+	"test/orgs"
+	"test/postal"
+)
+
+// ErrNotFound is returned when a report, NREL result or artifact is missing.
+// Order lookups return orders.ErrNotFound instead.
+var ErrNotFound = errors.New("storage: not found")
+
+// Store is everything the application persists
+type Store interface {
+	orders.Store
+	accounts.Store
+	orgs.Store
+	batches.Store
+
+	// SaveOrder records a placed order in OrderHistory
+	SaveOrder(ctx context.Context, order OrderRecord) error
+	// ReportOwner returns the id of the user who ordered reportId, 0 for
+	// orders placed before accounts existed
+	ReportOwner(ctx context.Context, reportId string) (int64, error)
+	// UserOrders returns the orders of a user, most recent first
+	UserOrders(ctx context.Context, userId int64) ([]OrderRecord, error)
+	// ReportType returns the report type ordered for reportId
+	ReportType(ctx context.Context, reportId string) (string, error)
+	// FindReportByAddress returns the report already ordered for the
+	// property of address, however its address was written
+	FindReportByAddress(ctx context.Context, address postal.Address) (string, error)
+	// OrderDetails returns the OrderHistory row of reportId
+	OrderDetails(ctx context.Context, reportId string) (OrderRecord, error)
+	// SearchOrders returns a page of the orders matching filter, most recent
+	// first, and how many match in all
+	SearchOrders(ctx context.Context, filter OrderFilter) ([]OrderListing, int, error)
+	// OrderTotals counts the orders placed since a date by month,
+	// organization, report type and price, leaving out the orders that failed
+	// or were cancelled at EagleView
+	OrderTotals(ctx context.Context, since time.Time) ([]OrderTotal, error)
+
+	// SaveNREL stores the PVWatts result of a report with its monthly series,
+	// replacing an earlier result of the same report
+	SaveNREL(ctx context.Context, result NRELResult) error
+	// NRELMonthly returns the monthly series of a report, January first
+	NRELMonthly(ctx context.Context, reportId string) ([]MonthlyNREL, error)
+	// ReportData joins the order with the NREL result of its address
+	ReportData(ctx context.Context, reportId string) (ReportData, error)
+
+	// SaveRoofProduction replaces the per facet production of a report
+	SaveRoofProduction(ctx context.Context, reportId string, roofs []RoofProduction) error
+	RoofProduction(ctx context.Context, reportId string) ([]RoofProduction, error)
+
+	// SaveArtifact stores a file produced for a report, replacing one of the same kind
+	SaveArtifact(ctx context.Context, artifact Artifact) error
+	Artifact(ctx context.Context, reportId string, kind string) (Artifact, error)
+
+	Close() error
+}
+
+// OrderRecord is one row of OrderHistory
+type OrderRecord struct {
+	FirstName  string
+	LastName   string
+	Email      string
+	Street     string
+	City       string
+	State      string
+	Zip        string
+	ReportID   string
+	ReportType string
+	// UserID is the account that placed the order, 0 when it has none
+	UserID int64
+	// OrgID is the organization of the order, 0 for the default one
+	OrgID int64
+	// Price is what was charged in whole dollars, 0 when it was not recorded
+	Price int
+	// CreatedAt is when the order was placed, zero when it is not known
+	CreatedAt time.Time
+	// PaymentID is the payment at the payment provider, CardBrand and
+	// CardLast4 tell the card it was charged to. All are empty for the orders
+	// paid through EagleView.
+	PaymentID string
+	CardBrand string
+	CardLast4 string
+}
+
+// OrderFilter selects the orders of SearchOrders. Empty fields match every
+// order.
+type OrderFilter struct {
+	// Search matches part of the report id, email, name, street or city
+	Search     string
+	Status     orders.Status
+	ReportType string
+	State      string
+	Email      string
+	// From and To bound the order date, To excluded. Orders without a date
+	// only match when both are zero.
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// OrderListing is an order with its EagleView status. Orders without a
+// tracked status are listed as placed.
+type OrderListing struct {
+	OrderRecord
+	Status orders.Status
+	// StatusUpdatedAt is zero when the status was never tracked
+	StatusUpdatedAt time.Time
+}
+
+// OrderTotal is the number of orders of one month, organization, report type
+// and price. Month is the first day of the month in UTC.
+type OrderTotal struct {
+	Month      time.Time
+	OrgID      int64
+	ReportType string
+	// Price is 0 for orders placed before prices were recorded
+	Price int
+	Count int
+}
+
+// NRELResult is the PVWatts answer stored for a report
+type NRELResult struct {
+	ReportID     string
+	Street       string
+	City         string
+	State        string
+	Zip          string
+	Lat          float64
+	Lon          float64
+	Azimuth      string
+	Tilt         string
+	SolradAnnual float64
+	AcAnnual     float64
+	// CapacityFactor is the AC output as a percentage of nameplate capacity
+	CapacityFactor float64
+	Monthly        []MonthlyNREL
+}
+
+// MonthlyNREL is one month of a PVWatts result. Month runs from 1 to 12.
+type MonthlyNREL struct {
+	Month int
+	// AC and DC are the system output in kWh
+	AC float64
+	DC float64
+	// POA is the plane of array irradiance in kWh/m2
+	POA float64
+	// Solrad is the average daily irradiance in kWh/m2/day
+	Solrad float64
+}
+
+// ReportData is what the advanced report page needs from the database
+type ReportData struct {
+	ReportID string
+	// OrgID is the organization the report was ordered for, 0 for the default one
+	OrgID          int64
+	Street         string
+	City           string
+	State          string
+	Zip            string
+	Azimuth        string
+	Tilt           string
+	SolradAnnual   float64
+	AcAnnual       float64
+	CapacityFactor float64
+}
+
+// RoofProduction is the PVWatts estimate of one roof facet
+type RoofProduction struct {
+	Designator     string
+	Azimuth        float64
+	Tilt           float64
+	AreaSqFt       float64
+	SystemCapacity float64
+	AcAnnual       float64
+	SolradAnnual   float64
+	// AcMonthly is the AC output in kWh of each month, January first
+	AcMonthly []float64
+	// Panels of Module were laid out on the facet, none when it was skipped
+	Panels int
+	Module string
+	// Failed facets have no production because PVWatts failed for them, they
+	// are estimated again on the next view
+	Failed bool
+}
+
+// Artifact kinds
+const (
+	ArtifactInvoice = "invoice"
+)
+
+// Artifact is a file generated or downloaded for a report
+type Artifact struct {
+	ReportID    string
+	Kind        string
+	ContentType string
+	Data        []byte
+	CreatedAt   time.Time
+}
+
+// Open connects to the database named by driver ("mysql" or "sqlite") and
+// brings its schema up to date
+func Open(ctx context.Context, driver string, dsn string) (*SQLStore, error) {
+	switch driver {
+	case "mysql":
+		return OpenMySQL(ctx, dsn)
+	case "sqlite":
+		return OpenSQLite(ctx, dsn)
+	}
+	return nil, fmt.Errorf("storage: unsupported driver %q", driver)
+}